@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/shuliakovsky/email-checker/internal/domains"
+	"github.com/shuliakovsky/email-checker/internal/export"
+	"github.com/shuliakovsky/email-checker/internal/smtp"
+)
+
+// readConfigFile loads --config, or config.yaml/config.json from the working directory or /etc/email-checker/
+// An explicitly given file must exist and parse; keys must match flag names
+func readConfigFile() error {
+	if path := viper.GetString("config"); path != "" {
+		viper.SetConfigFile(path)
+		if err := viper.ReadInConfig(); err != nil {
+			return fmt.Errorf("read config file %s: %w", path, err)
+		}
+	} else {
+		viper.SetConfigName("config")
+		viper.AddConfigPath(".")
+		viper.AddConfigPath("/etc/email-checker/")
+		if err := viper.ReadInConfig(); err != nil {
+			var notFound viper.ConfigFileNotFoundError
+			if errors.As(err, &notFound) {
+				return nil // The config file is optional
+			}
+			return fmt.Errorf("read config file: %w", err)
+		}
+	}
+	return checkConfigKeys(viper.ConfigFileUsed())
+}
+
+// checkConfigKeys rejects keys in the config file that do not correspond to any flag,
+// so a typo fails at startup instead of silently leaving the default in place
+func checkConfigKeys(path string) error {
+	file := viper.New()
+	file.SetConfigFile(path)
+	if err := file.ReadInConfig(); err != nil {
+		return fmt.Errorf("read config file %s: %w", path, err)
+	}
+	var unknown []string
+	for _, key := range file.AllKeys() {
+		if pflag.Lookup(key) == nil {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown keys in %s: %s", path, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// validateConfig checks settings shared by CLI and server mode and reports every invalid value at once
+func validateConfig() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	for _, key := range []string{"workers", "task-concurrency", "dns-concurrency", "max-batch-emails", "max-task-emails"} {
+		check(viper.GetInt(key) > 0, "%s must be positive, got %d", key, viper.GetInt(key))
+	}
+	for _, key := range []string{"smtp-connect-timeout", "smtp-command-timeout", "task-ttl", "throttle-ttl", "throttle-snapshot-interval"} {
+		check(viper.GetDuration(key) > 0, "%s must be a positive duration, got %q", key, viper.GetString(key))
+	}
+	check(viper.GetInt("smtp-max-retries") > 0, "smtp-max-retries must be positive, got %d", viper.GetInt("smtp-max-retries"))
+	check(viper.GetDuration("smtp-retry-delay") >= 0, "smtp-retry-delay must not be negative")
+	check(viper.GetDuration("smtp-retry-max-time") >= 0, "smtp-retry-max-time must not be negative")
+	check(viper.GetDuration("task-timeout") >= 0, "task-timeout must not be negative")
+	check(viper.GetInt("max-task-emails-monthly") >= 0, "max-task-emails-monthly must not be negative")
+	check(viper.GetInt("metrics-domain-limit") >= 0, "metrics-domain-limit must not be negative")
+	check(viper.GetInt("smtp-parallel-mx") >= 0, "smtp-parallel-mx must not be negative")
+	jitter := viper.GetFloat64("cache-ttl-jitter")
+	check(jitter >= 0 && jitter <= 1, "cache-ttl-jitter must be between 0 and 1, got %v", jitter)
+
+	check(validPort(viper.GetString("port")), "port must be a TCP port number, got %q", viper.GetString("port"))
+	check(validPort(viper.GetString("pg-port")), "pg-port must be a TCP port number, got %q", viper.GetString("pg-port"))
+	if addr := viper.GetString("metrics-addr"); addr != "" {
+		_, port, err := net.SplitHostPort(addr)
+		check(err == nil && validPort(port), "metrics-addr must be host:port, got %q", addr)
+	}
+	if viper.GetString("export-s3-bucket") != "" {
+		if _, err := export.ParseEndpoint(viper.GetString("export-s3-endpoint")); err != nil {
+			problems = append(problems, "export-s3-endpoint: "+err.Error())
+		}
+	}
+	ports := viper.GetStringSlice("smtp-ports")
+	check(len(ports) > 0, "smtp-ports must list at least one port")
+	for _, port := range ports {
+		check(validPort(port), "smtp-ports entry %q is not a TCP port number", port)
+	}
+
+	if _, err := smtp.ParseIPPreference(viper.GetString("smtp-ip-preference")); err != nil {
+		problems = append(problems, "smtp-ip-preference: "+err.Error())
+	}
+	if _, err := smtp.ParseTLSVersion(viper.GetString("smtp-tls-min-version")); err != nil {
+		problems = append(problems, "smtp-tls-min-version: "+err.Error())
+	}
+	switch viper.GetString("mx-cache") {
+	case "shared", "local":
+	default:
+		problems = append(problems, fmt.Sprintf("mx-cache must be shared or local, got %q", viper.GetString("mx-cache")))
+	}
+	switch domains.Strategy(viper.GetString("helo-strategy")) {
+	case domains.StrategyRoundRobin, domains.StrategyWeighted:
+	default:
+		problems = append(problems, fmt.Sprintf("helo-strategy must be round-robin or weighted, got %q", viper.GetString("helo-strategy")))
+	}
+	if err := domains.ValidateMapping(viper.GetStringSlice("helo-domain-map")); err != nil {
+		problems = append(problems, "helo-domain-map: "+err.Error())
+	}
+	for _, cidr := range viper.GetStringSlice("webhook-allow-networks") {
+		_, _, err := net.ParseCIDR(cidr)
+		check(err == nil, "webhook-allow-networks entry %q is not a CIDR range", cidr)
+	}
+	for _, key := range []string{"disposable-index-url", "disposable-wildcard-url"} {
+		u, err := url.Parse(viper.GetString(key))
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "%s must be an http(s) URL, got %q", key, viper.GetString(key))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// validPort reports whether value is a TCP port number
+func validPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port > 0 && port <= 65535
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// collectEmails merges the --emails list with addresses read from --emails-file
+// Entries are trimmed and deduplicated case-insensitively, preserving first-seen order
+func collectEmails(emailsFlag, emailsFile string) ([]string, error) {
+	var emails []string
+	if emailsFlag != "" {
+		emails = append(emails, strings.Split(emailsFlag, ",")...)
+	}
+
+	if emailsFile != "" {
+		fromFile, err := readEmailsFile(emailsFile)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, fromFile...)
+	}
+
+	seen := make(map[string]struct{}, len(emails))
+	unique := make([]string, 0, len(emails))
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		key := strings.ToLower(email)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, email)
+	}
+	return unique, nil
+}
+
+// readEmailsFile reads one email per line from a file, or from stdin when path is "-"
+// Blank lines and lines starting with '#' are ignored
+func readEmailsFile(path string) ([]string, error) {
+	var src io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open emails file: %w", err)
+		}
+		defer f.Close()
+		src = f
+	}
+
+	var emails []string
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		emails = append(emails, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read emails: %w", err)
+	}
+	return emails, nil
+}
@@ -2,24 +2,28 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"net"
+	"os"
 	"strings"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/go-redis/redis/v8"
 	"github.com/shuliakovsky/email-checker/internal/cache"
 	"github.com/shuliakovsky/email-checker/internal/checker"
 	"github.com/shuliakovsky/email-checker/internal/disposable"
 	"github.com/shuliakovsky/email-checker/internal/domains"
+	"github.com/shuliakovsky/email-checker/internal/lock"
 	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/internal/metrics"
 	"github.com/shuliakovsky/email-checker/internal/mx"
 	"github.com/shuliakovsky/email-checker/internal/server"
 	"github.com/shuliakovsky/email-checker/internal/smtp"
 	"github.com/shuliakovsky/email-checker/internal/storage"
 	"github.com/shuliakovsky/email-checker/internal/throttle"
+	"github.com/shuliakovsky/email-checker/internal/tracing"
+	"github.com/shuliakovsky/email-checker/pkg/types"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
@@ -27,6 +31,7 @@ import (
 var (
 	Version    string = "0.0.1" // Current application version
 	CommitHash string = ""      // Git commit hash from build
+	BuildTime  string = ""      // Build timestamp (RFC 3339) from build
 )
 
 // Displays version information from build
@@ -35,29 +40,95 @@ func printVersion() {
 	if CommitHash != "" {
 		fmt.Printf("commit hash: %s\n", CommitHash)
 	}
+	if BuildTime != "" {
+		fmt.Printf("build time: %s\n", BuildTime)
+	}
 }
 
 // Function to initialize Viper configuration
 func initViper() {
 	// Configure command-line flags
+	pflag.String("config", "", "Config file (YAML or JSON); defaults to config.yaml/config.json in . or /etc/email-checker/")
 	pflag.String("admin-key", "", "Admin secret key")
 	pflag.String("dns", "1.1.1.1", "DNS server IP address")
 	pflag.String("emails", "", "Comma-separated email addresses")
-	pflag.Int("workers", 10, "Number of concurrent workers")
+	pflag.String("emails-file", "", "File with one email per line (use - for stdin)")
+	pflag.String("format", "json", "CLI output format (json, jsonl, csv)")
+	pflag.String("fail-on", "none", "CLI exit code policy (none, invalid, undeliverable)")
+	pflag.Int("workers", 10, "Number of concurrent workers (per task in server mode)")
+	pflag.Bool("reject-disposable", false, "Report disposable addresses as undeliverable without DNS/SMTP checks")
+	pflag.Float64("cache-ttl-jitter", checker.DefaultTTLJitter, "Random +/- fraction applied to cache TTLs to spread expirations (0 disables)")
+	pflag.Bool("preload-cache", false, "Cache the results of stored tasks at server startup so recent verdicts are reused")
+	pflag.Int("task-concurrency", server.DefaultTaskConcurrency, "Tasks processed at once per server node; total SMTP concurrency is task-concurrency x workers")
 	pflag.String("redis", "", "Redis nodes (comma-separated, format: host:port)")
 	pflag.String("redis-pass", "", "Redis password")
 	pflag.Int("redis-db", 0, "Redis database number")
-	pflag.String("host", "127.0.0.1", "Server host interface")
+	pflag.String("redlock-nodes", "", "Independent Redis nodes for Redlock distributed locking (comma-separated, format: host:port)")
+	pflag.StringSlice("cors-origins", nil, "Origins allowed for cross-origin API requests (empty or * allows any)")
+	pflag.StringSlice("webhook-allow-hosts", nil, "Hosts (or *.suffix wildcards) webhooks may target (empty allows any public host)")
+	pflag.StringSlice("webhook-allow-networks", nil, "CIDR ranges webhooks may reach although private, loopback or link-local (e.g. 10.0.0.0/8)")
+	pflag.String("host", "0.0.0.0", "Server host interface (127.0.0.1 to accept local connections only)")
 	pflag.String("port", "8080", "Server port")
+	pflag.String("tls-cert", "", "TLS certificate file; serves HTTPS with HTTP/2 when set together with --tls-key")
+	pflag.String("tls-key", "", "TLS private key file")
+	pflag.String("http-redirect", "", "Plain HTTP address redirecting to HTTPS when TLS is enabled (e.g. :80, disabled if empty)")
+	pflag.String("export-s3-endpoint", "", "S3-compatible service URL for task result exports without their own bucket (e.g. https://s3.eu-west-1.amazonaws.com)")
+	pflag.String("export-s3-region", "us-east-1", "Signing region of the export endpoint")
+	pflag.String("export-s3-bucket", "", "Bucket receiving task result exports (exports need their own bucket if empty)")
+	pflag.String("export-s3-prefix", "", "Object key prefix of task result exports")
+	pflag.String("export-s3-access-key", "", "Access key for the export bucket")
+	pflag.String("export-s3-secret-key", "", "Secret key for the export bucket")
+	pflag.String("metrics-addr", "", "Internal host:port serving /metrics, /healthz and /readyz instead of the API listener (e.g. 127.0.0.1:9090)")
+	pflag.Bool("proxy-protocol", false, "Expect a PROXY protocol v1/v2 header on API connections (behind HAProxy or an AWS NLB)")
 	pflag.String("pg-host", "localhost", "PostgreSQL host")
 	pflag.Int("pg-port", 5432, "PostgreSQL port")
 	pflag.String("pg-user", "postgres", "PostgreSQL user")
 	pflag.String("pg-password", "", "PostgreSQL password")
 	pflag.String("pg-db", "email_checker", "PostgreSQL database name")
 	pflag.String("pg-ssl", "disable", "PostgreSQL SSL mode")
+	pflag.Duration("smtp-connect-timeout", smtp.DefaultOptions.ConnectTimeout, "Timeout for establishing SMTP connections")
+	pflag.Duration("smtp-command-timeout", smtp.DefaultOptions.CommandTimeout, "Timeout for SMTP commands")
+	pflag.Int("smtp-max-retries", smtp.DefaultOptions.MaxRetries, "Maximum SMTP attempts per host and port")
+	pflag.Duration("smtp-retry-delay", smtp.DefaultOptions.RetryDelay, "Base delay before an SMTP retry, doubled with jitter for each further retry")
+	pflag.Duration("smtp-retry-max-time", smtp.DefaultOptions.MaxRetryTime, "Time after the first SMTP attempt in which retries may still start (0 for no limit)")
+	pflag.String("smtp-ip-preference", string(smtp.DefaultOptions.IPPreference), "IP family dialed first for MX hosts: any, ipv4 or ipv6")
+	pflag.Bool("smtp-tls-skip-verify", false, "Accept self-signed or mismatched MX certificates on STARTTLS (opportunistic encryption)")
+	pflag.Bool("smtp-tls-fallback", false, "Repeat the check in plaintext when STARTTLS fails")
+	pflag.String("smtp-tls-min-version", "", "Minimum TLS version for MX connections: 1.0, 1.1, 1.2 or 1.3 (empty uses 1.2)")
+	pflag.StringSlice("smtp-ports", smtp.DefaultOptions.Ports, "SMTP ports probed per MX host, in order (465 implicit TLS, 587 STARTTLS)")
+	pflag.Int("smtp-parallel-mx", 0, "MX hosts probed at once per email, first definitive answer wins (0 or 1 keeps strict priority order)")
+	pflag.StringSlice("smtp-probe-hosts", []string{"gmail-smtp-in.l.google.com"}, "Known-good MX hosts probed on port 25 at server startup (empty disables)")
+	pflag.Bool("dry-run", false, "Run syntax, disposable, role and MX checks without connecting to SMTP servers")
+	pflag.Duration("throttle-ttl", throttle.ThrottleTTL, "How long a domain is throttled after temporary SMTP failures")
+	pflag.String("disposable-index-url", disposable.DefaultIndexURL, "JSON array of disposable domains")
+	pflag.String("disposable-wildcard-url", disposable.DefaultWildcardURL, "JSON array of disposable *.suffix wildcards")
+	pflag.String("throttle-state-file", "", "File used to persist domain throttles across restarts (disabled if empty)")
+	pflag.String("runtime-config-file", "", "File persisting settings changed through /admin/config (disabled if empty)")
+	pflag.Duration("throttle-snapshot-interval", time.Minute, "Interval between throttle state snapshots in server mode")
+	pflag.Bool("domain-age", false, "Add the domain registration age (RDAP lookup, adds latency)")
+	pflag.String("mx-override", "", "Probe this host:port instead of the domains' MX records (CLI debugging)")
+	pflag.String("overrides-file", "", "JSON file of known results (email -> report) returned without checking")
+	pflag.StringSlice("allow-domains", nil, "Domains (or *.suffix wildcards) whose mailboxes are assumed to exist without SMTP checks")
+	pflag.String("allow-domains-file", "", "File with allowlisted domains, one per line")
+	pflag.StringSlice("block-domains", nil, "Domains (or *.suffix wildcards) whose mailboxes are reported undeliverable without SMTP checks")
+	pflag.String("block-domains-file", "", "File with blocklisted domains, one per line")
+	pflag.String("otlp-endpoint", "", "OTLP/HTTP collector endpoint for OpenTelemetry traces, e.g. http://localhost:4318 (disabled if empty)")
+	pflag.Int("metrics-domain-limit", metrics.DefaultDomainLabelLimit, "Distinct domains labelled individually in metrics; later domains are reported as \"other\"")
+	pflag.Int("dns-concurrency", mx.DefaultLookupConcurrency, "Maximum concurrent DNS lookups shared by all workers")
+	pflag.String("mx-cache", "shared", "Where MX records are cached in server mode: shared (same backend as email results) or local (process memory)")
+	pflag.Duration("task-ttl", storage.DefaultTaskTTL, "How long tasks and results are kept after the last update")
+	pflag.Bool("strict-email-length", true, "Reject batches containing addresses over 254 characters (false reports them as invalid)")
+	pflag.Int("max-batch-emails", server.DefaultMaxBatchEmails, "Maximum emails per synchronous /check-batch request")
+	pflag.Int("max-task-emails", server.DefaultMaxTaskEmails, "Maximum emails per task")
+	pflag.Int("max-task-emails-monthly", 0, "Maximum emails per task for monthly keys (0 uses --max-task-emails)")
+	pflag.Duration("task-timeout", 0, "Maximum duration of a batch; unfinished emails are reported as not_checked (0 disables)")
+	pflag.Bool("group-by-domain", false, "Process emails of the same domain sequentially (fewer duplicate lookups, lower parallelism)")
 	pflag.Bool("server", false, "Run in server mode")
 	pflag.Bool("version", false, "Show version")
-	pflag.StringSlice("helo-domains", nil, "[REQUIRED] List of HELO domains for SMTP rotation (comma-separated)")
+	pflag.StringSlice("helo-domains", nil, "[REQUIRED] List of HELO domains for SMTP rotation (comma-separated, optional weight as domain:weight)")
+	pflag.StringSlice("helo-domain-map", nil, "HELO domain (and MAIL FROM) used for specific recipient domains instead of the rotation, as target=helo[:sender]")
+	pflag.String("helo-strategy", "round-robin", "HELO domain selection strategy (round-robin, weighted)")
+	pflag.Bool("helo-resolve-check", false, "Skip HELO domains that do not resolve at startup")
 	viper.BindPFlags(pflag.CommandLine)
 	pflag.Parse()
 
@@ -65,19 +136,42 @@ func initViper() {
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 
-	// Read configuration file if available
-	viper.SetConfigName("config")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("/etc/email-checker/")
-	if err := viper.ReadInConfig(); err == nil {
-		log.Println("Using config file:", viper.ConfigFileUsed())
+	// Read configuration file if available and validate all settings before anything starts
+	if err := readConfigFile(); err != nil {
+		log.Fatal(err)
+	}
+	if path := viper.ConfigFileUsed(); path != "" {
+		log.Println("Using config file:", path)
+	}
+	if err := validateConfig(); err != nil {
+		log.Fatal(err)
 	}
 
-	// Monitor for changes in the configuration file
-	viper.WatchConfig()
-	viper.OnConfigChange(func(e fsnotify.Event) {
-		log.Println("Config file changed:", e.Name)
-	})
+}
+
+// smtpOptions builds the SMTP network settings from the configuration
+func smtpOptions() (smtp.Options, error) {
+	ipPreference, err := smtp.ParseIPPreference(viper.GetString("smtp-ip-preference"))
+	if err != nil {
+		return smtp.Options{}, err
+	}
+	tlsMinVersion, err := smtp.ParseTLSVersion(viper.GetString("smtp-tls-min-version"))
+	if err != nil {
+		return smtp.Options{}, err
+	}
+	return smtp.Options{
+		ConnectTimeout: viper.GetDuration("smtp-connect-timeout"),
+		CommandTimeout: viper.GetDuration("smtp-command-timeout"),
+		MaxRetries:     viper.GetInt("smtp-max-retries"),
+		RetryDelay:     viper.GetDuration("smtp-retry-delay"),
+		MaxRetryTime:   viper.GetDuration("smtp-retry-max-time"),
+		IPPreference:   ipPreference,
+		TLSSkipVerify:  viper.GetBool("smtp-tls-skip-verify"),
+		TLSMinVersion:  tlsMinVersion,
+		TLSFallback:    viper.GetBool("smtp-tls-fallback"),
+		Ports:          viper.GetStringSlice("smtp-ports"),
+		ParallelHosts:  viper.GetInt("smtp-parallel-mx"),
+	}, nil
 }
 
 // Main entry point with dual operational modes: CLI and Server
@@ -92,7 +186,18 @@ func main() {
 	}
 
 	throttleManager := throttle.NewThrottleManager(cfg.CacheProvider)
+	throttleManager.SetTTL(viper.GetDuration("throttle-ttl"))
 	smtp.SetThrottleManager(throttleManager)
+	metrics.SetDomainLabelLimit(viper.GetInt("metrics-domain-limit"))
+	disposable.SetSources(viper.GetString("disposable-index-url"), viper.GetString("disposable-wildcard-url"))
+	if path := viper.GetString("throttle-state-file"); path != "" {
+		restoreThrottleState(throttleManager, path) // Avoid re-hammering domains throttled before a restart
+	}
+	smtpOpts, err := smtpOptions()
+	if err != nil {
+		log.Fatalf("Invalid SMTP configuration: %v", err) // Runs before logger.Init, nothing is buffered yet
+	}
+	smtp.SetOptions(smtpOpts)
 
 	// Handle version display request
 	if viper.GetBool("version") {
@@ -117,9 +222,19 @@ func main() {
 	}
 
 	// CLI mode validations
-	if viper.GetString("emails") == "" {
+	switch viper.GetString("format") {
+	case formatJSON, formatJSONL, formatCSV:
+	default:
+		log.Fatalf("Unsupported output format %q (use json, jsonl or csv)", viper.GetString("format"))
+	}
+	switch viper.GetString("fail-on") {
+	case failOnNone, failOnInvalid, failOnUndeliverable:
+	default:
+		log.Fatalf("Unsupported --fail-on policy %q (use none, invalid or undeliverable)", viper.GetString("fail-on"))
+	}
+	if viper.GetString("emails") == "" && viper.GetString("emails-file") == "" {
 		printVersion()
-		log.Fatal("Please specify emails using --emails flag or EMAILS env")
+		log.Fatal("Please specify emails using --emails/--emails-file flags or EMAILS/EMAILS_FILE env")
 	}
 	if len(viper.GetStringSlice("helo-domains")) == 0 {
 		printVersion()
@@ -127,31 +242,154 @@ func main() {
 	}
 
 	// CLI mode execution setup
+	stopTracing := initTracing()
+	defer stopTracing()
 	mx.InitResolver(viper.GetString("dns"))
+	mx.SetLookupConcurrency(viper.GetInt("dns-concurrency"))
 	if err := disposable.Init(); err != nil {
 		log.Fatalf("Failed to initialize disposable checker: %v", err)
 	}
-	logger.Init(false) // Initialize the logger
+	logger.Init(false)   // Initialize the logger
+	defer logger.Flush() // Output buffered log messages before the CLI exits
 
 	// Domains initialise for CLI mode
-	domains.Init(
+	if err := domains.Init(
 		false, // isClusterMode
 		nil,   // redisClient
 		viper.GetStringSlice("helo-domains"),
-	)
+		domains.Strategy(viper.GetString("helo-strategy")),
+		viper.GetBool("helo-resolve-check"),
+	); err != nil {
+		logger.Flush()
+		log.Fatalf("Failed to initialize HELO domains: %v", err)
+	}
+	if err := domains.SetMapping(viper.GetStringSlice("helo-domain-map")); err != nil {
+		logger.Flush()
+		log.Fatalf("Failed to load HELO domain map: %v", err)
+	}
+	// Validate the forced MX host before any checks run
+	if override := viper.GetString("mx-override"); override != "" {
+		if _, _, err := net.SplitHostPort(override); err != nil {
+			logger.Flush()
+			log.Fatalf("Invalid --mx-override %q (expected host:port): %v", override, err)
+		}
+	}
+	overrides, err := loadOverrides(viper.GetString("overrides-file"))
+	if err != nil {
+		logger.Flush()
+		log.Fatalf("Failed to load overrides: %v", err)
+	}
+	allowDomains, blockDomains, err := loadDomainPolicy()
+	if err != nil {
+		logger.Flush()
+		log.Fatalf("Failed to load domain lists: %v", err)
+	}
 	// Process emails with in-memory caching
-	emailList := strings.Split(viper.GetString("emails"), ",")
-	results := checker.ProcessEmailsWithConfig(emailList, checker.Config{
-		MaxWorkers:     viper.GetInt("workers"),
-		CacheProvider:  cache.NewInMemoryCache(),
-		DomainCacheTTL: 24 * time.Hour,
-		ExistTTL:       720 * time.Hour,
-		NotExistTTL:    24 * time.Hour,
+	emailList, err := collectEmails(viper.GetString("emails"), viper.GetString("emails-file"))
+	if err != nil {
+		logger.Flush()
+		log.Fatalf("Failed to read emails: %v", err)
+	}
+	results := checker.StreamEmailsWithConfig(emailList, checker.Config{
+		MaxWorkers:       viper.GetInt("workers"),
+		CacheProvider:    cache.NewInMemoryCache(),
+		DomainCacheTTL:   24 * time.Hour,
+		ExistTTL:         720 * time.Hour,
+		NotExistTTL:      24 * time.Hour,
+		DryRun:           viper.GetBool("dry-run"),
+		GroupByDomain:    viper.GetBool("group-by-domain"),
+		MaxDuration:      viper.GetDuration("task-timeout"),
+		MXOverride:       viper.GetString("mx-override"),
+		DomainAge:        viper.GetBool("domain-age"),
+		Overrides:        overrides,
+		TTLJitter:        viper.GetFloat64("cache-ttl-jitter"),
+		RejectDisposable: viper.GetBool("reject-disposable"),
+		AllowDomains:     allowDomains,
+		BlockDomains:     blockDomains,
 	})
 
-	// Output results as formatted JSON
-	jsonData, _ := json.MarshalIndent(results, "", "  ")
-	fmt.Println(string(jsonData))
+	// Output results in the requested format
+	var summary outcomeSummary
+	if err := writeResults(os.Stdout, viper.GetString("format"), results, summary.add); err != nil {
+		logger.Flush()
+		log.Fatalf("Failed to write results: %v", err)
+	}
+
+	if path := viper.GetString("throttle-state-file"); path != "" {
+		if err := saveThrottleState(throttleManager, path); err != nil {
+			logger.Log(fmt.Sprintf("[WARN] Failed to save throttle state to %s: %v", path, err))
+		}
+	}
+
+	// Apply exit code policy, keeping stdout reserved for results
+	if policy := viper.GetString("fail-on"); summary.failed(policy) {
+		stopTracing() // os.Exit skips deferred calls
+		logger.Flush()
+		fmt.Fprintf(os.Stderr, "Checked %d emails: %d invalid, %d undeliverable (fail-on: %s)\n",
+			summary.total, summary.invalid, summary.undeliverable, policy)
+		os.Exit(1)
+	}
+}
+
+// initTracing exports OpenTelemetry spans when --otlp-endpoint is set
+// The returned function flushes pending spans; it is a no-op when tracing is disabled
+func initTracing() func() {
+	endpoint := viper.GetString("otlp-endpoint")
+	if endpoint == "" {
+		return func() {}
+	}
+	shutdown, err := tracing.Init(context.Background(), endpoint)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	logger.Log(fmt.Sprintf("Exporting traces to %s", endpoint))
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logger.Log(fmt.Sprintf("[WARN] Failed to flush traces: %v", err))
+		}
+	}
+}
+
+// loadOverrides reads known results from path; an empty path disables overrides
+func loadOverrides(path string) (map[string]types.EmailReport, error) {
+	if path == "" {
+		return nil, nil
+	}
+	overrides, err := checker.LoadOverrides(path)
+	if err != nil {
+		return nil, err
+	}
+	logger.Log(fmt.Sprintf("Loaded %d known results from %s", len(overrides), path))
+	return overrides, nil
+}
+
+// loadDomainPolicy builds the allowlist and blocklist from the domain flags and files
+func loadDomainPolicy() (allow, block *checker.DomainList, err error) {
+	load := func(name string) (*checker.DomainList, error) {
+		entries := viper.GetStringSlice(name)
+		if path := viper.GetString(name + "-file"); path != "" {
+			fromFile, err := checker.LoadDomainList(path)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, fromFile...)
+		}
+		if len(entries) == 0 {
+			return nil, nil
+		}
+		list := checker.NewDomainList(entries)
+		logger.Log(fmt.Sprintf("Loaded %d domains for --%s", list.Len(), name))
+		return list, nil
+	}
+	if allow, err = load("allow-domains"); err != nil {
+		return nil, nil, err
+	}
+	if block, err = load("block-domains"); err != nil {
+		return nil, nil, err
+	}
+	return allow, block, nil
 }
 
 // Configures and starts server mode with Redis integration (if presents)
@@ -172,6 +410,12 @@ func startServerMode(host, port, dns, redisNodes, redisPass string, redisDB, max
 		log.Fatal("HELO domains required for server mode")
 	}
 
+	// Task retention must be positive so results are readable at all
+	taskTTL := viper.GetDuration("task-ttl")
+	if taskTTL <= 0 {
+		log.Fatalf("Invalid --task-ttl %v: must be positive", taskTTL)
+	}
+
 	// Redis configuration logic
 	if redisNodes != "" {
 		nodes := strings.Split(redisNodes, ",")
@@ -196,28 +440,74 @@ func startServerMode(host, port, dns, redisNodes, redisPass string, redisDB, max
 			log.Fatalf("Redis connection failed: %v", err)
 		}
 
+		// Configure Redlock over independent Redis nodes if provided
+		if redlockNodes := viper.GetString("redlock-nodes"); redlockNodes != "" {
+			var lockClients []redis.UniversalClient
+			for _, node := range strings.Split(redlockNodes, ",") {
+				lockClients = append(lockClients, redis.NewClient(&redis.Options{
+					Addr:     strings.TrimSpace(node),
+					Password: redisPass,
+				}))
+			}
+			lock.SetRedlockClients(lockClients)
+			logger.Log(fmt.Sprintf("Using Redlock across %d Redis nodes", len(lockClients)))
+		}
+
 		//  Configure Redis-based components: cache and storage
 		cacheProvider = cache.NewRedisCache(redisClient)
-		store = storage.NewRedisStorage(redisClient)
+		redisStore := storage.NewRedisStorage(redisClient)
+		redisStore.SetTaskTTL(taskTTL)
+		store = redisStore
 		logger.Log(fmt.Sprintf("Using Redis storage: %v (cluster: %v)", nodes, isCluster))
 	} else {
 		// Fallback to in-memory storage
 		cacheProvider = cache.NewInMemoryCache()
-		store = storage.NewMemoryStorage(cacheProvider)
+		memoryStore := storage.NewMemoryStorage(cacheProvider)
+		memoryStore.SetTaskTTL(taskTTL)
+		store = memoryStore
 		logger.Log("Using in-memory storage")
 	}
 
 	// Common service initialization DNS resolver and Cache provider
-	domains.Init(isCluster, redisClient, heloDomains)
+	if err := domains.Init(
+		isCluster,
+		redisClient,
+		heloDomains,
+		domains.Strategy(viper.GetString("helo-strategy")),
+		viper.GetBool("helo-resolve-check"),
+	); err != nil {
+		log.Fatalf("Failed to initialize HELO domains: %v", err)
+	}
+	if err := domains.SetMapping(viper.GetStringSlice("helo-domain-map")); err != nil {
+		log.Fatalf("Failed to load HELO domain map: %v", err)
+	}
 	mx.InitResolver(dns)
-	mx.SetCacheProvider(cacheProvider)
+	mx.SetLookupConcurrency(viper.GetInt("dns-concurrency"))
+	// MX records may stay in process memory while email results are shared through Redis
+	var mxCache cache.Provider
+	if viper.GetString("mx-cache") == "local" {
+		mxCache = cache.NewInMemoryCache()
+		mx.SetCacheProvider(mxCache)
+	} else {
+		mx.SetCacheProvider(cacheProvider)
+	}
 
 	// Initialize disposable checker
 	if err := disposable.Init(); err != nil {
 		log.Fatalf("Failed to initialize disposable checker: %v", err)
 	}
 
+	// Probe outbound port 25 in the background so a blocked network is reported early
+	if probeHosts := viper.GetStringSlice("smtp-probe-hosts"); len(probeHosts) > 0 {
+		go func() {
+			if err := smtp.CheckOutboundSMTP(probeHosts, 5*time.Second); err != nil {
+				logger.Log(fmt.Sprintf("[WARN] SMTP self-check failed: %v. All checks will end with temporary errors", err))
+			}
+		}()
+	}
+
 	// Create and start HTTP server
+	buildInfo := server.BuildInfo{Version: Version, CommitHash: CommitHash, BuildTime: BuildTime}
 	server := server.NewServer(
 		host,
 		port,
@@ -228,11 +518,68 @@ func startServerMode(host, port, dns, redisNodes, redisPass string, redisDB, max
 		throttleManager,
 		db,
 	)
-	logger.Log(fmt.Sprintf("Starting server on host %s port %s | DNS: %s | Workers: %d | Redis: %v",
-		host, port, dns, maxWorkers, redisNodes != ""))
+	server.SetBuildInfo(buildInfo)
+	server.SetDryRun(viper.GetBool("dry-run"))
+	overrides, err := loadOverrides(viper.GetString("overrides-file"))
+	if err != nil {
+		log.Fatalf("Failed to load overrides: %v", err)
+	}
+	server.SetOverrides(overrides)
+	server.SetMXCacheProvider(mxCache)
+	allowDomains, blockDomains, err := loadDomainPolicy()
+	if err != nil {
+		log.Fatalf("Failed to load domain lists: %v", err)
+	}
+	applyServerTunables(server, allowDomains, blockDomains)
+	if path := viper.GetString("runtime-config-file"); path != "" {
+		if err := server.SetRuntimeConfigFile(path); err != nil {
+			log.Fatalf("Failed to load runtime config: %v", err)
+		}
+	}
+	server.SetStrictEmailLength(viper.GetBool("strict-email-length"))
+	server.SetPreloadCache(viper.GetBool("preload-cache"))
+	server.SetCORSOrigins(viper.GetStringSlice("cors-origins"))
+	if err := server.SetWebhookPolicy(viper.GetStringSlice("webhook-allow-hosts"), viper.GetStringSlice("webhook-allow-networks")); err != nil {
+		log.Fatalf("Invalid webhook policy: %v", err)
+	}
+	tlsCert, tlsKey := viper.GetString("tls-cert"), viper.GetString("tls-key")
+	if (tlsCert == "") != (tlsKey == "") {
+		log.Fatalf("Invalid TLS configuration: --tls-cert and --tls-key must be set together")
+	}
+	server.SetTLS(tlsCert, tlsKey)
+	if redirect := viper.GetString("http-redirect"); redirect != "" && tlsCert != "" {
+		server.SetHTTPRedirect(redirect)
+	}
+	server.SetMetricsAddr(viper.GetString("metrics-addr"))
+	server.SetProxyProtocol(viper.GetBool("proxy-protocol"))
+	server.SetExportDefaults(types.ExportConfig{
+		Endpoint:  viper.GetString("export-s3-endpoint"),
+		Region:    viper.GetString("export-s3-region"),
+		Bucket:    viper.GetString("export-s3-bucket"),
+		Prefix:    viper.GetString("export-s3-prefix"),
+		AccessKey: viper.GetString("export-s3-access-key"),
+		SecretKey: viper.GetString("export-s3-secret-key"),
+	})
+	if viper.GetBool("dry-run") {
+		logger.Log("[DryRun] SMTP servers will not be contacted and quota will not be charged")
+	}
+	onStop := []func(){initTracing()}
+	if path := viper.GetString("throttle-state-file"); path != "" {
+		persistThrottleState(throttleManager, path, viper.GetDuration("throttle-snapshot-interval"))
+		onStop = append(onStop, func() {
+			if err := saveThrottleState(throttleManager, path); err != nil {
+				logger.Log(fmt.Sprintf("[WARN] Failed to save throttle state to %s: %v", path, err))
+			}
+		})
+	}
+	watchConfig(server, throttleManager)
+	stopped := shutdownOnSignal(server, onStop...)
+	logger.Log(fmt.Sprintf("Starting server on host %s port %s | TLS: %v | DNS: %s | Tasks: %d | Workers per task: %d | Redis: %v",
+		host, port, tlsCert != "", dns, viper.GetInt("task-concurrency"), maxWorkers, redisNodes != ""))
 
 	// Handle potential errors during server startup
 	if err := server.Start(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
+	<-stopped // Start returns as soon as shutdown begins; wait for it to complete
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/shuliakovsky/email-checker/internal/export"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// Supported CLI output formats
+const (
+	formatJSON  = "json"  // Pretty-printed JSON array (buffered)
+	formatJSONL = "jsonl" // One JSON object per line, streamed
+	formatCSV   = "csv"   // CSV with header row, streamed
+)
+
+// Supported --fail-on exit code policies
+const (
+	failOnNone          = "none"          // Always exit 0 after successful processing
+	failOnInvalid       = "invalid"       // Exit 1 if any address has an invalid format
+	failOnUndeliverable = "undeliverable" // Exit 1 if any address is invalid or definitively non-existent
+)
+
+// outcomeSummary tallies verification outcomes for the exit code policy
+type outcomeSummary struct {
+	total         int // Number of processed addresses
+	invalid       int // Addresses with an invalid format
+	undeliverable int // Addresses rejected permanently by SMTP
+}
+
+// add records a single report in the summary
+func (s *outcomeSummary) add(report types.EmailReport) {
+	s.total++
+	if !report.Valid {
+		s.invalid++
+		return
+	}
+	if report.Exists != nil && !*report.Exists && report.PermanentError {
+		s.undeliverable++
+	}
+}
+
+// failed reports whether the summary violates the given --fail-on policy
+func (s *outcomeSummary) failed(policy string) bool {
+	switch policy {
+	case failOnInvalid:
+		return s.invalid > 0
+	case failOnUndeliverable:
+		return s.invalid+s.undeliverable > 0
+	default:
+		return false
+	}
+}
+
+// writeResults consumes reports from the channel and writes them in the requested format
+// Every report is passed to onReport after it has been written
+func writeResults(w io.Writer, format string, results <-chan types.EmailReport, onReport func(types.EmailReport)) error {
+	switch format {
+	case formatJSON:
+		var collected []types.EmailReport
+		for report := range results {
+			collected = append(collected, report)
+			onReport(report)
+		}
+		jsonData, err := json.MarshalIndent(collected, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(jsonData))
+		return err
+
+	case formatJSONL:
+		encoder := json.NewEncoder(w)
+		for report := range results {
+			if err := encoder.Encode(report); err != nil {
+				return err
+			}
+			onReport(report)
+		}
+		return nil
+
+	case formatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(export.CSVHeader); err != nil {
+			return err
+		}
+		for report := range results {
+			if err := writer.Write(export.CSVRow(report)); err != nil {
+				return err
+			}
+			writer.Flush() // Emit rows as soon as they are ready
+			onReport(report)
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("unsupported output format %q", format)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/shuliakovsky/email-checker/internal/checker"
+	"github.com/shuliakovsky/email-checker/internal/domains"
+	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/internal/metrics"
+	"github.com/shuliakovsky/email-checker/internal/server"
+	"github.com/shuliakovsky/email-checker/internal/smtp"
+	"github.com/shuliakovsky/email-checker/internal/throttle"
+)
+
+// reloadableKeys are applied to a running server when the config file changes
+// Any other key is read once at startup and needs a restart to take effect
+var reloadableKeys = map[string]bool{
+	"workers":                 true,
+	"task-concurrency":        true,
+	"task-timeout":            true,
+	"max-batch-emails":        true,
+	"max-task-emails":         true,
+	"max-task-emails-monthly": true,
+	"group-by-domain":         true,
+	"domain-age":              true,
+	"reject-disposable":       true,
+	"cache-ttl-jitter":        true,
+	"allow-domains":           true,
+	"allow-domains-file":      true,
+	"block-domains":           true,
+	"block-domains-file":      true,
+	"helo-domains":            true,
+	"helo-strategy":           true,
+	"helo-domain-map":         true,
+	"helo-resolve-check":      true,
+	"smtp-connect-timeout":    true,
+	"smtp-command-timeout":    true,
+	"smtp-max-retries":        true,
+	"smtp-retry-delay":        true,
+	"smtp-retry-max-time":     true,
+	"smtp-ip-preference":      true,
+	"smtp-tls-skip-verify":    true,
+	"smtp-tls-fallback":       true,
+	"smtp-tls-min-version":    true,
+	"smtp-ports":              true,
+	"smtp-parallel-mx":        true,
+	"throttle-ttl":            true,
+	"metrics-domain-limit":    true,
+}
+
+// applyServerTunables passes the reloadable server settings to srv
+func applyServerTunables(srv *server.Server, allow, block *checker.DomainList) {
+	srv.SetWorkers(viper.GetInt("workers"))
+	srv.SetTaskConcurrency(viper.GetInt("task-concurrency"))
+	srv.SetTaskTimeout(viper.GetDuration("task-timeout"))
+	srv.SetMaxBatchEmails(viper.GetInt("max-batch-emails"))
+	srv.SetTaskLimits(viper.GetInt("max-task-emails"), viper.GetInt("max-task-emails-monthly"))
+	srv.SetGroupByDomain(viper.GetBool("group-by-domain"))
+	srv.SetDomainAge(viper.GetBool("domain-age"))
+	srv.SetRejectDisposable(viper.GetBool("reject-disposable"))
+	srv.SetCacheTTLJitter(viper.GetFloat64("cache-ttl-jitter"))
+	srv.SetDomainPolicy(allow, block)
+}
+
+// watchConfig re-applies the reloadable settings whenever the config file changes
+// A file that fails validation is ignored as a whole and the running settings are kept
+func watchConfig(srv *server.Server, throttleManager *throttle.ThrottleManager) {
+	if viper.ConfigFileUsed() == "" {
+		return // Nothing to watch
+	}
+	var mu sync.Mutex // fsnotify may report several events for one save
+	applied := settingsSnapshot()
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		logger.Log(fmt.Sprintf("[Config] Config file changed: %s", e.Name))
+		if err := reloadConfig(srv, throttleManager); err != nil {
+			logger.Log(fmt.Sprintf("[WARN] Config reload rejected, keeping current settings: %v", err))
+			return
+		}
+		current := settingsSnapshot()
+		if keys := changedKeys(applied, current, false); len(keys) > 0 {
+			logger.Log(fmt.Sprintf("[Config] Reloaded %s", strings.Join(keys, ", ")))
+		}
+		if keys := changedKeys(applied, current, true); len(keys) > 0 {
+			logger.Log(fmt.Sprintf("[WARN] Restart required to apply %s", strings.Join(keys, ", ")))
+		}
+		applied = current
+	})
+	viper.WatchConfig()
+}
+
+// reloadConfig validates the re-read configuration and applies the reloadable settings
+// Everything that can fail runs before the first setting changes
+func reloadConfig(srv *server.Server, throttleManager *throttle.ThrottleManager) error {
+	if err := checkConfigKeys(viper.ConfigFileUsed()); err != nil {
+		return err
+	}
+	if err := validateConfig(); err != nil {
+		return err
+	}
+	smtpOpts, err := smtpOptions()
+	if err != nil {
+		return err
+	}
+	allowDomains, blockDomains, err := loadDomainPolicy()
+	if err != nil {
+		return fmt.Errorf("load domain lists: %w", err)
+	}
+	rotation, err := domains.NewRotation(
+		viper.GetStringSlice("helo-domains"),
+		domains.Strategy(viper.GetString("helo-strategy")),
+		viper.GetBool("helo-resolve-check"),
+	)
+	if err != nil {
+		return err
+	}
+	heloMapping, err := domains.NewMapping(viper.GetStringSlice("helo-domain-map"))
+	if err != nil {
+		return err
+	}
+
+	// Nothing below can fail, so the new settings are applied together
+	rotation.Apply()
+	heloMapping.Apply()
+	smtp.SetOptions(smtpOpts)
+	throttleManager.SetTTL(viper.GetDuration("throttle-ttl"))
+	srv.ReapplyRuntimeOverrides() // Settings changed through /admin/config keep precedence
+	metrics.SetDomainLabelLimit(viper.GetInt("metrics-domain-limit"))
+	applyServerTunables(srv, allowDomains, blockDomains)
+	return nil
+}
+
+// settingsSnapshot captures the effective value of every flag-backed key
+func settingsSnapshot() map[string]string {
+	snapshot := make(map[string]string)
+	pflag.VisitAll(func(f *pflag.Flag) {
+		snapshot[f.Name] = fmt.Sprint(viper.Get(f.Name))
+	})
+	return snapshot
+}
+
+// changedKeys lists the sorted keys whose value differs between two snapshots,
+// restricted to keys that need a restart or to reloadable keys
+func changedKeys(before, after map[string]string, restartRequired bool) []string {
+	var keys []string
+	for key, value := range after {
+		if reloadableKeys[key] == restartRequired || before[key] == value {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/internal/server"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take after SIGINT/SIGTERM
+const shutdownTimeout = 30 * time.Second
+
+// shutdownOnSignal gracefully stops the server on SIGINT/SIGTERM, then runs onStop hooks
+// The returned channel is closed once shutdown has completed
+func shutdownOnSignal(srv *server.Server, onStop ...func()) <-chan struct{} {
+	stopped := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer close(stopped)
+		sig := <-signals
+		logger.Log(fmt.Sprintf("Received %v, shutting down", sig))
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Log(fmt.Sprintf("[WARN] Graceful shutdown incomplete: %v", err))
+		}
+		for _, stop := range onStop {
+			stop()
+		}
+		logger.Flush()
+	}()
+	return stopped
+}
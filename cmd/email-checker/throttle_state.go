@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/internal/throttle"
+)
+
+// restoreThrottleState loads domain throttles saved by a previous run; a missing file is not an error
+func restoreThrottleState(tm *throttle.ThrottleManager, path string) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		logger.Log(fmt.Sprintf("[WARN] Failed to open throttle state %s: %v", path, err))
+		return
+	}
+	defer f.Close()
+
+	restored, err := tm.Restore(f)
+	if err != nil {
+		logger.Log(fmt.Sprintf("[WARN] Failed to restore throttle state from %s: %v", path, err))
+		return
+	}
+	logger.Log(fmt.Sprintf("[Throttle] Restored %d throttled domains from %s", restored, path))
+}
+
+// saveThrottleState writes the active throttles atomically via a temporary file and rename
+func saveThrottleState(tm *throttle.ThrottleManager, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if err := tm.Snapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// persistThrottleState snapshots throttles periodically; the final save happens on shutdown
+func persistThrottleState(tm *throttle.ThrottleManager, path string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := saveThrottleState(tm, path); err != nil {
+				logger.Log(fmt.Sprintf("[WARN] Failed to save throttle state to %s: %v", path, err))
+			}
+		}
+	}()
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-redis/redis/v8"
 	"github.com/jmoiron/sqlx"
+	"github.com/shuliakovsky/email-checker/internal/cache"
 	"github.com/shuliakovsky/email-checker/internal/lock"
 	"github.com/shuliakovsky/email-checker/internal/logger"
 )
@@ -35,17 +36,32 @@ type APIKey struct {
 // AuthService handles API key authentication and quota management
 type AuthService struct {
 	db          *sqlx.DB              // PostgreSQL database connection
-	redis       redis.UniversalClient // Redis client for caching/locking
+	redis       redis.UniversalClient // Redis client for caching/locking (nil in memory-only mode)
+	local       cache.Provider        // In-process key cache used when Redis isn't available
 	clusterMode bool                  // Flag for distributed system operation
 }
 
 // NewAuthService creates a new authentication service instance
+// Without a Redis client, keys are cached in process and quota uses database transactions
 func NewAuthService(db *sqlx.DB, redis redis.UniversalClient, clusterMode bool) *AuthService {
-	return &AuthService{
+	service := &AuthService{
 		db:          db,
 		redis:       redis,
-		clusterMode: clusterMode,
+		clusterMode: clusterMode && redis != nil,
 	}
+	if redis == nil {
+		service.local = cache.NewInMemoryCache()
+	}
+	return service
+}
+
+// InvalidateKey removes cached details of an API key
+func (s *AuthService) InvalidateKey(ctx context.Context, apiKey string) {
+	if s.redis == nil {
+		s.local.Set("apikey:"+apiKey, nil, 0) // Expire the entry immediately
+		return
+	}
+	s.redis.Del(ctx, "apikey:"+apiKey)
 }
 
 // ValidateKey checks API key validity and returns key details
@@ -82,15 +98,29 @@ func (s *AuthService) ValidateKey(ctx context.Context, apiKey string) (*APIKey,
 }
 
 // DecrementQuota reduces available checks count using appropriate concurrency control
-func (s *AuthService) DecrementQuota(ctx context.Context, apiKey string, count int) error {
+// and records the consumption of the task in the usage history
+func (s *AuthService) DecrementQuota(ctx context.Context, apiKey, taskID string, count int) error {
 	if s.clusterMode {
-		return s.decrementWithLock(ctx, apiKey, count) // Distributed lock for clusters
+		return s.decrementWithLock(ctx, apiKey, taskID, count) // Distributed lock for clusters
+	}
+	return s.decrementInTransaction(ctx, apiKey, taskID, count) // Local transaction for single instance
+}
+
+// recordUsage appends a usage history entry for the task
+func recordUsage(ctx context.Context, db sqlx.ExecerContext, apiKey, taskID string, count int) error {
+	_, err := db.ExecContext(ctx, `
+        INSERT INTO api_key_usage (api_key, task_id, checks)
+        VALUES ($1, $2, $3)`,
+		apiKey, taskID, count,
+	)
+	if err != nil {
+		return fmt.Errorf("usage record failed: %v", err)
 	}
-	return s.decrementInTransaction(ctx, apiKey, count) // Local transaction for single instance
+	return nil
 }
 
 // decrementInTransaction updates quota using database transaction
-func (s *AuthService) decrementInTransaction(ctx context.Context, apiKey string, count int) error {
+func (s *AuthService) decrementInTransaction(ctx context.Context, apiKey, taskID string, count int) error {
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -116,6 +146,10 @@ func (s *AuthService) decrementInTransaction(ctx context.Context, apiKey string,
 		return fmt.Errorf("quota exceeded")
 	}
 
+	if err := recordUsage(ctx, tx, apiKey, taskID, count); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("commit failed: %v", err)
 	}
@@ -129,8 +163,20 @@ func (s *AuthService) decrementInTransaction(ctx context.Context, apiKey string,
 	return nil
 }
 
-// getFromCache retrieves API key details from Redis
+// getFromCache retrieves API key details from Redis or the in-process cache
 func (s *AuthService) getFromCache(ctx context.Context, key string) (*APIKey, error) {
+	if s.redis == nil {
+		cached, ok := s.local.Get("apikey:" + key)
+		if !ok {
+			return nil, nil
+		}
+		apiKey, ok := cached.(APIKey)
+		if !ok {
+			return nil, nil
+		}
+		return &apiKey, nil
+	}
+
 	data, err := s.redis.HGetAll(ctx, "apikey:"+key).Result()
 	if err != nil || len(data) == 0 {
 		return nil, err
@@ -147,8 +193,13 @@ func (s *AuthService) getFromCache(ctx context.Context, key string) (*APIKey, er
 	}, nil
 }
 
-// cacheKey stores API key details in Redis with TTL
+// cacheKey stores API key details in Redis or the in-process cache
 func (s *AuthService) cacheKey(ctx context.Context, key *APIKey) error {
+	if s.redis == nil {
+		s.local.Set("apikey:"+key.Key, *key, cacheTTL) // Store a copy to avoid shared mutation
+		return nil
+	}
+
 	fields := map[string]interface{}{
 		"type":           key.Type,
 		"used_checks":    key.UsedChecks,
@@ -190,9 +241,9 @@ func (s *AuthService) getFromDB(ctx context.Context, apiKey string) (*APIKey, er
 }
 
 // decrementWithLock uses distributed lock and atomic Redis operations
-func (s *AuthService) decrementWithLock(ctx context.Context, apiKey string, count int) error {
+func (s *AuthService) decrementWithLock(ctx context.Context, apiKey, taskID string, count int) error {
 	lockKey := "lock:apikey:" + apiKey
-	lock := lock.NewLock(s.redis, lockKey, 10*time.Second, true)
+	lock := lock.NewClusterLock(s.redis, lockKey, 10*time.Second, true)
 
 	if !lock.Acquire(ctx) {
 		return fmt.Errorf("failed to acquire lock")
@@ -215,21 +266,35 @@ func (s *AuthService) decrementWithLock(ctx context.Context, apiKey string, coun
         return redis.call('HGETALL', key)
     `
 
-	_, err := s.redis.Eval(ctx, script, []string{"apikey:" + apiKey}, count, cacheTTL.Seconds()).Result()
+	// The quota update and its usage record are committed together, and only once Redis accepted the decrement
+	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// Synchronize with PostgreSQL database
-	_, err = s.db.ExecContext(ctx, `
+	_, err = tx.ExecContext(ctx, `
         UPDATE api_keys 
         SET used_checks = used_checks + $1,
             remaining_checks = remaining_checks - $1
         WHERE api_key = $2`,
 		count, apiKey,
 	)
+	if err != nil {
+		return fmt.Errorf("update failed: %v", err)
+	}
+	if err := recordUsage(ctx, tx, apiKey, taskID, count); err != nil {
+		return err
+	}
 
-	return err
+	if _, err := s.redis.Eval(ctx, script, []string{"apikey:" + apiKey}, count, cacheTTL.Seconds()).Result(); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		s.redis.Del(ctx, "apikey:"+apiKey) // The cached counters are ahead of the database; reload them on next use
+		return fmt.Errorf("commit failed: %v", err)
+	}
+	return nil
 }
 
 // parseInt converts string to integer with error suppression
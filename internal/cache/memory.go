@@ -1,11 +1,11 @@
 package cache
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unsafe"
 
 	"github.com/shuliakovsky/email-checker/internal/logger"
 	"github.com/shuliakovsky/email-checker/internal/metrics"
@@ -22,7 +22,7 @@ type Provider interface {
 // Stats contains statistical data about the cache
 type Stats struct {
 	Items  int   // Number of items currently stored in the cache
-	Memory int64 // Total memory used by the cache (in bytes)
+	Memory int64 // Estimated memory used by the cache entries (in bytes, -1 if unknown)
 	Hits   int64 // Number of successful retrievals (cache hits)
 	Misses int64 // Number of failed retrievals (cache misses)
 }
@@ -31,6 +31,7 @@ type Stats struct {
 type InMemoryCache struct {
 	mu          sync.RWMutex         // Mutex for thread-safe access to the cache
 	items       map[string]cacheItem // Map holding the cache items
+	memory      int64                // Estimated size of all items, maintained by Set and Flush
 	statsHits   int64                // Counter for successful cache hits
 	statsMisses int64                // Counter for failed cache misses
 }
@@ -39,6 +40,33 @@ type InMemoryCache struct {
 type cacheItem struct {
 	value    interface{} // The stored value of the cache item
 	expireAt time.Time   // The expiration time for the cache item
+	size     int64       // Estimated memory of the entry, see entrySize
+}
+
+// entryOverhead approximates the fixed cost of an entry: map slot, key and item headers
+const entryOverhead = 64
+
+// entrySize estimates the memory held by an entry: its key, its value and entryOverhead
+// Values other than strings, byte slices and scalars are measured by their JSON encoding,
+// so the figure is an approximation of the Go heap usage, not an exact count
+func entrySize(key string, value interface{}) int64 {
+	size := int64(entryOverhead + len(key))
+	switch v := value.(type) {
+	case nil:
+	case string:
+		size += int64(len(v))
+	case []byte:
+		size += int64(len(v))
+	case bool, int, int64, uint64, float64, time.Duration:
+		size += 8
+	case time.Time:
+		size += 24
+	default:
+		if data, err := json.Marshal(v); err == nil {
+			size += int64(len(data))
+		}
+	}
+	return size
 }
 
 // NewInMemoryCache creates and initializes a new instance of InMemoryCache
@@ -67,13 +95,20 @@ func (c *InMemoryCache) Get(key string) (interface{}, bool) {
 
 // Set adds a new cache item or updates an existing one with the given key, value, and TTL
 func (c *InMemoryCache) Set(key string, value interface{}, ttl time.Duration) {
+	size := entrySize(key, value) // Measured before locking, encoding may take a while
+
 	c.mu.Lock()         // Acquire a write lock
 	defer c.mu.Unlock() // Release the write lock when the function exits
 
+	if old, ok := c.items[key]; ok {
+		c.memory -= old.size // The replaced entry is released
+	}
 	c.items[key] = cacheItem{
 		value:    value,               // Store the provided value
 		expireAt: time.Now().Add(ttl), // Calculate and set the expiration time based on the provided TTL
+		size:     size,                // Account the entry in the memory estimate
 	}
+	c.memory += size
 }
 
 // Flush clears all items from the cache
@@ -82,6 +117,7 @@ func (c *InMemoryCache) Flush() {
 	defer c.mu.Unlock()                                              // Release the write lock when the function exits
 	logger.Log(fmt.Sprintf("Flushing %d cache items", len(c.items))) // Log the number of items being flushed
 	c.items = make(map[string]cacheItem)                             // Reset the map to clear all cache entries
+	c.memory = 0                                                     // Nothing left to account for
 }
 
 // GetStats retrieves the current statistics of the cache
@@ -91,7 +127,7 @@ func (c *InMemoryCache) GetStats() Stats {
 
 	return Stats{
 		Items:  len(c.items),                     // Count the number of items in the cache
-		Memory: int64(unsafe.Sizeof(c.items)),    // Calculate the memory usage of the cache (approximation)
+		Memory: c.memory,                         // Estimated size of the stored entries, expired ones included until replaced
 		Hits:   atomic.LoadInt64(&c.statsHits),   // Retrieve the number of successful cache hits
 		Misses: atomic.LoadInt64(&c.statsMisses), // Retrieve the number of cache misses
 	}
@@ -1,33 +1,131 @@
 package checker
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"math/rand"
 	"net"
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute" // Trace span attributes
+	"golang.org/x/sync/singleflight"     // Shares in-flight verifications of the same email
+
 	"github.com/shuliakovsky/email-checker/internal/cache"      // Handles cache operations
 	"github.com/shuliakovsky/email-checker/internal/disposable" // Checks disposable email domains
 	"github.com/shuliakovsky/email-checker/internal/logger"     // Provides logging capabilities
 	"github.com/shuliakovsky/email-checker/internal/metrics"    // Prometheus metrics
 	"github.com/shuliakovsky/email-checker/internal/mx"         // Retrieves MX records
+	"github.com/shuliakovsky/email-checker/internal/rdap"       // Looks up domain registration dates
 	"github.com/shuliakovsky/email-checker/internal/smtp"       // Handles SMTP checks
 	"github.com/shuliakovsky/email-checker/internal/throttle"   // ThrottleManager functionalities
+	"github.com/shuliakovsky/email-checker/internal/tracing"    // OpenTelemetry spans
 	"github.com/shuliakovsky/email-checker/pkg/types"           // Defines custom types, like EmailReport
 )
 
 // Config holds the configuration settings for email processing
 type Config struct {
-	MaxWorkers      int                       // Maximum number of concurrent workers
-	CacheProvider   cache.Provider            // Cache implementation to store processed data
-	DomainCacheTTL  time.Duration             // TTL for domain-related cache entries
-	ExistTTL        time.Duration             // TTL for existing emails (e.g., 30 days)
-	NotExistTTL     time.Duration             // TTL for non-existing emails (e.g., 24 hours)
-	ThrottleManager *throttle.ThrottleManager // ThrottleManager implementation
+	MaxWorkers       int                          // Maximum number of concurrent workers
+	CacheProvider    cache.Provider               // Cache implementation to store processed data
+	MXCacheProvider  cache.Provider               // Separate cache for MX records (CacheProvider if nil)
+	DomainCacheTTL   time.Duration                // TTL for domain-related cache entries
+	ExistTTL         time.Duration                // TTL for existing emails (e.g., 30 days)
+	NotExistTTL      time.Duration                // TTL for non-existing emails (e.g., 24 hours)
+	ThrottleManager  *throttle.ThrottleManager    // ThrottleManager implementation
+	ScoreWeights     ScoreWeights                 // Confidence score weighting (zero value uses DefaultScoreWeights)
+	DryRun           bool                         // Skip SMTP connections and record planned probes instead
+	GroupByDomain    bool                         // Process emails of the same domain sequentially on one worker
+	MaxDuration      time.Duration                // Overall deadline for a batch; remaining emails are reported as not checked (0 disables)
+	MXOverride       string                       // Probe this "host:port" instead of the domain's MX records (debugging)
+	DomainAge        bool                         // Look up the domain registration age over RDAP (adds an external call)
+	RequestID        string                       // Correlation ID attached to worker and SMTP log lines
+	SkipDisposable   bool                         // Skip the disposable provider lookup
+	SkipRole         bool                         // Skip role-based mailbox detection
+	Overrides        map[string]types.EmailReport // Predetermined reports by normalized email, returned without any lookup
+	TTLJitter        float64                      // Random +/- fraction applied to cache TTLs so entries don't expire together (0 disables)
+	RejectDisposable bool                         // Report disposable addresses as undeliverable without DNS/SMTP checks
+	AllowDomains     *DomainList                  // Domains whose mailboxes are assumed to exist without SMTP checks
+	BlockDomains     *DomainList                  // Domains whose mailboxes are reported undeliverable without SMTP checks
+	MaxAge           time.Duration                // Cached reports checked longer ago than this are verified again (0 accepts any age)
+	SkipCache        bool                         // Ignore cached reports; the fresh result still replaces the cached one
+}
+
+// DefaultTTLJitter spreads cache expirations by +/-10%
+const DefaultTTLJitter = 0.1
+
+// jitterTTL randomizes ttl by up to +/- TTLJitter of its length
+func (cfg Config) jitterTTL(ttl time.Duration) time.Duration {
+	if cfg.TTLJitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	spread := time.Duration(float64(ttl) * math.Min(cfg.TTLJitter, 1))
+	if spread <= 0 {
+		return ttl
+	}
+	return ttl - spread + time.Duration(rand.Int63n(int64(2*spread)+1))
+}
+
+// mxCache returns the cache holding MX records
+func (cfg Config) mxCache() cache.Provider {
+	if cfg.MXCacheProvider != nil {
+		return cfg.MXCacheProvider
+	}
+	return cfg.CacheProvider
+}
+
+// cachedReport returns the cached report of an email unless SkipCache is set
+func (cfg Config) cachedReport(normalizedEmail string) (types.EmailReport, bool) {
+	if cfg.SkipCache {
+		return types.EmailReport{}, false
+	}
+	cached, ok := cfg.CacheProvider.Get(normalizedEmail)
+	if !ok {
+		return types.EmailReport{}, false
+	}
+	report, ok := cached.(types.EmailReport)
+	return report, ok
+}
+
+// acceptsCached reports whether a cached report is recent enough to be returned
+// Reports cached before check times were recorded count as stale once MaxAge is set
+func (cfg Config) acceptsCached(report types.EmailReport) bool {
+	return cfg.MaxAge <= 0 || !report.CheckedAt.IsZero() && time.Since(report.CheckedAt) <= cfg.MaxAge
+}
+
+// logContext returns a context carrying the request ID for correlated log lines
+func (cfg Config) logContext() context.Context {
+	return logger.WithRequestID(context.Background(), cfg.RequestID)
+}
+
+// smtpContext tags ctx with the request ID for SMTP log lines, keeping its deadline and trace span
+func (cfg Config) smtpContext(ctx context.Context) context.Context {
+	return logger.WithRequestID(ctx, cfg.RequestID)
 }
 
+// inflight collapses concurrent verifications of the same email across workers
+var inflight singleflight.Group
+
+// activeWorkers counts email workers currently running across all batches
+var activeWorkers atomic.Int64
+
+// ActiveWorkers returns the number of email workers currently running
+func ActiveWorkers() int {
+	return int(activeWorkers.Load())
+}
+
+// NotChecked is the error category of emails skipped or cut short because the batch deadline passed
+const NotChecked = smtp.NotChecked
+
+// NonPublicLiteral is the MX error category of domain literals naming a loopback, private or other internal address
+const NonPublicLiteral = "non_public_literal"
+
+// resultsPerWorker is how many finished reports each worker may have waiting for the consumer
+const resultsPerWorker = 4
+
 // DefaultConfig provides default settings for email processing
 var (
 	DefaultConfig = Config{
@@ -36,34 +134,132 @@ var (
 		DomainCacheTTL: 24 * time.Hour,           // Cache domain details for 24 hours
 		ExistTTL:       720 * time.Hour,          // Cache existing emails for 30 days
 		NotExistTTL:    24 * time.Hour,           // Cache non-existing emails for 24 hours
+		ScoreWeights:   DefaultScoreWeights,      // Balanced confidence score weighting
+		TTLJitter:      DefaultTTLJitter,         // Spread cache expirations by +/-10%
 	}
 )
 
 // ProcessEmailsWithConfig processes a list of emails using the provided configuration
 func ProcessEmailsWithConfig(emails []string, cfg Config) []types.EmailReport {
-	jobs := make(chan string, len(emails))               // Channel to store jobs (emails to process)
-	results := make(chan types.EmailReport, len(emails)) // Channel to store results
+	return ProcessEmailsWithContext(context.Background(), emails, cfg)
+}
+
+// ProcessEmailsWithContext processes a list of emails until done or until ctx is cancelled
+func ProcessEmailsWithContext(ctx context.Context, emails []string, cfg Config) []types.EmailReport {
+	collected := make([]types.EmailReport, 0, len(emails))
+	ProcessEmailsFunc(ctx, emails, cfg, func(report types.EmailReport) {
+		collected = append(collected, report)
+	})
+	return collected
+}
+
+// ProcessEmailsFunc is ProcessEmailsWithContext handing each report to fn as soon as it is ready
+// instead of collecting them, so callers persisting reports as they go keep memory bounded
+// fn is called from a single goroutine; slow callbacks pause the workers rather than buffer results
+func ProcessEmailsFunc(ctx context.Context, emails []string, cfg Config, fn func(types.EmailReport)) {
+	for report := range StreamEmailsWithContext(ctx, emails, cfg) {
+		fn(report)
+	}
+}
+
+// StreamEmailsWithConfig processes emails and delivers reports as soon as they are ready
+// The returned channel is closed once every email has been processed
+func StreamEmailsWithConfig(emails []string, cfg Config) <-chan types.EmailReport {
+	return StreamEmailsWithContext(context.Background(), emails, cfg)
+}
+
+// StreamEmailsWithContext is StreamEmailsWithConfig bounded by ctx and cfg.MaxDuration
+// Once either expires, emails not yet started are reported with the NotChecked category,
+// so every input email still yields exactly one report
+// The channel buffers a few reports per worker; callers must drain it or the workers block
+func StreamEmailsWithContext(ctx context.Context, emails []string, cfg Config) <-chan types.EmailReport {
+	cancel := context.CancelFunc(func() {})
+	if cfg.MaxDuration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.MaxDuration)
+	}
+
+	// Warm MX caches for all distinct domains while the workers already check emails
+	// Lookups of a domain both are resolving at once are shared, so nothing is queried twice
+	prefetchCtx, stopPrefetch := context.WithCancel(ctx)
+	if cfg.MXOverride == "" {
+		go mx.PrefetchDomains(prefetchCtx, distinctDomains(emails), cfg.MaxWorkers)
+	}
+
+	groups := jobGroups(emails, cfg.GroupByDomain)
+	jobs := make(chan []string, len(groups)) // Channel to store jobs (groups of emails to process)
+	// Channel to store results, bounded so a slow consumer pauses the workers
+	results := make(chan types.EmailReport, resultsBuffer(len(emails), cfg.MaxWorkers))
 
 	var wg sync.WaitGroup
 	wg.Add(cfg.MaxWorkers)
 
 	// Start worker goroutines
 	for i := 0; i < cfg.MaxWorkers; i++ {
-		go worker(jobs, results, &wg, cfg)
+		go worker(ctx, jobs, results, &wg, cfg)
 	}
 
 	// Submit jobs to workers
-	for _, email := range emails {
-		jobs <- strings.TrimSpace(email) // Trim spaces before processing
+	for _, group := range groups {
+		jobs <- group
 	}
 	close(jobs)
 
 	// Wait for workers to finish and close the results channel
 	go func() {
 		wg.Wait()
+		stopPrefetch() // Domains not prefetched yet are no longer needed
+		cancel()
 		close(results)
 	}()
-	return collectResults(results)
+	return results
+}
+
+// jobGroups splits emails into units of work for the workers
+// With groupByDomain every domain becomes one group processed sequentially by a single worker,
+// so later addresses reuse the MX cache and throttle state populated by earlier ones.
+// This avoids duplicate DNS lookups and parallel handshakes against one server, at the cost of
+// throughput being bounded by the number of distinct domains and large domains finishing last.
+// Without it each email is its own group and all workers share the load evenly.
+func jobGroups(emails []string, groupByDomain bool) [][]string {
+	if !groupByDomain {
+		groups := make([][]string, 0, len(emails))
+		for _, email := range emails {
+			groups = append(groups, []string{strings.TrimSpace(email)}) // Trim spaces before processing
+		}
+		return groups
+	}
+
+	index := make(map[string]int)
+	var groups [][]string
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		_, domain := splitAddress(strings.ToLower(email))
+		i, ok := index[domain]
+		if !ok {
+			i = len(groups)
+			index[domain] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], email)
+	}
+	return groups
+}
+
+// distinctDomains returns the unique lowercase hostname domains of the given emails
+func distinctDomains(emails []string) []string {
+	seen := make(map[string]struct{})
+	var unique []string
+	for _, email := range emails {
+		_, domain := splitAddress(strings.ToLower(strings.TrimSpace(email)))
+		if domain == "" || strings.HasPrefix(domain, "[") {
+			continue // Domain literals have no MX records
+		}
+		if _, ok := seen[domain]; !ok {
+			seen[domain] = struct{}{}
+			unique = append(unique, domain)
+		}
+	}
+	return unique
 }
 
 // ProcessEmails is a shortcut for processing emails using default settings
@@ -72,124 +268,436 @@ func ProcessEmails(emails []string) []types.EmailReport {
 }
 
 // Worker processes emails using cache and SMTP validation
-func worker(jobs <-chan string, results chan<- types.EmailReport, wg *sync.WaitGroup, cfg Config) {
+func worker(ctx context.Context, jobs <-chan []string, results chan<- types.EmailReport, wg *sync.WaitGroup, cfg Config) {
 	defer wg.Done() // Signal worker completion
+	activeWorkers.Add(1)
+	defer activeWorkers.Add(-1)
 
-	for email := range jobs {
-		// Normalize email address
-		normalizedEmail := strings.ToLower(strings.TrimSpace(email))
-		logger.Log(fmt.Sprintf("[Worker] Processing: %s", normalizedEmail))
+	for group := range jobs {
+		for _, email := range group {
+			if ctx.Err() != nil {
+				results <- notCheckedReport(email) // Deadline passed, drain remaining jobs
+				continue
+			}
+			results <- CheckEmailContext(ctx, email, cfg)
+		}
+	}
+}
 
-		// Check if the email exists in cache
-		if cached, ok := cfg.CacheProvider.Get(normalizedEmail); ok {
-			logger.Log(fmt.Sprintf("[Cache] Hit for: %s", normalizedEmail))
-			results <- cached.(types.EmailReport) // Use cached data
-			continue
+// notCheckedReport builds the placeholder report for an email skipped after the deadline
+func notCheckedReport(email string) types.EmailReport {
+	return types.EmailReport{
+		Email:         NormalizeEmail(email),
+		ErrorCategory: NotChecked,
+	}
+}
+
+// CountNotChecked returns how many reports were skipped because the deadline passed
+func CountNotChecked(reports []types.EmailReport) int {
+	skipped := 0
+	for _, report := range reports {
+		if report.ErrorCategory == NotChecked {
+			skipped++
 		}
+	}
+	return skipped
+}
+
+// CheckEmail verifies a single email address using the cache and SMTP validation
+func CheckEmail(email string, cfg Config) types.EmailReport {
+	return CheckEmailContext(context.Background(), email, cfg)
+}
+
+// CheckEmailContext is CheckEmail recording its trace spans under the span in ctx
+// The deadline of ctx bounds the SMTP verification; an email it cuts short is reported with the NotChecked
+// category and not cached
+func CheckEmailContext(ctx context.Context, email string, cfg Config) types.EmailReport {
+	// Normalize email address
+	normalizedEmail := NormalizeEmail(email)
+	logger.LogContext(cfg.logContext(), fmt.Sprintf("[Worker] Processing: %s", normalizedEmail))
 
-		// Process the email and generate a report
-		report := processEmail(normalizedEmail, cfg)
-		// Process metrics
+	_, domain := splitAddress(normalizedEmail)
+	ctx, span := tracing.Start(ctx, "email.check", attribute.String("email.domain", domain))
+	defer span.End()
+
+	// Known results bypass the cache and all network checks
+	if report, ok := cfg.override(normalizedEmail); ok {
+		logger.LogContext(cfg.logContext(), fmt.Sprintf("[Override] Known result for: %s", normalizedEmail))
 		metrics.EmailsChecked.Inc()
-		results <- report
+		recordOutcome(report, false)
+		return report
+	}
 
-		// Cache the result with an appropriate TTL
-		ttl := cfg.NotExistTTL
-		if report.Exists != nil && *report.Exists { // Adjust TTL for existing emails
-			ttl = cfg.ExistTTL
+	// Check if the email exists in cache (fresh checks, overridden MX hosts, policy domains and reports older than MaxAge skip it)
+	// Throttling still applies to fresh checks: it is enforced by the verification itself
+	if cached, ok := cfg.cachedReport(normalizedEmail); ok && cfg.MXOverride == "" && cfg.domainPolicy(normalizedEmail) == "" &&
+		cfg.acceptsCached(cached) {
+		logger.LogContext(cfg.logContext(), fmt.Sprintf("[Cache] Hit for: %s", normalizedEmail))
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		report := withoutSkippedChecks(cached, cfg) // Use cached data
+		if cfg.RejectDisposable && report.Disposable {
+			report = rejectedDisposable(report, cfg) // Cached full checks still honour the rejection
 		}
-		cfg.CacheProvider.Set(normalizedEmail, report, ttl)
+		recordOutcome(report, true)
+		return report
+	}
+
+	// Concurrent checks of the same email share one verification instead of all missing the cache
+	leader := false
+	shared, _, _ := inflight.Do(cfg.inflightKey(normalizedEmail), func() (interface{}, error) {
+		leader = true
+		return verifyAndCache(ctx, normalizedEmail, cfg), nil
+	})
+	report := shared.(types.EmailReport)
+	if !leader {
+		recordOutcome(report, true) // Waiters got the result without doing the work
+	}
+	return report
+}
+
+// verifyAndCache processes an email and caches the report with an outcome-dependent TTL
+func verifyAndCache(ctx context.Context, normalizedEmail string, cfg Config) types.EmailReport {
+	// Process the email and generate a report
+	report := processEmail(ctx, normalizedEmail, cfg)
+	if report.ErrorCategory == NotChecked {
+		return notCheckedReport(normalizedEmail) // No verdict to count or cache
+	}
+	report.CheckedAt = time.Now().UTC()
+	// Process metrics
+	metrics.EmailsChecked.Inc()
+	recordOutcome(report, false)
+
+	cacheReport(report, cfg)
+	return report
+}
+
+// cacheReport stores a fresh report with an outcome-dependent TTL
+func cacheReport(report types.EmailReport, cfg Config) {
+	// Dry-run, MX override, partial, rejected and policy reports are never cached so later full checks aren't shadowed
+	if cfg.DryRun || cfg.MXOverride != "" || cfg.SkipDisposable || cfg.SkipRole || cfg.RejectDisposable && report.Disposable ||
+		cfg.domainPolicy(report.Email) != "" {
+		return
+	}
+
+	// Cache the result with an appropriate TTL
+	ttl := cfg.NotExistTTL
+	if report.Exists != nil && *report.Exists { // Adjust TTL for existing emails
+		ttl = cfg.ExistTTL
 	}
+	cfg.CacheProvider.Set(report.Email, report, cfg.jitterTTL(ttl))
+}
+
+// inflightKey identifies checks that produce identical reports and may share one verification
+func (cfg Config) inflightKey(normalizedEmail string) string {
+	return fmt.Sprintf("%s|%t|%s|%t|%t|%t|%t", normalizedEmail,
+		cfg.DryRun, cfg.MXOverride, cfg.SkipDisposable, cfg.SkipRole, cfg.DomainAge, cfg.RejectDisposable)
 }
 
 // processEmail performs validation, domain checks, and SMTP verification for an email
-func processEmail(email string, cfg Config) types.EmailReport {
-	logger.Log(fmt.Sprintf("[Processing] Email: %s", email))
+func processEmail(ctx context.Context, email string, cfg Config) types.EmailReport {
+	logger.LogContext(cfg.logContext(), fmt.Sprintf("[Processing] Email: %s", email))
 	report := types.EmailReport{Email: email}
 
 	// Validate email format
-	if !isValidEmail(email) {
+	if reason := validateEmail(email); reason != "" {
 		report.Valid = false
+		report.ErrorCategory = reason
+		report.PermanentError = true
+		report.Score, report.Risk = scoreReport(report, cfg.ScoreWeights)
 		return report
 	}
 	report.Valid = true
 
 	// Extract domain from the email address
-	parts := strings.Split(email, "@")
-	domain := parts[1]
+	_, domain := splitAddress(email)
+
+	// Check if the domain is disposable and the mailbox role-based unless switched off
+	if !cfg.SkipDisposable {
+		report.Disposable = disposable.IsDisposable(domain)
+	}
+	if !cfg.SkipRole {
+		report.Role = isRoleAddress(email)
+	}
+	if policy := cfg.domainPolicy(email); policy != "" {
+		return withDomainPolicy(report, policy, cfg)
+	}
+	if cfg.RejectDisposable && report.Disposable {
+		return rejectedDisposable(report, cfg)
+	}
+
+	// Optionally add the domain age; lookups are cached and failures leave the field empty
+	if cfg.DomainAge && !strings.HasPrefix(domain, "[") {
+		// Bounded by the task deadline as well, so a slow registry cannot outlive the check
+		rdapCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if days, ok, err := rdap.DomainAgeDays(rdapCtx, domain); err != nil {
+			logger.LogContext(cfg.logContext(), fmt.Sprintf("[RDAP] Domain age lookup for %s failed: %v", domain, err))
+		} else if ok {
+			report.DomainAgeDays = days
+		}
+		cancel()
+	}
+
+	// A forced MX host skips the lookup entirely
+	if cfg.MXOverride != "" {
+		return checkOverrideHost(ctx, report, domain, cfg)
+	}
+
+	// Resolve mail hosts; the MX section is filled in with whatever was discovered
+	mxRecords := resolveMailHosts(ctx, &report, domain, cfg)
+
+	// In dry-run mode record the probes SMTP validation would perform and stop
+	if cfg.DryRun {
+		report.PlannedProbes = smtp.PlannedProbes(mxRecords)
+		report.Score, report.Risk = scoreReport(report, cfg.ScoreWeights)
+		return report
+	}
 
-	// Check if the domain is disposable
-	report.Disposable = disposable.IsDisposable(domain)
+	// Registered verifiers go first, even without mail hosts; SMTP validation runs if none decided and
+	// a usable mail host was found
+	smtpCtx := cfg.smtpContext(ctx)
+	res, decided := smtp.Verify(smtpCtx, email, mxRecords)
+	if !decided && len(mxRecords) > 0 {
+		res, _ = smtp.SMTPVerifier.Verify(smtpCtx, email, mxRecords)
+		decided = true
+	}
+	if decided {
+		report.Exists = &res.Exists
+		report.SMTPError = res.Err
+		report.ErrorCategory = res.Category
+		report.PermanentError = res.Permanent
+		report.TTL = res.TTL
+		report.RetryAfter = smtp.RetryAfter(domain) // Tell callers when a throttled domain can be retried
+		report.ThrottledUntil = smtp.ThrottledUntil(domain)
+		report.RetryScheduled = smtp.RetryScheduled(email)
+	}
+
+	// Combine the collected signals into a confidence score
+	report.Score, report.Risk = scoreReport(report, cfg.ScoreWeights)
+	return report
+}
 
-	// Retrieve MX records with caching
-	var mxRecords []*net.MX
-	if cached, ok := cfg.CacheProvider.Get("mx:" + domain); ok {
-		mxRecords = cached.([]*net.MX) // Use cached MX records
+// rejectedDisposable marks a disposable address as undeliverable, skipping deeper checks
+func rejectedDisposable(report types.EmailReport, cfg Config) types.EmailReport {
+	exists := false
+	report.Exists = &exists
+	report.ErrorCategory = "disposable"
+	report.PermanentError = true
+	report.Score, report.Risk = scoreReport(report, cfg.ScoreWeights)
+	return report
+}
+
+// withDomainPolicy records the allowlist or blocklist verdict for a domain, skipping deeper checks
+func withDomainPolicy(report types.EmailReport, policy string, cfg Config) types.EmailReport {
+	exists := policy == policyAllow
+	report.Exists = &exists
+	if !exists {
+		report.ErrorCategory = "blocked_domain"
+		report.PermanentError = true
+	}
+	report.Score, report.Risk = scoreReport(report, cfg.ScoreWeights)
+	return report
+}
+
+// withoutSkippedChecks clears the signals of checks switched off in cfg and rescores the report
+func withoutSkippedChecks(report types.EmailReport, cfg Config) types.EmailReport {
+	if !cfg.SkipDisposable && !cfg.SkipRole {
+		return report
+	}
+	if cfg.SkipDisposable {
+		report.Disposable = false
+	}
+	if cfg.SkipRole {
+		report.Role = false
+	}
+	report.Score, report.Risk = scoreReport(report, cfg.ScoreWeights)
+	return report
+}
+
+// resolveMailHosts populates the MX section of the report and returns the hosts to probe
+// Lookup errors are recorded next to whatever was discovered: a domain without MX records
+// falls back to its own A/AAAA records (implicit MX, RFC 5321 section 5.1), while a null MX
+// (RFC 7505) is reported but never probed
+func resolveMailHosts(ctx context.Context, report *types.EmailReport, domain string, cfg Config) []*net.MX {
+	var records []*net.MX
+	if ip, ok := domainLiteralIP(domain); ok && !PublicIP(ip) {
+		// Probing the literal would let clients make the server connect to internal hosts
+		report.MX.Error = fmt.Sprintf("domain literal %s is not a public address", ip)
+		report.MX.ErrorCategory = NonPublicLiteral
+	} else if ok {
+		records = []*net.MX{{Host: ip.String()}} // Domain literals are delivered to the address itself
+	} else if cached, ok := cfg.mxCache().Get("mx:" + domain); ok {
+		records = cached.([]*net.MX) // Use cached MX records
 		logger.Log(fmt.Sprintf("[Cache] MX for %s", domain))
+	} else if found, err := lookupMX(ctx, domain); err != nil {
+		report.MX.Error = err.Error() // Keep the categorized error and continue with fallbacks
+		report.MX.ErrorCategory = mx.ErrorCategory(err)
 	} else {
-		records, err := mx.GetMXRecords(domain)
-		if err != nil {
-			report.MX.Error = err.Error() // Log the error and return the report
-			return report
-		}
-		mxRecords = records
-		cfg.CacheProvider.Set("mx:"+domain, mxRecords, cfg.DomainCacheTTL)
+		records = found
+		cfg.mxCache().Set("mx:"+domain, records, cfg.jitterTTL(cfg.DomainCacheTTL))
 	}
 
-	// Populate MX data in the report
-	report.MX.Valid = len(mxRecords) > 0
-	for _, record := range mxRecords {
+	var usable []*net.MX
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Host, ".")
 		report.MX.Records = append(report.MX.Records, types.MXRecord{
-			Host:     strings.TrimSuffix(record.Host, "."),
+			Host:     host,
 			Priority: record.Pref,
 			TTL:      calculateTTL(record.Pref),
 		})
+		if host != "" { // A "." host is a null MX: the domain accepts no mail
+			usable = append(usable, record)
+		}
 	}
+	report.MX.Valid = len(usable) > 0
 
-	// Perform SMTP validation if MX records are valid
-	if report.MX.Valid {
-		exists, smtpErr, category, permanent, ttl := smtp.CheckEmailExists(email, mxRecords)
-		report.Exists = &exists
-		report.SMTPError = smtpErr
-		report.ErrorCategory = category
-		report.PermanentError = permanent
-		report.TTL = ttl
+	switch {
+	case len(records) > 0 && len(usable) == 0:
+		report.MX.ErrorCategory = mx.CategoryNullMX
+	case len(records) == 0 && report.MX.ErrorCategory == "":
+		report.MX.ErrorCategory = mx.CategoryNoMX
 	}
 
-	// Save the report in cache even if SMTP validation wasn't performed
-	cfg.CacheProvider.Set(email, report, cfg.ExistTTL)
+	// Without MX records mail is delivered to the domain's address records
+	if len(usable) == 0 && report.MX.ErrorCategory == mx.CategoryNoMX {
+		if implicit, err := mx.ImplicitMX(domain); err == nil {
+			report.MX.Valid = true // The domain accepts mail, so missing MX records are not an error
+			report.MX.Error = ""
+			report.MX.ErrorCategory = ""
+			report.MX.Implicit = true
+			report.MX.Records = append(report.MX.Records, types.MXRecord{Host: domain})
+			usable = implicit
+		}
+	}
+	return usable
+}
+
+// lookupMX resolves the MX records of a domain inside an mx.lookup span
+func lookupMX(ctx context.Context, domain string) ([]*net.MX, error) {
+	_, span := tracing.Start(ctx, "mx.lookup", attribute.String("mx.domain", domain))
+	defer span.End()
+	records, err := mx.GetMXRecords(domain)
+	if err != nil {
+		tracing.Fail(span, mx.ErrorCategory(err))
+	}
+	span.SetAttributes(attribute.Int("mx.records", len(records)))
+	return records, err
+}
+
+// checkOverrideHost verifies an email against the configured MX override instead of the published MX records
+func checkOverrideHost(ctx context.Context, report types.EmailReport, domain string, cfg Config) types.EmailReport {
+	host, port, err := net.SplitHostPort(cfg.MXOverride)
+	if err != nil {
+		report.MX.Error = fmt.Sprintf("invalid MX override %q: %v", cfg.MXOverride, err)
+		report.Score, report.Risk = scoreReport(report, cfg.ScoreWeights)
+		return report
+	}
+	report.MX.Valid = true
+	report.MX.Records = []types.MXRecord{{Host: host}}
+
+	if cfg.DryRun {
+		report.PlannedProbes = []string{net.JoinHostPort(host, port)}
+		report.Score, report.Risk = scoreReport(report, cfg.ScoreWeights)
+		return report
+	}
+
+	exists, smtpErr, category, permanent, ttl := smtp.CheckEmailAtHostContext(cfg.smtpContext(ctx), report.Email, host, port)
+	report.Exists = &exists
+	report.SMTPError = smtpErr
+	report.ErrorCategory = category
+	report.PermanentError = permanent
+	report.TTL = ttl
+	report.RetryAfter = smtp.RetryAfter(domain)
+	report.ThrottledUntil = smtp.ThrottledUntil(domain)
+	report.RetryScheduled = smtp.RetryScheduled(report.Email)
+	report.Score, report.Risk = scoreReport(report, cfg.ScoreWeights)
 	return report
 }
 
-// isValidEmail checks if an email address has a valid format
-func isValidEmail(email string) bool {
-	const pattern = `(?i)^(?:[a-z0-9!#$%&'*+/=?^_{|}~-]+` +
-		`(?:\.[a-z0-9!#$%&'*+/=?^_{|}~-]+)*` +
-		`|"(?:[\x01-\x08\x0b\x0c\x0e-\x1f\x21\x23-\x5b\x5d-\x7f]|\
-\[\x01-\x09\x0b\x0c\x0e-\x7f])*")` +
-		`@(?:(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+` +
-		`[a-z]{2,}|
-\[(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}` +
-		`(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\]
-|IPv6:[\da-f:]+\]
-)$`
+// emailRegex validates address syntax; compiled once since it runs for every email
+// Supported grammar follows the RFC 5321 Mailbox production:
+//   - local part: dot-atom, or a quoted string of printable ASCII and space with backslash escapes
+//   - domain: dotted hostname with an alphabetic TLD, an IPv4 literal [192.0.2.1]
+//     or an IPv6 literal [IPv6:2001:db8::1] (checked further by domainLiteralIP)
+//
+// Comments, folding whitespace, obsolete syntax and internationalized addresses are not accepted
+var emailRegex = regexp.MustCompile(`(?i)^(?:[a-z0-9!#$%&'*+/=?^_{|}~-]+` +
+	`(?:\.[a-z0-9!#$%&'*+/=?^_{|}~-]+)*` +
+	`|"(?:[\x20\x21\x23-\x5b\x5d-\x7e]|\\[\x20-\x7e])*")` +
+	`@(?:(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}` +
+	`|\[(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\]` +
+	`|\[IPv6:[\da-f:.]+\])$`)
+
+// Address length limits (RFC 5321 section 4.5.3.1, RFC 3696 errata)
+const (
+	maxAddressLength     = 254 // Maximum length of the whole address
+	maxLocalPartLength   = 64  // Maximum length of the local part
+	maxDomainLength      = 255 // Maximum length of the domain
+	maxDomainLabelLength = 63  // Maximum length of a single domain label
+)
+
+// validateEmail checks the address format and length limits
+// Returns an empty string for valid addresses or the error category explaining the rejection
+func validateEmail(email string) string {
+	// Part limits come first: the total cap would otherwise hide which part is too long
+	local, domain := splitAddress(email)
+	if len(local) > maxLocalPartLength {
+		return "local_part_too_long"
+	}
+	if len(domain) > maxDomainLength {
+		return "domain_too_long"
+	}
+	if !strings.HasPrefix(domain, "[") {
+		for _, label := range strings.Split(domain, ".") {
+			if len(label) > maxDomainLabelLength {
+				return "domain_label_too_long"
+			}
+		}
+	}
+	if len(email) > maxAddressLength {
+		return "address_too_long"
+	}
+
+	if !emailRegex.MatchString(email) {
+		return "invalid_format"
+	}
 
-	// Check the overall length (RFC 3696)
-	if len(email) > 254 {
-		return false
+	// IPv6 literals need a full address parse beyond the regex character check
+	if strings.HasPrefix(strings.ToLower(domain), "[ipv6:") {
+		if _, ok := domainLiteralIP(domain); !ok {
+			return "invalid_format"
+		}
 	}
+	return ""
+}
 
-	re := regexp.MustCompile(pattern)
-	return re.MatchString(email)
+// splitAddress splits an email into local part and domain at the last '@'
+// The last '@' is used because quoted local parts may contain '@' themselves
+func splitAddress(email string) (string, string) {
+	idx := strings.LastIndex(email, "@")
+	if idx == -1 {
+		return email, ""
+	}
+	return email[:idx], email[idx+1:]
 }
 
-// collectResults gathers results from the results channel into a slice
-func collectResults(results <-chan types.EmailReport) []types.EmailReport {
-	var collected []types.EmailReport
-	for res := range results {
-		collected = append(collected, res)
+// domainLiteralIP returns the IP address of a domain literal such as [192.0.2.1] or [IPv6:2001:db8::1]
+func domainLiteralIP(domain string) (net.IP, bool) {
+	if !strings.HasPrefix(domain, "[") || !strings.HasSuffix(domain, "]") {
+		return nil, false
 	}
-	return collected
+	literal := domain[1 : len(domain)-1]
+	if len(literal) > 5 && strings.EqualFold(literal[:5], "IPv6:") {
+		ip := net.ParseIP(literal[5:])
+		return ip, ip != nil && ip.To4() == nil
+	}
+	ip := net.ParseIP(literal)
+	return ip, ip != nil && ip.To4() != nil
+}
+
+// resultsBuffer sizes the results channel: enough to keep workers busy while the consumer
+// handles a report, without holding the reports of a whole large batch
+func resultsBuffer(emails, workers int) int {
+	return min(emails, max(workers, 1)*resultsPerWorker)
 }
 
 // calculateTTL estimates TTL based on MX record priority
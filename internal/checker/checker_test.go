@@ -0,0 +1,226 @@
+package checker
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/cache"
+	"github.com/shuliakovsky/email-checker/internal/mx"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.0.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"ff02::1", false},
+		{"100.64.0.1", false},
+		{"240.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := PublicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("PublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestDomainLiteralsOfInternalAddressesAreNotProbed(t *testing.T) {
+	for _, domain := range []string{
+		"[127.0.0.1]",
+		"[10.0.0.5]",
+		"[192.168.1.1]",
+		"[169.254.169.254]",
+		"[0.0.0.0]",
+		"[224.0.0.1]",
+		"[IPv6:::1]",
+		"[IPv6:fe80::1]",
+	} {
+		t.Run(domain, func(t *testing.T) {
+			report := types.EmailReport{Email: "user@" + domain}
+			hosts := resolveMailHosts(context.Background(), &report, domain, Config{})
+			if len(hosts) != 0 {
+				t.Fatalf("hosts = %v, want none", hosts)
+			}
+			if report.MX.Valid || report.MX.ErrorCategory != NonPublicLiteral {
+				t.Fatalf("MX = %+v, want invalid with category %s", report.MX, NonPublicLiteral)
+			}
+		})
+	}
+}
+
+func TestDomainLiteralOfPublicAddressIsProbed(t *testing.T) {
+	for domain, want := range map[string]string{
+		"[8.8.8.8]":                   "8.8.8.8",
+		"[IPv6:2001:4860:4860::8888]": "2001:4860:4860::8888",
+	} {
+		report := types.EmailReport{Email: "user@" + domain}
+		hosts := resolveMailHosts(context.Background(), &report, domain, Config{})
+		if len(hosts) != 1 || hosts[0].Host != want {
+			t.Fatalf("%s: hosts = %v, want %s", domain, hosts, want)
+		}
+		if !report.MX.Valid || report.MX.ErrorCategory != "" {
+			t.Fatalf("%s: MX = %+v, want valid", domain, report.MX)
+		}
+	}
+}
+
+func TestSkipCacheReplacesCachedReport(t *testing.T) {
+	const email = "user@invalid_domain"
+	provider := cache.NewInMemoryCache()
+	stale := types.EmailReport{Email: email, Valid: true, CheckedAt: time.Now().Add(-time.Hour).UTC()}
+	provider.Set(email, stale, time.Hour)
+	cfg := Config{CacheProvider: provider, ExistTTL: time.Hour, NotExistTTL: time.Hour}
+
+	if report := CheckEmailContext(context.Background(), email, cfg); !report.Valid {
+		t.Fatalf("check without SkipCache = %+v, want the cached report", report)
+	}
+
+	cfg.SkipCache = true
+	report := CheckEmailContext(context.Background(), email, cfg)
+	if report.Valid || report.ErrorCategory != "invalid_format" {
+		t.Fatalf("fresh check = %+v, want a new invalid_format report", report)
+	}
+
+	cached, ok := provider.Get(email)
+	if !ok {
+		t.Fatal("fresh report was not cached")
+	}
+	if updated := cached.(types.EmailReport); updated.Valid || !updated.CheckedAt.After(stale.CheckedAt) {
+		t.Fatalf("cached report = %+v, want the fresh one", updated)
+	}
+}
+
+// domainOfLength returns a valid domain of exactly n characters made of labels up to 63 characters
+func domainOfLength(n int) string {
+	var labels []string
+	for rest := n - len(".com"); rest > 0; {
+		size := min(maxDomainLabelLength, rest)
+		if rest-size == 1 {
+			size-- // A dot must be followed by another label
+		}
+		labels = append(labels, strings.Repeat("a", size))
+		rest -= size + 1
+	}
+	return strings.Join(labels, ".") + ".com"
+}
+
+func TestValidateEmailLengthLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		want  string
+	}{
+		{"label of 63", "user@" + strings.Repeat("a", 63) + ".com", ""},
+		{"label of 64", "user@" + strings.Repeat("a", 64) + ".com", "domain_label_too_long"},
+		{"local part of 64", strings.Repeat("l", 64) + "@example.com", ""},
+		{"local part of 65", strings.Repeat("l", 65) + "@example.com", "local_part_too_long"},
+		{"address of 253", "user@" + domainOfLength(248), ""},
+		{"address of 254", "user@" + domainOfLength(249), ""},
+		{"address of 255", "user@" + domainOfLength(250), "address_too_long"},
+		{"domain of 255", "u@" + domainOfLength(255), "address_too_long"},
+		{"domain of 256", "u@" + domainOfLength(256), "domain_too_long"},
+		{"long label in long address", strings.Repeat("l", 60) + "@" + strings.Repeat("a", 64) + "." + domainOfLength(150), "domain_label_too_long"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateEmail(tt.email); got != tt.want {
+				t.Fatalf("validateEmail(%d chars) = %q, want %q", len(tt.email), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateEmailFormat(t *testing.T) {
+	valid := []string{
+		"user@example.com",
+		"first.last@example.co.uk",
+		"user+tag@sub.example.org",
+		"o'brien@example.ie",
+		"x@a-b.example.com",
+		"!#$%&'*+/=?^_{|}~-@example.com",
+		"USER@EXAMPLE.COM",
+		`"john doe"@example.com`,
+		`"quoted@at"@example.com`,
+		`"escaped\"quote"@example.com`,
+		"user@[192.0.2.1]",
+		"user@[IPv6:2001:db8::1]",
+	}
+	for _, email := range valid {
+		if got := validateEmail(email); got != "" {
+			t.Errorf("validateEmail(%q) = %q, want valid", email, got)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"plainaddress",
+		"@example.com",
+		"user@",
+		"user@@example.com",
+		"user@example",
+		"user@example.c",
+		".user@example.com",
+		"user.@example.com",
+		"us..er@example.com",
+		"us er@example.com",
+		"user@exa mple.com",
+		"user@-example.com",
+		"user@example-.com",
+		"user@example..com",
+		"user@example.123",
+		"user(comment)@example.com",
+		`"unterminated@example.com`,
+		"user@[256.0.0.1]",
+		"user@[192.0.2]",
+		"user@[IPv6:not-an-address]",
+		"user@[IPv6:2001:db8::1::2]",
+		"üser@example.com",
+	}
+	for _, email := range invalid {
+		if got := validateEmail(email); got != "invalid_format" {
+			t.Errorf("validateEmail(%q) = %q, want invalid_format", email, got)
+		}
+	}
+}
+
+func TestImplicitMXFallbackIsValid(t *testing.T) {
+	provider := cache.NewInMemoryCache()
+	provider.Set("mx:localhost", []*net.MX{}, time.Hour) // No MX records; localhost has an address record
+	report := types.EmailReport{Email: "user@localhost"}
+
+	hosts := resolveMailHosts(context.Background(), &report, "localhost", Config{CacheProvider: provider})
+	if len(hosts) != 1 || hosts[0].Host != "localhost" {
+		t.Fatalf("hosts = %v, want the domain itself", hosts)
+	}
+	if !report.MX.Valid || !report.MX.Implicit || report.MX.ErrorCategory != "" || report.MX.Error != "" {
+		t.Fatalf("MX = %+v, want a valid implicit MX without error", report.MX)
+	}
+}
+
+func TestNullMXHasNoFallback(t *testing.T) {
+	provider := cache.NewInMemoryCache()
+	provider.Set("mx:localhost", []*net.MX{{Host: "."}}, time.Hour)
+	report := types.EmailReport{Email: "user@localhost"}
+
+	hosts := resolveMailHosts(context.Background(), &report, "localhost", Config{CacheProvider: provider})
+	if len(hosts) != 0 || report.MX.Valid || report.MX.Implicit || report.MX.ErrorCategory != mx.CategoryNullMX {
+		t.Fatalf("hosts = %v, MX = %+v, want a null MX without fallback", hosts, report.MX)
+	}
+}
@@ -0,0 +1,93 @@
+package checker
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Domain policy verdicts
+const (
+	policyAllow = "allow" // Mailbox assumed to exist without SMTP verification
+	policyBlock = "block" // Mailbox reported undeliverable without SMTP verification
+)
+
+// DomainList matches domains against exact entries and "*.suffix" wildcards
+// A wildcard matches subdomains of the suffix, not the suffix itself
+type DomainList struct {
+	exact     map[string]struct{}
+	wildcards []string // Suffixes including the leading dot
+}
+
+// NewDomainList builds a list from domain entries; blank entries are ignored
+func NewDomainList(entries []string) *DomainList {
+	list := &DomainList{exact: make(map[string]struct{})}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, "*."):
+			list.wildcards = append(list.wildcards, entry[1:])
+		default:
+			list.exact[entry] = struct{}{}
+		}
+	}
+	return list
+}
+
+// LoadDomainList reads one domain per line; empty lines and lines starting with # are skipped
+func LoadDomainList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries, scanner.Err()
+}
+
+// Len returns the number of entries in the list
+func (l *DomainList) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.exact) + len(l.wildcards)
+}
+
+// Match reports whether the lowercase domain is on the list; a nil list matches nothing
+func (l *DomainList) Match(domain string) bool {
+	if l == nil || domain == "" {
+		return false
+	}
+	if _, ok := l.exact[domain]; ok {
+		return true
+	}
+	for _, suffix := range l.wildcards {
+		if strings.HasSuffix(domain, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainPolicy returns the verdict configured for an email's domain ("" when neither list matches)
+// The blocklist wins when a domain is on both lists
+func (cfg Config) domainPolicy(normalizedEmail string) string {
+	_, domain := splitAddress(normalizedEmail)
+	switch {
+	case cfg.BlockDomains.Match(domain):
+		return policyBlock
+	case cfg.AllowDomains.Match(domain):
+		return policyAllow
+	}
+	return ""
+}
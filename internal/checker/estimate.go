@@ -0,0 +1,52 @@
+package checker
+
+import (
+	"strings"
+
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// NormalizeEmail returns the form under which an address is cached, deduplicated and charged
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// EstimateCost computes the quota a list consumes without checking it
+// Repeated addresses are charged once; with excludeInvalid, addresses failing syntax validation
+// are left out of the chargeable count and listed so the caller can drop them before submitting
+func EstimateCost(emails []string, excludeInvalid bool) types.QuotaEstimate {
+	estimate := types.QuotaEstimate{Emails: len(emails)}
+	seen := make(map[string]struct{}, len(emails))
+	for _, email := range emails {
+		normalized := NormalizeEmail(email)
+		if _, ok := seen[normalized]; ok {
+			estimate.Duplicates++
+			continue
+		}
+		seen[normalized] = struct{}{}
+		if validateEmail(normalized) != "" {
+			estimate.Invalid++
+			estimate.InvalidEmails = append(estimate.InvalidEmails, email)
+			if excludeInvalid {
+				continue
+			}
+		}
+		estimate.Chargeable++
+	}
+	if !excludeInvalid {
+		estimate.InvalidEmails = nil // Only listed when they are meant to be removed
+	}
+	return estimate
+}
+
+// CountCharged returns the number of checks charged for reports: each distinct address once,
+// not_checked reports are free
+func CountCharged(reports []types.EmailReport) int {
+	seen := make(map[string]struct{}, len(reports))
+	for _, report := range reports {
+		if report.ErrorCategory != NotChecked {
+			seen[NormalizeEmail(report.Email)] = struct{}{}
+		}
+	}
+	return len(seen)
+}
@@ -0,0 +1,68 @@
+package checker
+
+import (
+	"strconv"
+
+	"github.com/shuliakovsky/email-checker/internal/metrics"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// Verification outcomes used as the metric label; each report maps to exactly one
+const (
+	OutcomeInvalid       = "invalid"       // Address failed syntax validation
+	OutcomeDisposable    = "disposable"    // Valid address at a disposable provider
+	OutcomeExists        = "exists"        // SMTP server accepted the recipient
+	OutcomeUndeliverable = "undeliverable" // SMTP server permanently rejected the recipient
+	OutcomeUnknown       = "unknown"       // No definitive answer (no MX, temporary errors, throttling, dry run)
+)
+
+// Outcome classifies a report into a single verification outcome
+// Precedence is invalid, disposable, exists, undeliverable, unknown so ratios add up to 100%
+func Outcome(report types.EmailReport) string {
+	switch {
+	case !report.Valid:
+		return OutcomeInvalid
+	case report.Disposable:
+		return OutcomeDisposable
+	case report.Exists != nil && *report.Exists:
+		return OutcomeExists
+	case report.Exists != nil && report.PermanentError:
+		return OutcomeUndeliverable
+	default:
+		return OutcomeUnknown
+	}
+}
+
+// recordOutcome counts a finished check by outcome and cache source
+// Labels are fixed enums to keep cardinality bounded
+func recordOutcome(report types.EmailReport, cacheHit bool) {
+	metrics.EmailOutcomes.WithLabelValues(Outcome(report), strconv.FormatBool(cacheHit)).Inc()
+}
+
+// Summarize groups reports into deliverability classes for task summaries
+// Disposable and unknown outcomes count as risky; not_checked reports are left out
+func Summarize(reports []types.EmailReport) types.TaskSummary {
+	var summary types.TaskSummary
+	for _, report := range reports {
+		AddToSummary(&summary, report)
+	}
+	return summary
+}
+
+// AddToSummary counts one report in summary, for callers tallying results as they arrive
+// Reports not checked before the deadline are not counted
+func AddToSummary(summary *types.TaskSummary, report types.EmailReport) {
+	if report.ErrorCategory == NotChecked {
+		return
+	}
+	switch Outcome(report) {
+	case OutcomeExists:
+		summary.Deliverable++
+	case OutcomeUndeliverable:
+		summary.Undeliverable++
+	case OutcomeInvalid:
+		summary.Invalid++
+	default:
+		summary.Risky++
+	}
+}
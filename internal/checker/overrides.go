@@ -0,0 +1,37 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// LoadOverrides reads predetermined reports from a JSON file mapping email to report
+// Keys are normalized like checked emails; a report's email field is always set to its key
+func LoadOverrides(path string) (map[string]types.EmailReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]types.EmailReport
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("decode overrides %s: %w", path, err)
+	}
+
+	overrides := make(map[string]types.EmailReport, len(raw))
+	for email, report := range raw {
+		normalized := strings.ToLower(strings.TrimSpace(email))
+		report.Email = normalized
+		overrides[normalized] = report
+	}
+	return overrides, nil
+}
+
+// override returns the predetermined report for an email, if any
+func (cfg Config) override(normalizedEmail string) (types.EmailReport, bool) {
+	report, ok := cfg.Overrides[normalizedEmail]
+	return report, ok
+}
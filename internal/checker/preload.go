@@ -0,0 +1,45 @@
+package checker
+
+import (
+	"time"
+
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// PreloadTask caches the results of a completed task so they are reused instead of re-probed
+// Each report is cached for what remains of its regular TTL since the task completed;
+// stale, skipped, dry-run, rejected and partial-check results are left out, as are emails already cached
+// Returns the number of reports cached
+func PreloadTask(task *types.Task, cfg Config) int {
+	if task.Status != "completed" && task.Status != "completed_partial" {
+		return 0
+	}
+	if task.Checks != nil && (task.Checks.Disposable != nil && !*task.Checks.Disposable || task.Checks.Role != nil && !*task.Checks.Role) {
+		return 0 // Reports without every check would shadow full checks
+	}
+	completed := task.CompletedAt
+	if completed.IsZero() {
+		completed = task.CreatedAt // Tasks stored before completion times were recorded
+	}
+	age := time.Since(completed)
+
+	preloaded := 0
+	for _, report := range task.Results {
+		if report.ErrorCategory == NotChecked || report.ErrorCategory == "disposable" || len(report.PlannedProbes) > 0 {
+			continue
+		}
+		ttl := cfg.NotExistTTL
+		if report.Exists != nil && *report.Exists {
+			ttl = cfg.ExistTTL
+		}
+		if ttl -= age; ttl <= 0 {
+			continue
+		}
+		if _, ok := cfg.CacheProvider.Get(report.Email); ok {
+			continue // A newer verdict is already cached
+		}
+		cfg.CacheProvider.Set(report.Email, report, cfg.jitterTTL(ttl))
+		preloaded++
+	}
+	return preloaded
+}
@@ -0,0 +1,41 @@
+package checker
+
+import "net"
+
+// reservedNetworks are special-purpose ranges not covered by the net.IP predicates
+var reservedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // "This network"
+	"100.64.0.0/10", // Carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // Benchmarking
+	"240.0.0.0/4",   // Reserved
+)
+
+// PublicIP reports whether ip is a globally reachable unicast address
+// Loopback, private, link-local, unspecified, multicast and reserved addresses are not, so connections the
+// server makes on behalf of clients (SMTP probes of domain literals, webhooks) cannot reach internal services
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// mustParseCIDRs parses constant CIDR ranges
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,57 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// RecheckDue re-verifies emails whose scheduled retry became due and refreshes their cached reports
+// Emails that fail temporarily again are rescheduled with the next attempt until MaxRetries is reached
+// Returns the number of emails re-checked
+func RecheckDue(cfg Config) int {
+	if cfg.ThrottleManager == nil {
+		return 0
+	}
+	due := cfg.ThrottleManager.DueRetries()
+	if len(due) == 0 {
+		return 0
+	}
+
+	workers := cfg.MaxWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, retry := range due {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			logger.Log(fmt.Sprintf("[Retry] Re-checking %s (attempt %d)", retry.Email, retry.Attempt))
+			// Bypass the cached temporary failure; the fresh report replaces it
+			shared, _, _ := inflight.Do(cfg.inflightKey(retry.Email), func() (interface{}, error) {
+				return verifyAndCache(context.Background(), retry.Email, cfg), nil
+			})
+			report := shared.(types.EmailReport)
+			if report.ErrorCategory == "temporary" {
+				cfg.ThrottleManager.ScheduleRetry(retry.Email, retry.Attempt+1)
+			} else {
+				cfg.ThrottleManager.CancelRetry(retry.Email) // Drop the first-attempt retry the SMTP layer queued
+			}
+			// The SMTP layer always schedules a first attempt; cache the final decision instead
+			if scheduled := cfg.ThrottleManager.RetryScheduled(retry.Email); scheduled != report.RetryScheduled {
+				report.RetryScheduled = scheduled
+				cacheReport(report, cfg)
+			}
+		}()
+	}
+	wg.Wait()
+	return len(due)
+}
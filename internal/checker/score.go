@@ -0,0 +1,98 @@
+package checker
+
+import (
+	"strings"
+
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// Risk levels derived from the confidence score
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// ScoreWeights defines how verification signals contribute to the 0-100 confidence score
+// Positive weights are added when the signal is present, penalties are subtracted
+type ScoreWeights struct {
+	Syntax            int // Points for a syntactically valid address
+	MX                int // Points for a domain with valid MX records
+	SMTPAccepted      int // Points when the SMTP server accepted the recipient
+	SMTPInconclusive  int // Points when SMTP verification ended with a temporary error
+	DisposablePenalty int // Penalty for disposable email providers
+	RolePenalty       int // Penalty for role-based mailboxes (e.g. info@, support@)
+	LowRiskMin        int // Minimum score classified as low risk
+	MediumRiskMin     int // Minimum score classified as medium risk
+}
+
+// DefaultScoreWeights provides a balanced weighting favouring SMTP acceptance
+var DefaultScoreWeights = ScoreWeights{
+	Syntax:            10,
+	MX:                20,
+	SMTPAccepted:      70,
+	SMTPInconclusive:  30,
+	DisposablePenalty: 50,
+	RolePenalty:       15,
+	LowRiskMin:        80,
+	MediumRiskMin:     50,
+}
+
+// roleLocalParts lists common role-based mailbox names
+var roleLocalParts = map[string]struct{}{
+	"admin": {}, "administrator": {}, "billing": {}, "contact": {}, "help": {},
+	"hostmaster": {}, "info": {}, "marketing": {}, "noreply": {}, "no-reply": {},
+	"office": {}, "postmaster": {}, "sales": {}, "security": {}, "support": {},
+	"team": {}, "webmaster": {},
+}
+
+// isRoleAddress reports whether the local part of an email is a role-based mailbox
+func isRoleAddress(email string) bool {
+	local, _ := splitAddress(email)
+	local = strings.ToLower(local)
+	_, ok := roleLocalParts[local]
+	return ok
+}
+
+// scoreReport computes the confidence score and risk level for a report
+func scoreReport(report types.EmailReport, weights ScoreWeights) (int, string) {
+	if weights == (ScoreWeights{}) {
+		weights = DefaultScoreWeights // Unset weights fall back to defaults
+	}
+
+	score := 0
+	if report.Valid {
+		score += weights.Syntax
+	}
+	if report.MX.Valid {
+		score += weights.MX
+	}
+	if report.Exists != nil && *report.Exists {
+		score += weights.SMTPAccepted
+	} else if report.Exists != nil && !report.PermanentError && report.ErrorCategory != "" {
+		score += weights.SMTPInconclusive
+	}
+	if report.Disposable {
+		score -= weights.DisposablePenalty
+	}
+	if report.Role {
+		score -= weights.RolePenalty
+	}
+
+	// Clamp score into the 0-100 range
+	if score < 0 {
+		score = 0
+	}
+	if score > 100 {
+		score = 100
+	}
+
+	switch {
+	case score >= weights.LowRiskMin:
+		return score, RiskLow
+	case score >= weights.MediumRiskMin:
+		return score, RiskMedium
+	default:
+		return score, RiskHigh
+	}
+}
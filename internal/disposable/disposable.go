@@ -11,11 +11,26 @@ import (
 )
 
 const (
-	indexURL    = "https://raw.githubusercontent.com/tompec/disposable-email-domains/main/index.json"    // URL to fetch a list of precise disposable domains
-	wildcardURL = "https://raw.githubusercontent.com/tompec/disposable-email-domains/main/wildcard.json" // URL to fetch wildcard disposable domains
-	timeout     = 10 * time.Second                                                                       // Timeout for HTTP requests
+	DefaultIndexURL    = "https://raw.githubusercontent.com/tompec/disposable-email-domains/main/index.json"    // URL to fetch a list of precise disposable domains
+	DefaultWildcardURL = "https://raw.githubusercontent.com/tompec/disposable-email-domains/main/wildcard.json" // URL to fetch wildcard disposable domains
+	timeout            = 10 * time.Second                                                                       // Timeout for HTTP requests
 )
 
+var (
+	indexURL    = DefaultIndexURL    // Source of precise domains (JSON array)
+	wildcardURL = DefaultWildcardURL // Source of wildcard domains (JSON array of "*.suffix")
+)
+
+// SetSources replaces the domain list URLs; empty values keep the defaults. Call before Init
+func SetSources(index, wildcard string) {
+	if index != "" {
+		indexURL = index
+	}
+	if wildcard != "" {
+		wildcardURL = wildcard
+	}
+}
+
 var (
 	domains     []string            // Slice to store precise disposable domains
 	domainSet   map[string]struct{} // Set for fast lookup of precise domains
@@ -3,12 +3,42 @@ package domains
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/internal/metrics"
 )
 
-var domainsList []string
+// Strategy defines how the next HELO domain is selected
+type Strategy string
+
+const (
+	StrategyRoundRobin Strategy = "round-robin" // Cycle through domains in order, honouring weights
+	StrategyWeighted   Strategy = "weighted"    // Pick domains randomly in proportion to their weights
+)
+
+// heloDomain is a rotation entry with its relative selection weight
+type heloDomain struct {
+	name   string // Domain used in HELO/EHLO and MAIL FROM
+	weight int    // Relative share of selections (>= 1)
+}
+
+var (
+	rotationMu  sync.RWMutex // Guards domainsList and strategy, which Reload replaces at runtime
+	domainsList []heloDomain
+)
+
+// hostnamePattern matches a fully qualified domain name made of RFC 1123 labels
+var hostnamePattern = regexp.MustCompile(`(?i)^(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
 
 // Counter interface for sequence generation
 type Counter interface {
@@ -39,11 +69,30 @@ func (c *RedisCounter) Next() (uint64, error) {
 var (
 	// Active counter implementation (memory or Redis)
 	counter Counter
+
+	// Active selection strategy
+	strategy Strategy
+
+	// Domains temporarily excluded from rotation after errors
+	cooldowns struct {
+		sync.RWMutex
+		until map[string]time.Time
+	}
 )
 
 // Initialize counter based on deployment mode
-func Init(isClusterMode bool, redisClient redis.UniversalClient, heloDomains []string) {
-	domainsList = heloDomains
+// HELO domains may carry an optional weight using the "domain:weight" notation.
+// Syntactically invalid domains (and unresolvable ones when resolveCheck is set) are
+// skipped with a warning; an error is returned if no usable domain remains
+func Init(isClusterMode bool, redisClient redis.UniversalClient, heloDomains []string, selection Strategy, resolveCheck bool) error {
+	if err := Reload(heloDomains, selection, resolveCheck); err != nil {
+		return err
+	}
+
+	cooldowns.Lock()
+	cooldowns.until = make(map[string]time.Time)
+	cooldowns.Unlock()
+
 	if isClusterMode && redisClient != nil {
 		// Use Redis counter for clustered deployments
 		counter = &RedisCounter{
@@ -54,15 +103,161 @@ func Init(isClusterMode bool, redisClient redis.UniversalClient, heloDomains []s
 		// Use in-memory counter for single instance
 		counter = &MemoryCounter{}
 	}
+	return nil
 }
 
-// Get next rotated domain using modulo distribution
-func GetNext() (string, error) {
-	n, err := counter.Next() // Get sequence number
+// Reload replaces the rotation list and strategy while checks are running
+// The counter and active cooldowns are kept; on error the current rotation stays in place
+func Reload(heloDomains []string, selection Strategy, resolveCheck bool) error {
+	rotation, err := NewRotation(heloDomains, selection, resolveCheck)
 	if err != nil {
-		return "", err // Propagate counter errors
+		return err
+	}
+	rotation.Apply()
+	return nil
+}
+
+// Rotation is a validated HELO domain list and strategy, applied with Apply
+type Rotation struct {
+	domains  []heloDomain
+	strategy Strategy
+}
+
+// NewRotation validates HELO domains and the selection strategy without applying them
+func NewRotation(heloDomains []string, selection Strategy, resolveCheck bool) (*Rotation, error) {
+	usable := validateDomains(parseDomains(heloDomains), resolveCheck)
+	if len(usable) == 0 {
+		return nil, fmt.Errorf("no usable HELO domains in %q", heloDomains)
+	}
+	switch selection {
+	case StrategyRoundRobin, StrategyWeighted:
+	case "":
+		selection = StrategyRoundRobin // Default to round-robin when unset
+	default:
+		return nil, fmt.Errorf("unknown HELO selection strategy %q", selection)
+	}
+	return &Rotation{domains: usable, strategy: selection}, nil
+}
+
+// Apply makes r the active rotation; the counter and active cooldowns are kept
+func (r *Rotation) Apply() {
+	rotationMu.Lock()
+	defer rotationMu.Unlock()
+	domainsList = r.domains
+	strategy = r.strategy
+}
+
+// validateDomains filters out domains that cannot be used in HELO/EHLO
+func validateDomains(candidates []heloDomain, resolveCheck bool) []heloDomain {
+	usable := make([]heloDomain, 0, len(candidates))
+	for _, d := range candidates {
+		if len(d.name) > 253 || !hostnamePattern.MatchString(d.name) {
+			logger.Log(fmt.Sprintf("[WARN] Invalid HELO domain %q skipped", d.name))
+			continue
+		}
+		if resolveCheck {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			addrs, err := net.DefaultResolver.LookupHost(ctx, d.name)
+			cancel()
+			if err != nil || len(addrs) == 0 {
+				logger.Log(fmt.Sprintf("[WARN] HELO domain %s does not resolve, skipped: %v", d.name, err))
+				continue
+			}
+		}
+		usable = append(usable, d)
+	}
+	return usable
+}
+
+// parseDomains converts "domain[:weight]" entries into rotation entries
+// Entries that still hold a comma-separated list (e.g. HELO_DOMAINS from the
+// environment, which viper does not split) are expanded into separate domains
+func parseDomains(heloDomains []string) []heloDomain {
+	parsed := make([]heloDomain, 0, len(heloDomains))
+	for _, raw := range heloDomains {
+		for _, entry := range strings.Split(raw, ",") {
+			name, weight := strings.TrimSpace(entry), 1
+			if name == "" {
+				continue // Ignore empty items from trailing commas
+			}
+			if idx := strings.LastIndex(name, ":"); idx != -1 {
+				if w, err := strconv.Atoi(name[idx+1:]); err == nil && w > 0 {
+					weight = w
+				}
+				name = name[:idx]
+			}
+			parsed = append(parsed, heloDomain{name: name, weight: weight})
+		}
+	}
+	return parsed
+}
+
+// CoolDown excludes a domain from rotation for the given duration
+func CoolDown(domain string, ttl time.Duration) {
+	cooldowns.Lock()
+	defer cooldowns.Unlock()
+	if cooldowns.until == nil {
+		cooldowns.until = make(map[string]time.Time)
 	}
+	cooldowns.until[domain] = time.Now().Add(ttl)
+}
+
+// available returns domains that are not cooling down, with the active strategy
+// Falls back to the full list when every domain is cooling down
+func available() ([]heloDomain, Strategy) {
+	rotationMu.RLock()
+	list, selection := domainsList, strategy
+	rotationMu.RUnlock()
+
+	cooldowns.RLock()
+	defer cooldowns.RUnlock()
 
-	// Rotate through domains using modulus
-	return domainsList[n%uint64(len(domainsList))], nil
+	now := time.Now()
+	candidates := make([]heloDomain, 0, len(list))
+	for _, d := range list {
+		if until, ok := cooldowns.until[d.name]; ok && now.Before(until) {
+			continue
+		}
+		candidates = append(candidates, d)
+	}
+	if len(candidates) == 0 {
+		return list, selection
+	}
+	return candidates, selection
+}
+
+// Get next rotated domain using the configured strategy
+func GetNext() (string, error) {
+	candidates, strategy := available()
+
+	totalWeight := 0
+	for _, d := range candidates {
+		totalWeight += d.weight
+	}
+
+	var slot int
+	if strategy == StrategyWeighted {
+		slot = rand.Intn(totalWeight) // Random slot proportional to weights
+	} else {
+		n, err := counter.Next() // Get sequence number
+		if err != nil {
+			metrics.HeloSelectionErrors.Inc()
+			return "", err // Propagate counter errors
+		}
+		slot = int(n % uint64(totalWeight)) // Rotate through weighted slots using modulus
+		if slot == 0 {
+			metrics.HeloRotationCycles.Inc() // Counter wrapped back to the first slot
+		}
+	}
+
+	name := candidates[len(candidates)-1].name
+	for _, d := range candidates {
+		if slot < d.weight {
+			name = d.name
+			break
+		}
+		slot -= d.weight
+	}
+	metrics.HeloSelections.WithLabelValues(name).Inc()
+	return name, nil
 }
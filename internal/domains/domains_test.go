@@ -0,0 +1,112 @@
+package domains
+
+import (
+	"testing"
+	"time"
+)
+
+// nextDomains returns the next n rotated domains
+func nextDomains(t *testing.T, n int) []string {
+	t.Helper()
+	got := make([]string, n)
+	for i := range got {
+		name, err := GetNext()
+		if err != nil {
+			t.Fatalf("GetNext: %v", err)
+		}
+		got[i] = name
+	}
+	return got
+}
+
+func TestInitUsesConfiguredDomains(t *testing.T) {
+	tests := []struct {
+		name        string
+		heloDomains []string
+		want        []string // Two full rotation cycles
+	}{
+		{"list", []string{"a.example.com", "b.example.com", "c.example.com"},
+			[]string{"b.example.com", "c.example.com", "a.example.com", "b.example.com", "c.example.com", "a.example.com"}},
+		{"comma separated", []string{"a.example.com, b.example.com,"},
+			[]string{"b.example.com", "a.example.com", "b.example.com", "a.example.com"}},
+		{"weighted", []string{"a.example.com:2", "b.example.com"},
+			[]string{"a.example.com", "b.example.com", "a.example.com", "a.example.com", "b.example.com", "a.example.com"}},
+		{"invalid skipped", []string{"not a domain", "a.example.com"},
+			[]string{"a.example.com", "a.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Init(false, nil, tt.heloDomains, StrategyRoundRobin, false); err != nil {
+				t.Fatalf("Init: %v", err)
+			}
+			got := nextDomains(t, len(tt.want))
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("rotation = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestInitWithoutUsableDomainsFails(t *testing.T) {
+	if err := Init(false, nil, []string{"", "not a domain"}, StrategyRoundRobin, false); err == nil {
+		t.Fatal("Init without usable domains returned no error")
+	}
+}
+
+func TestCoolDownSkipsDomain(t *testing.T) {
+	if err := Init(false, nil, []string{"a.example.com", "b.example.com"}, StrategyRoundRobin, false); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	CoolDown("a.example.com", time.Minute)
+	for _, name := range nextDomains(t, 4) {
+		if name != "b.example.com" {
+			t.Fatalf("rotated to %s while it cools down", name)
+		}
+	}
+}
+
+func TestNewRotationAppliesOnlyOnApply(t *testing.T) {
+	if err := Init(false, nil, []string{"a.example.com"}, StrategyRoundRobin, false); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	rotation, err := NewRotation([]string{"b.example.com"}, StrategyRoundRobin, false)
+	if err != nil {
+		t.Fatalf("NewRotation: %v", err)
+	}
+	if _, err := NewRotation([]string{"c.example.com"}, "random", false); err == nil {
+		t.Fatal("NewRotation accepted an unknown strategy")
+	}
+	if got := nextDomains(t, 1)[0]; got != "a.example.com" {
+		t.Fatalf("rotated to %s before Apply", got)
+	}
+
+	rotation.Apply()
+	if got := nextDomains(t, 1)[0]; got != "b.example.com" {
+		t.Fatalf("rotated to %s after Apply, want b.example.com", got)
+	}
+}
+
+func TestNewMappingAppliesOnlyOnApply(t *testing.T) {
+	if err := SetMapping([]string{"yahoo.com=mx1.example.com"}); err != nil {
+		t.Fatalf("SetMapping: %v", err)
+	}
+	t.Cleanup(func() { SetMapping(nil) })
+
+	m, err := NewMapping([]string{"yahoo.com=mx2.example.com"})
+	if err != nil {
+		t.Fatalf("NewMapping: %v", err)
+	}
+	if _, err := NewMapping([]string{"yahoo.com=not a host"}); err == nil {
+		t.Fatal("NewMapping accepted an invalid HELO domain")
+	}
+	if helo, _ := GetNextFor("yahoo.com"); helo != "mx1.example.com" {
+		t.Fatalf("yahoo.com greeted as %s before Apply", helo)
+	}
+
+	m.Apply()
+	if helo, _ := GetNextFor("yahoo.com"); helo != "mx2.example.com" {
+		t.Fatalf("yahoo.com greeted as %s after Apply, want mx2.example.com", helo)
+	}
+}
@@ -0,0 +1,132 @@
+package domains
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+
+	"github.com/shuliakovsky/email-checker/internal/metrics"
+)
+
+// identity is the HELO domain and MAIL FROM address pinned for a recipient domain
+type identity struct {
+	helo   string
+	sender string // MAIL FROM address; test@helo when empty
+}
+
+// Recipient domains greeted with a fixed identity instead of the rotation, for strict providers
+// that expect a HELO domain with matching rDNS
+var mapping struct {
+	sync.RWMutex
+	identities map[string]identity // Keyed by recipient domain; "*.suffix" keys cover subdomains
+}
+
+// SetMapping replaces the per-domain identities with "target=helo[:sender]" entries,
+// e.g. "yahoo.com=mx1.example.com:verify@example.com" or "*.aol.com=mx2.example.com"
+// On error the current mapping stays in place
+func SetMapping(entries []string) error {
+	m, err := NewMapping(entries)
+	if err != nil {
+		return err
+	}
+	m.Apply()
+	return nil
+}
+
+// ValidateMapping reports the first invalid mapping entry without applying any
+func ValidateMapping(entries []string) error {
+	_, err := parseMapping(entries)
+	return err
+}
+
+// Mapping is a validated set of per-domain identities, applied with Apply
+type Mapping struct {
+	identities map[string]identity
+}
+
+// NewMapping validates mapping entries without applying them
+func NewMapping(entries []string) (*Mapping, error) {
+	identities, err := parseMapping(entries)
+	if err != nil {
+		return nil, err
+	}
+	return &Mapping{identities: identities}, nil
+}
+
+// Apply makes m the active per-domain mapping
+func (m *Mapping) Apply() {
+	mapping.Lock()
+	defer mapping.Unlock()
+	mapping.identities = m.identities
+}
+
+// parseMapping converts mapping entries, expanding comma-separated lists like parseDomains
+func parseMapping(entries []string) (map[string]identity, error) {
+	identities := make(map[string]identity)
+	for _, raw := range entries {
+		for _, entry := range strings.Split(raw, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			target, value, ok := strings.Cut(entry, "=")
+			target = strings.ToLower(strings.TrimSpace(target))
+			if !ok || target == "" {
+				return nil, fmt.Errorf("HELO mapping %q must be target=helo[:sender]", entry)
+			}
+			helo, sender, _ := strings.Cut(strings.TrimSpace(value), ":")
+			if !hostnamePattern.MatchString(helo) {
+				return nil, fmt.Errorf("HELO mapping %q: invalid HELO domain %q", entry, helo)
+			}
+			if sender != "" {
+				if addr, err := mail.ParseAddress(sender); err != nil || addr.Address != sender {
+					return nil, fmt.Errorf("HELO mapping %q: invalid MAIL FROM address %q", entry, sender)
+				}
+			}
+			identities[target] = identity{helo: helo, sender: sender}
+		}
+	}
+	return identities, nil
+}
+
+// lookup returns the identity mapped to a recipient domain, trying the exact domain
+// and then "*." wildcards of its parent domains
+func lookup(target string) (identity, bool) {
+	mapping.RLock()
+	defer mapping.RUnlock()
+	if len(mapping.identities) == 0 {
+		return identity{}, false
+	}
+	target = strings.ToLower(target)
+	if id, ok := mapping.identities[target]; ok {
+		return id, true
+	}
+	for domain := target; ; {
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			return identity{}, false
+		}
+		if id, ok := mapping.identities["*."+parent]; ok {
+			return id, true
+		}
+		domain = parent
+	}
+}
+
+// GetNextFor returns the HELO domain mapped to the recipient domain, falling back to the rotation
+func GetNextFor(targetDomain string) (string, error) {
+	if id, ok := lookup(targetDomain); ok {
+		metrics.HeloSelections.WithLabelValues(id.helo).Inc()
+		return id.helo, nil
+	}
+	return GetNext()
+}
+
+// SenderFor returns the MAIL FROM address for a recipient domain greeted with helo
+func SenderFor(targetDomain, helo string) string {
+	if id, ok := lookup(targetDomain); ok && id.sender != "" && id.helo == helo {
+		return id.sender
+	}
+	return "test@" + helo
+}
@@ -0,0 +1,42 @@
+// Package export writes task results in file formats and uploads them to S3-compatible storage
+package export
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// CSVHeader lists the CSV columns written for each report
+var CSVHeader = []string{
+	"email", "valid", "disposable", "role", "exists", "mx_valid",
+	"error_category", "permanent_error", "smtp_error", "ttl", "score", "risk", "checked_at",
+}
+
+// CSVRow converts a report into CSV column values matching CSVHeader
+func CSVRow(report types.EmailReport) []string {
+	exists := ""
+	if report.Exists != nil {
+		exists = strconv.FormatBool(*report.Exists)
+	}
+	checkedAt := ""
+	if !report.CheckedAt.IsZero() {
+		checkedAt = report.CheckedAt.Format(time.RFC3339)
+	}
+	return []string{
+		report.Email,
+		strconv.FormatBool(report.Valid),
+		strconv.FormatBool(report.Disposable),
+		strconv.FormatBool(report.Role),
+		exists,
+		strconv.FormatBool(report.MX.Valid),
+		report.ErrorCategory,
+		strconv.FormatBool(report.PermanentError),
+		report.SMTPError,
+		strconv.Itoa(report.TTL),
+		strconv.Itoa(report.Score),
+		report.Risk,
+		checkedAt,
+	}
+}
@@ -0,0 +1,76 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Target is the object an export is uploaded to
+type S3Target struct {
+	Endpoint  string // Service URL, e.g. https://s3.eu-west-1.amazonaws.com or a MinIO server
+	Region    string // Signing region (us-east-1 for most S3-compatible services)
+	Bucket    string
+	Key       string // Object key
+	AccessKey string
+	SecretKey string
+}
+
+// URL returns the path-style object URL, which every S3-compatible service accepts
+func (t S3Target) URL() string {
+	return strings.TrimRight(t.Endpoint, "/") + "/" + url.PathEscape(t.Bucket) + "/" + escapeKey(t.Key)
+}
+
+// PutObject uploads size bytes of body with the AWS SDK S3 client, sending requests through httpClient,
+// and returns the object URL
+// Path-style addressing is used and checksums are only sent when required, so S3-compatible services work too
+func PutObject(ctx context.Context, httpClient s3.HTTPClient, t S3Target, body io.ReadSeeker, size int64, contentType string) (string, error) {
+	client := s3.New(s3.Options{
+		Region:       t.Region,
+		BaseEndpoint: aws.String(strings.TrimRight(t.Endpoint, "/")),
+		UsePathStyle: true,
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: t.AccessKey, SecretAccessKey: t.SecretKey}, nil
+		}),
+		HTTPClient:                 httpClient,
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	})
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(t.Bucket),
+		Key:           aws.String(t.Key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String(contentType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("upload to %s failed: %w", t.URL(), err)
+	}
+	return t.URL(), nil
+}
+
+// escapeKey escapes every segment of an object key, keeping the '/' separators
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// ParseEndpoint checks that endpoint is an http(s) URL without a path, query or credentials
+func ParseEndpoint(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("export endpoint must be an http(s) URL, got %q", endpoint)
+	}
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.User != nil {
+		return nil, fmt.Errorf("export endpoint must not have a path, query or credentials, got %q", endpoint)
+	}
+	return u, nil
+}
@@ -7,6 +7,22 @@ import (
 	"time"
 )
 
+// Lua script deleting the lock only if it is still held by the given token
+const releaseScript = `
+	if redis.call("get", KEYS[1]) == ARGV[1] then
+		return redis.call("del", KEYS[1])
+	end
+	return 0
+`
+
+// Locker is implemented by single-node and Redlock distributed locks
+type Locker interface {
+	Acquire(ctx context.Context) bool
+	Release(ctx context.Context)
+	Refresh(ctx context.Context) bool
+	StartRefresh(ctx context.Context)
+}
+
 // DistributedLock provides Redis-based distributed locking mechanism
 type DistributedLock struct {
 	client      redis.UniversalClient
@@ -41,13 +57,7 @@ func (dl *DistributedLock) Release(ctx context.Context) {
 	if !dl.clusterMode {
 		return
 	}
-	script := `
-		if redis.call("get", KEYS[1]) == ARGV[1] then
-			return redis.call("del", KEYS[1])
-		end
-		return 0
-	`
-	dl.client.Eval(ctx, script, []string{dl.key}, dl.token)
+	dl.client.Eval(ctx, releaseScript, []string{dl.key}, dl.token)
 }
 
 // Extends lock expiration time if still held
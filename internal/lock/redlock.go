@@ -0,0 +1,187 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	clockDriftFactor = 0.01                  // Share of the TTL reserved for clock drift between nodes
+	minNodeTimeout   = 50 * time.Millisecond // Lower bound for a single node round-trip
+)
+
+var (
+	// Independent Redis nodes used for Redlock (empty disables Redlock)
+	redlockClients []redis.UniversalClient
+)
+
+// SetRedlockClients configures independent Redis nodes used by NewClusterLock
+func SetRedlockClients(clients []redis.UniversalClient) {
+	redlockClients = clients
+}
+
+// NewClusterLock returns a Redlock when independent nodes are configured,
+// otherwise a single-node DistributedLock on the given client
+func NewClusterLock(client redis.UniversalClient, key string, ttl time.Duration, clusterMode bool) Locker {
+	if clusterMode && len(redlockClients) > 0 {
+		return NewRedlock(redlockClients, key, ttl)
+	}
+	return NewLock(client, key, ttl, clusterMode)
+}
+
+// Nodes returns the Redis clients cluster locks are kept on: the Redlock nodes when configured, otherwise client
+// Code inspecting or removing locks by key must use these rather than the main client
+func Nodes(client redis.UniversalClient) []redis.UniversalClient {
+	if len(redlockClients) > 0 {
+		return redlockClients
+	}
+	return []redis.UniversalClient{client}
+}
+
+// Stalled reports whether the lock under key is kept on some node but not with at least minTTL left on a quorum
+// of nodes, i.e. its holder stopped refreshing it; copies without expiry count as stalled. Nodes that cannot be
+// asked count as holding the lock, so an unreachable node never gets a live lock recovered
+func Stalled(ctx context.Context, nodes []redis.UniversalClient, key string, minTTL time.Duration) bool {
+	present, alive := 0, 0
+	for _, node := range nodes {
+		ttl, err := node.TTL(ctx, key).Result()
+		switch {
+		case err != nil || ttl >= minTTL:
+			present++
+			alive++
+		case ttl != -2: // -2: no such key, -1: no expiry
+			present++
+		}
+	}
+	return present > 0 && alive < quorum(len(nodes))
+}
+
+// Remove deletes the lock under key from every node regardless of its holder, reporting whether any node had it
+func Remove(ctx context.Context, nodes []redis.UniversalClient, key string) (bool, error) {
+	removed := false
+	for _, node := range nodes {
+		deleted, err := node.Del(ctx, key).Result()
+		if err != nil {
+			return removed, err
+		}
+		removed = removed || deleted > 0
+	}
+	return removed, nil
+}
+
+// quorum returns the number of nodes out of n that must agree on a lock
+func quorum(n int) int {
+	return n/2 + 1
+}
+
+// Redlock implements the Redlock algorithm across independent Redis instances
+// The lock is held when a majority of nodes accepted it within the validity window
+type Redlock struct {
+	clients []redis.UniversalClient
+	key     string
+	token   string
+	ttl     time.Duration
+	quorum  int
+}
+
+// Creates new Redlock instance over independent Redis nodes with unique token
+func NewRedlock(clients []redis.UniversalClient, key string, ttl time.Duration) *Redlock {
+	return &Redlock{
+		clients: clients,
+		key:     key,
+		ttl:     ttl,
+		token:   generateToken(),
+		quorum:  quorum(len(clients)),
+	}
+}
+
+// Attempts to acquire lock on a quorum of nodes within the TTL budget.
+// Partially acquired locks are released on failure.
+func (rl *Redlock) Acquire(ctx context.Context) bool {
+	start := time.Now()
+	acquired := rl.onAll(ctx, func(ctx context.Context, client redis.UniversalClient) bool {
+		return client.SetNX(ctx, rl.key, rl.token, rl.ttl).Val()
+	})
+
+	drift := time.Duration(float64(rl.ttl)*clockDriftFactor) + 2*time.Millisecond
+	validity := rl.ttl - time.Since(start) - drift
+	if acquired >= rl.quorum && validity > 0 {
+		return true
+	}
+
+	rl.Release(ctx) // Undo partial acquisition
+	return false
+}
+
+// Releases lock on all nodes using the token-checked Lua script
+func (rl *Redlock) Release(ctx context.Context) {
+	rl.onAll(ctx, func(ctx context.Context, client redis.UniversalClient) bool {
+		return client.Eval(ctx, releaseScript, []string{rl.key}, rl.token).Err() == nil
+	})
+}
+
+// Extends lock expiration on nodes still holding our token; succeeds on quorum
+func (rl *Redlock) Refresh(ctx context.Context) bool {
+	script := `
+		if redis.call("get", KEYS[1]) == ARGV[1] then
+			return redis.call("pexpire", KEYS[1], ARGV[2])
+		end
+		return 0
+	`
+	refreshed := rl.onAll(ctx, func(ctx context.Context, client redis.UniversalClient) bool {
+		n, err := client.Eval(ctx, script, []string{rl.key}, rl.token, rl.ttl.Milliseconds()).Int()
+		return err == nil && n == 1
+	})
+	return refreshed >= rl.quorum
+}
+
+// Starts background goroutine to periodically refresh lock
+func (rl *Redlock) StartRefresh(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !rl.Refresh(ctx) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// onAll runs op concurrently against every node with a per-node timeout
+// and returns the number of nodes where it succeeded
+func (rl *Redlock) onAll(ctx context.Context, op func(context.Context, redis.UniversalClient) bool) int {
+	timeout := rl.ttl / 100
+	if timeout < minNodeTimeout {
+		timeout = minNodeTimeout
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		successes int
+	)
+	for _, client := range rl.clients {
+		wg.Add(1)
+		go func(client redis.UniversalClient) {
+			defer wg.Done()
+			nodeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			if op(nodeCtx, client) {
+				mu.Lock()
+				successes++
+				mu.Unlock()
+			}
+		}(client)
+	}
+	wg.Wait()
+	return successes
+}
@@ -0,0 +1,174 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// newNodes starts n independent in-process Redis nodes
+func newNodes(t *testing.T, n int) ([]*miniredis.Miniredis, []redis.UniversalClient) {
+	t.Helper()
+	servers := make([]*miniredis.Miniredis, n)
+	clients := make([]redis.UniversalClient, n)
+	for i := range n {
+		servers[i] = miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: servers[i].Addr(), MaxRetries: -1})
+		t.Cleanup(func() { client.Close() })
+		clients[i] = client
+	}
+	return servers, clients
+}
+
+func TestRedlockAcquiresOnQuorum(t *testing.T) {
+	ctx := context.Background()
+	servers, clients := newNodes(t, 3)
+
+	// One node down still leaves a majority
+	servers[2].Close()
+	rl := NewRedlock(clients, "lock:test", time.Minute)
+	if !rl.Acquire(ctx) {
+		t.Fatal("lock not acquired with 2 of 3 nodes up")
+	}
+	for _, mr := range servers[:2] {
+		if got, _ := mr.Get("lock:test"); got != rl.token {
+			t.Fatalf("node holds %q, want the lock token", got)
+		}
+	}
+	if other := NewRedlock(clients, "lock:test", time.Minute); other.Acquire(ctx) {
+		t.Fatal("second lock acquired while the first is held")
+	}
+
+	rl.Release(ctx)
+	for _, mr := range servers[:2] {
+		if mr.Exists("lock:test") {
+			t.Fatal("lock still held after Release")
+		}
+	}
+}
+
+func TestRedlockFailsWithoutQuorum(t *testing.T) {
+	nodes, clients := newNodes(t, 3)
+	nodes[1].Close()
+	nodes[2].Close()
+	if NewRedlock(clients, "lock:test", time.Minute).Acquire(context.Background()) {
+		t.Fatal("lock acquired with 1 of 3 nodes up")
+	}
+	// The single reachable node does not keep the failed attempt
+	if nodes[0].Exists("lock:test") {
+		t.Fatal("partially acquired lock was not released")
+	}
+}
+
+func TestRedlockRollsBackPartialAcquire(t *testing.T) {
+	nodes, clients := newNodes(t, 3)
+
+	// Another holder has the lock on two nodes; the free node must not keep our token
+	nodes[0].Set("lock:test", "lock:other")
+	nodes[1].Set("lock:test", "lock:other")
+	if NewRedlock(clients, "lock:test", time.Minute).Acquire(context.Background()) {
+		t.Fatal("lock acquired on a minority of nodes")
+	}
+	if nodes[2].Exists("lock:test") {
+		t.Fatal("partially acquired lock was not released")
+	}
+	for _, mr := range nodes[:2] {
+		if got, _ := mr.Get("lock:test"); got != "lock:other" {
+			t.Fatalf("rollback touched the other holder's lock: %q", got)
+		}
+	}
+}
+
+func TestRedlockRefresh(t *testing.T) {
+	ctx := context.Background()
+	nodes, clients := newNodes(t, 3)
+
+	rl := NewRedlock(clients, "lock:test", time.Minute)
+	if !rl.Acquire(ctx) {
+		t.Fatal("lock not acquired")
+	}
+	for _, mr := range nodes {
+		mr.FastForward(50 * time.Second)
+	}
+	if !rl.Refresh(ctx) {
+		t.Fatal("Refresh failed while the lock is held")
+	}
+	for _, mr := range nodes {
+		if ttl := mr.TTL("lock:test"); ttl != time.Minute {
+			t.Fatalf("TTL after refresh = %v, want %v", ttl, time.Minute)
+		}
+	}
+
+	// Losing the lock on a majority of nodes makes the refresh fail, without touching the new holder's copies
+	nodes[0].Set("lock:test", "lock:other")
+	nodes[1].Del("lock:test")
+	if rl.Refresh(ctx) {
+		t.Fatal("Refresh succeeded after the lock was lost on a majority")
+	}
+	if got, _ := nodes[0].Get("lock:test"); got != "lock:other" {
+		t.Fatalf("refresh overwrote another holder's lock: %q", got)
+	}
+}
+
+func TestStalledAndRemove(t *testing.T) {
+	ctx := context.Background()
+	nodes, clients := newNodes(t, 3)
+
+	if Stalled(ctx, clients, "lock:test", time.Minute) {
+		t.Fatal("missing lock reported as stalled")
+	}
+
+	// Refreshed on a majority: alive
+	for _, mr := range nodes[:2] {
+		mr.Set("lock:test", "lock:holder")
+		mr.SetTTL("lock:test", 5*time.Minute)
+	}
+	if Stalled(ctx, clients, "lock:test", time.Minute) {
+		t.Fatal("lock refreshed on a majority reported as stalled")
+	}
+
+	// No longer refreshed: about to expire on every node
+	for _, mr := range nodes[:2] {
+		mr.SetTTL("lock:test", 30*time.Second)
+	}
+	if !Stalled(ctx, clients, "lock:test", time.Minute) {
+		t.Fatal("lock about to expire not reported as stalled")
+	}
+
+	removed, err := Remove(ctx, clients, "lock:test")
+	if err != nil || !removed {
+		t.Fatalf("Remove = %v, %v; want true, nil", removed, err)
+	}
+	for _, mr := range nodes {
+		if mr.Exists("lock:test") {
+			t.Fatal("lock left on a node after Remove")
+		}
+	}
+	if removed, _ := Remove(ctx, clients, "lock:test"); removed {
+		t.Fatal("Remove of a missing lock reported a removal")
+	}
+}
+
+func TestNodesFollowRedlockConfiguration(t *testing.T) {
+	_, clients := newNodes(t, 3)
+	_, main := newNodes(t, 1)
+	t.Cleanup(func() { SetRedlockClients(nil) })
+
+	if nodes := Nodes(main[0]); len(nodes) != 1 || nodes[0] != main[0] {
+		t.Fatal("without Redlock nodes locks must be kept on the main client")
+	}
+	if _, ok := NewClusterLock(main[0], "lock:test", time.Minute, true).(*DistributedLock); !ok {
+		t.Fatal("NewClusterLock without Redlock nodes is not a single-node lock")
+	}
+
+	SetRedlockClients(clients)
+	if nodes := Nodes(main[0]); len(nodes) != 3 {
+		t.Fatalf("Nodes returned %d clients, want the 3 Redlock nodes", len(nodes))
+	}
+	if _, ok := NewClusterLock(main[0], "lock:test", time.Minute, true).(*Redlock); !ok {
+		t.Fatal("NewClusterLock with Redlock nodes is not a Redlock")
+	}
+}
@@ -1,10 +1,20 @@
 package logger
 
 import (
+	"context"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 )
 
+// Fields are structured key/value pairs appended to a log line as key=value
+type Fields map[string]string
+
+// requestIDKey is the context key under which the request correlation ID is stored
+type requestIDKey struct{}
+
 // Instance is the singleton instance of BufferedLogger, shared across the application
 var (
 	Instance *BufferedLogger // Global logger instance
@@ -19,7 +29,8 @@ type BufferedLogger struct {
 }
 
 // Init initializes the logger with the specified mode (immediate or buffered)
-// Ensures that the logger is initialized only once across the application
+// Ensures that the logger is initialized only once across the application;
+// later calls are ignored, use SetImmediate to change the mode afterwards
 func Init(immediate bool) {
 	initOnce.Do(func() {
 		Instance = &BufferedLogger{
@@ -28,6 +39,26 @@ func Init(immediate bool) {
 	})
 }
 
+// SetImmediate switches the logger between immediate and buffered mode
+// Buffered messages are flushed when switching to immediate mode so that ordering is preserved
+// It is safe to call even if Init has not been invoked
+func SetImmediate(immediate bool) {
+	if Instance == nil {
+		return // Mode is applied by Init when the logger is created
+	}
+
+	Instance.mu.Lock()         // Acquire the mutex lock for safe access
+	defer Instance.mu.Unlock() // Ensure the lock is released after the operation
+
+	if immediate && !Instance.immediate {
+		for _, msg := range Instance.buffer { // Output messages buffered before the switch
+			log.Println(msg)
+		}
+		Instance.buffer = nil // Clear the buffer after flushing
+	}
+	Instance.immediate = immediate // Apply the new logging mode
+}
+
 // Log adds a message to the buffer or logs it immediately depending on the configuration
 // It is safe to call even if Init has not been invoked
 func Log(msg string) {
@@ -47,7 +78,12 @@ func Log(msg string) {
 }
 
 // Flush outputs all buffered log messages and clears the buffer
+// It is safe to call even if Init has not been invoked
 func Flush() {
+	if Instance == nil {
+		return // Nothing has been buffered without an initialized logger
+	}
+
 	Instance.mu.Lock()                    // Acquire the mutex lock for safe access
 	defer Instance.mu.Unlock()            // Ensure the lock is released after the operation
 	for _, msg := range Instance.buffer { // Iterate over buffered messages
@@ -55,3 +91,42 @@ func Flush() {
 	}
 	Instance.buffer = nil // Clear the buffer after flushing
 }
+
+// LogFields logs a message followed by its fields in key order; empty values are omitted
+// Values containing spaces or quotes are quoted so lines stay grep- and parse-friendly
+func LogFields(msg string, fields Fields) {
+	keys := make([]string, 0, len(fields))
+	for key, value := range fields {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var line strings.Builder
+	line.WriteString(msg)
+	for _, key := range keys {
+		value := fields[key]
+		if strings.ContainsAny(value, " \t\"=") {
+			value = strconv.Quote(value)
+		}
+		line.WriteString(" " + key + "=" + value)
+	}
+	Log(line.String())
+}
+
+// WithRequestID returns a context carrying the request correlation ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the correlation ID stored in the context, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// LogContext logs a message tagged with the request ID carried by the context
+func LogContext(ctx context.Context, msg string) {
+	LogFields(msg, Fields{"request_id": RequestID(ctx)})
+}
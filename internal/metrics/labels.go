@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+)
+
+// OtherDomain is the label shared by domains beyond the cardinality limit
+const OtherDomain = "other"
+
+// DefaultDomainLabelLimit is how many distinct domains get their own label by default
+const DefaultDomainLabelLimit = 100
+
+// domainLabels hands out per-domain label values first come, first served up to a limit,
+// so a batch over millions of domains cannot create millions of time series
+var domainLabels = struct {
+	sync.Mutex
+	limit int
+	seen  map[string]struct{}
+}{limit: DefaultDomainLabelLimit, seen: make(map[string]struct{})}
+
+// SetDomainLabelLimit sets how many distinct domains are labelled individually (0 reports every domain as "other")
+// Domains already labelled keep their label
+func SetDomainLabelLimit(limit int) {
+	domainLabels.Lock()
+	defer domainLabels.Unlock()
+	domainLabels.limit = max(limit, 0)
+}
+
+// DomainLabel returns the label value for a domain: the domain itself while the limit allows, otherwise OtherDomain
+func DomainLabel(domain string) string {
+	domain = strings.ToLower(domain)
+	domainLabels.Lock()
+	defer domainLabels.Unlock()
+	if _, ok := domainLabels.seen[domain]; ok {
+		return domain
+	}
+	if len(domainLabels.seen) >= domainLabels.limit {
+		return OtherDomain
+	}
+	domainLabels.seen[domain] = struct{}{}
+	return domain
+}
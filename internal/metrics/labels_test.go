@@ -0,0 +1,23 @@
+package metrics
+
+import "testing"
+
+func TestDomainLabelCapsDistinctValues(t *testing.T) {
+	SetDomainLabelLimit(2)
+	defer SetDomainLabelLimit(DefaultDomainLabelLimit)
+
+	if got := DomainLabel("hooks.example.com"); got != "hooks.example.com" {
+		t.Fatalf("first label = %q", got)
+	}
+	if got := DomainLabel("LOGS.example.com"); got != "logs.example.com" {
+		t.Fatalf("second label = %q, want lowercased host", got)
+	}
+	for _, host := range []string{"a.attacker.test", "b.attacker.test", "c.attacker.test"} {
+		if got := DomainLabel(host); got != OtherDomain {
+			t.Fatalf("DomainLabel(%s) = %q beyond the limit, want %q", host, got, OtherDomain)
+		}
+	}
+	if got := DomainLabel("hooks.example.com"); got != "hooks.example.com" {
+		t.Fatalf("labelled host lost its label: %q", got)
+	}
+}
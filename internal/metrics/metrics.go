@@ -16,6 +16,11 @@ var (
 		Help: "Total emails processed",
 	})
 
+	EmailOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "email_outcomes_total",
+		Help: "Verified emails per outcome (invalid, disposable, exists, undeliverable, unknown)",
+	}, []string{"outcome", "cache_hit"})
+
 	CacheHits = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "cache_hits_total",
 		Help: "Total cache hits",
@@ -40,6 +45,11 @@ var (
 		Help: "Total webhook delivery attempts",
 	}, []string{"task_id", "status"})
 
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_deliveries_total",
+		Help: "Webhook notifications per destination host and final result (host label capped, see DomainLabel)",
+	}, []string{"host", "result"})
+
 	WebhookRetries = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "webhook_retries_total",
 		Help: "Total webhook retry attempts",
@@ -50,6 +60,37 @@ var (
 		Help:    "Webhook delivery latency distribution",
 		Buckets: prometheus.DefBuckets,
 	})
+	TaskPayloadBytes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "task_payload_bytes",
+		Help:    "Size of serialized task metadata (without results) written to Redis",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 10), // 1KiB .. 256MiB
+	})
+	SMTPLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "smtp_check_latency_seconds",
+		Help:    "SMTP verification latency distribution per result",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60},
+	}, []string{"result"})
+
+	SMTPConnections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "smtp_connections_total",
+		Help: "Established SMTP connections per IP family",
+	}, []string{"family"})
+
+	HeloSelections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "helo_domain_selections_total",
+		Help: "HELO domains handed out by the rotation",
+	}, []string{"domain"})
+
+	HeloSelectionErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "helo_domain_selection_errors_total",
+		Help: "Failed HELO domain selections (e.g. Redis counter errors)",
+	})
+
+	HeloRotationCycles = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "helo_rotation_cycles_total",
+		Help: "Completed round-robin cycles through the HELO domains",
+	})
+
 	ThrottledDomains = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "smtp_throttled_domains_total",
 		Help: "Total number of throttled domains",
@@ -57,18 +98,32 @@ var (
 
 	RetryAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "smtp_retry_attempts_total",
-		Help: "Total email retry attempts",
-	}, []string{"email", "attempt"})
+		Help: "Total email re-checks scheduled after every MX host failed temporarily (domain label capped, see DomainLabel)",
+	}, []string{"domain", "attempt"})
+
+	ConnectionRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "smtp_connection_retries_total",
+		Help: "Total SMTP sessions repeated against the same host and port after a retryable failure (domain label capped, see DomainLabel)",
+	}, []string{"domain", "attempt"})
 
 	TemporaryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "smtp_temporary_errors_total",
-		Help: "Total temporary SMTP errors",
+		Help: "Total temporary SMTP errors (domain label capped, see DomainLabel)",
 	}, []string{"domain"})
 
+	SMTPDomainResults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "smtp_domain_results_total",
+		Help: "SMTP check results per domain (domain label capped, see DomainLabel)",
+	}, []string{"domain", "result"})
+
 	RBLRestrictions = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "smtp_rbl_restrictions_total",
 		Help: "Total RBL restriction errors",
 	})
+	TaskQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "task_queue_depth",
+		Help: "Created or re-queued tasks waiting for a task loop",
+	})
 	APIKeyChecks = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "apikey_checks_total",
 		Help: "Total checks per API key",
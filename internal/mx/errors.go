@@ -0,0 +1,168 @@
+package mx
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNS failure categories reported in MXStats.ErrorCategory
+const (
+	CategoryNXDomain = "nxdomain"      // Domain does not exist
+	CategoryNoMX     = "no_mx_records" // Domain exists but publishes no MX records
+	CategoryNullMX   = "null_mx"       // Domain publishes a null MX and accepts no mail (RFC 7505)
+	CategoryTimeout  = "dns_timeout"   // Resolver did not answer in time
+	CategoryServFail = "servfail"      // Resolver reported a server failure
+	CategoryDNSError = "dns_error"     // Any other resolution failure
+)
+
+// LookupError is returned by GetMXRecords with the failure classified into a category
+type LookupError struct {
+	Category string // One of the Category* constants
+	Err      error  // Underlying resolver error
+}
+
+func (e *LookupError) Error() string {
+	return "MX lookup failed: " + e.Err.Error()
+}
+
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCategory returns the DNS failure category of an error from GetMXRecords
+func ErrorCategory(err error) string {
+	var lookupErr *LookupError
+	if errors.As(err, &lookupErr) {
+		return lookupErr.Category
+	}
+	if err != nil {
+		return CategoryDNSError
+	}
+	return ""
+}
+
+// classifyLookupError maps a resolver error to a DNS failure category
+// The resolver reports NXDOMAIN and NODATA answers alike as "not found", so the
+// domain is queried again to tell a domain without MX records apart from a missing one
+func classifyLookupError(ctx context.Context, domain string, err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return CategoryTimeout
+	}
+
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		return CategoryDNSError
+	}
+	switch {
+	case dnsErr.IsTimeout:
+		return CategoryTimeout
+	case dnsErr.IsNotFound:
+		if domainExists(ctx, domain) {
+			return CategoryNoMX
+		}
+		return CategoryNXDomain
+	case dnsErr.IsTemporary:
+		return CategoryServFail // SERVFAIL answers surface as temporary errors
+	default:
+		return CategoryDNSError
+	}
+}
+
+// domainExists reports whether the domain exists in DNS, even when it publishes neither MX nor address records
+// The response code of an SOA query decides; without one, any NS or address record counts as existence
+func domainExists(ctx context.Context, domain string) bool {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if rcode, err := queryRCode(ctx, domain, dnsmessage.TypeSOA); err == nil {
+		switch rcode {
+		case dnsmessage.RCodeNameError:
+			return false
+		case dnsmessage.RCodeSuccess:
+			return true
+		}
+	}
+	if ns, err := resolver.LookupNS(ctx, domain); err == nil && len(ns) > 0 {
+		return true
+	}
+	addrs, err := resolver.LookupHost(ctx, domain)
+	return err == nil && len(addrs) > 0
+}
+
+// queryRCode sends one query to the configured DNS server and returns the response code
+func queryRCode(ctx context.Context, domain string, qtype dnsmessage.Type) (dnsmessage.RCode, error) {
+	if resolver == nil || resolver.Dial == nil {
+		return 0, errors.New("no DNS server configured")
+	}
+	name, err := dnsmessage.NewName(dnsName(domain))
+	if err != nil {
+		return 0, err
+	}
+	id := uint16(time.Now().UnixNano())
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return 0, err
+	}
+
+	// The resolver's dialer ignores the address and connects to the configured server
+	conn, err := resolver.Dial(ctx, "udp", "")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var resp []byte
+	if _, ok := conn.(net.PacketConn); ok {
+		if _, err := conn.Write(query); err != nil {
+			return 0, err
+		}
+		resp = make([]byte, 1232)
+		n, err := conn.Read(resp)
+		if err != nil {
+			return 0, err
+		}
+		resp = resp[:n]
+	} else {
+		// Stream transports prefix every message with its length (RFC 1035 4.2.2)
+		if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)); err != nil {
+			return 0, err
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return 0, err
+		}
+		resp = make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			return 0, err
+		}
+	}
+
+	var parser dnsmessage.Parser
+	header, err := parser.Start(resp)
+	if err != nil {
+		return 0, err
+	}
+	if !header.Response || header.ID != id {
+		return 0, errors.New("unexpected DNS response")
+	}
+	return header.RCode, nil
+}
+
+// dnsName returns the domain as a fully qualified DNS name
+func dnsName(domain string) string {
+	if len(domain) > 0 && domain[len(domain)-1] == '.' {
+		return domain
+	}
+	return domain + "."
+}
@@ -0,0 +1,66 @@
+package mx
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveDNS answers every query on a local UDP server with the response code of the queried name
+// and no records, and points the package resolver at it
+func serveDNS(t *testing.T, rcodes map[string]dnsmessage.RCode) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+			rcode, ok := rcodes[query.Questions[0].Name.String()]
+			if !ok {
+				rcode = dnsmessage.RCodeNameError
+			}
+			resp, _ := (&dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, RCode: rcode},
+				Questions: query.Questions,
+			}).Pack()
+			pc.WriteTo(resp, addr)
+		}
+	}()
+
+	prev := resolver
+	resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", pc.LocalAddr().String())
+		},
+	}
+	t.Cleanup(func() { resolver = prev })
+}
+
+func TestNotFoundClassifiedByResponseCode(t *testing.T) {
+	// A registered domain without A or MX records answers NOERROR with no data
+	serveDNS(t, map[string]dnsmessage.RCode{"parked.example.": dnsmessage.RCodeSuccess})
+	notFound := &net.DNSError{Err: "no such host", IsNotFound: true}
+
+	if got := classifyLookupError(context.Background(), "parked.example", notFound); got != CategoryNoMX {
+		t.Fatalf("existing domain without records = %q, want %q", got, CategoryNoMX)
+	}
+	if got := classifyLookupError(context.Background(), "missing.example", notFound); got != CategoryNXDomain {
+		t.Fatalf("NXDOMAIN = %q, want %q", got, CategoryNXDomain)
+	}
+}
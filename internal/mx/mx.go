@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"golang.org/x/sync/singleflight"
+
 	"github.com/shuliakovsky/email-checker/internal/cache"
 	"github.com/shuliakovsky/email-checker/internal/metrics"
 )
@@ -24,8 +28,28 @@ var (
 
 	// Custom DNS resolver instance
 	resolver *net.Resolver
+
+	// In-flight MX lookups shared by concurrent callers resolving the same domain
+	lookups singleflight.Group
+
+	// Bounded pool of DNS query slots shared by all workers
+	lookupSlots = make(chan struct{}, DefaultLookupConcurrency)
+)
+
+const (
+	DefaultLookupConcurrency = 32               // Default number of concurrent DNS queries
+	lookupTimeout            = 10 * time.Second // Upper bound for one shared MX lookup
 )
 
+// SetLookupConcurrency bounds the number of concurrent DNS queries across all workers
+// Call it during startup, before lookups begin
+func SetLookupConcurrency(n int) {
+	if n <= 0 {
+		n = DefaultLookupConcurrency
+	}
+	lookupSlots = make(chan struct{}, n)
+}
+
 // Initialize local cache storage
 func init() {
 	localCache.records = make(map[string][]*net.MX)
@@ -60,6 +84,42 @@ func SetCacheProvider(provider cache.Provider) {
 // 3. Perform DNS lookup
 // 4. Cache results in both layers
 func GetMXRecords(domain string) ([]*net.MX, error) {
+	return getMXRecords(context.Background(), domain)
+}
+
+// PrefetchDomains resolves MX records for the given domains concurrently to warm the caches
+// Lookup errors are ignored; remaining lookups are abandoned when ctx is cancelled
+func PrefetchDomains(ctx context.Context, domains []string, concurrency int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for domain := range jobs {
+				getMXRecords(ctx, domain)
+			}
+		}()
+	}
+
+feed:
+	for _, domain := range domains {
+		select {
+		case jobs <- domain:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// getMXRecords implements the cached lookup with a caller-provided context
+func getMXRecords(ctx context.Context, domain string) ([]*net.MX, error) {
 	// First check distributed cache if available
 	if cacheProvider != nil {
 		if cached, ok := cacheProvider.Get("mx:" + domain); ok {
@@ -77,11 +137,38 @@ func GetMXRecords(domain string) ([]*net.MX, error) {
 		return cached, nil
 	}
 
+	// Collapse concurrent lookups of the same domain into one DNS query
+	result := lookups.DoChan(domain, func() (interface{}, error) {
+		return lookupMX(domain)
+	})
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]*net.MX), nil
+	case <-ctx.Done():
+		// The shared lookup keeps running for other callers and still fills the caches
+		return nil, &LookupError{Category: CategoryTimeout, Err: ctx.Err()}
+	}
+}
+
+// lookupMX performs the DNS query within the shared concurrency bound and caches the result
+// It runs detached from any single caller's context because its result is shared
+func lookupMX(domain string) ([]*net.MX, error) {
+	slots := lookupSlots
+	slots <- struct{}{}
+	defer func() { <-slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
 	// Perform actual DNS MX lookup
-	records, err := resolver.LookupMX(context.Background(), domain)
+	records, err := resolver.LookupMX(ctx, domain)
 	if err != nil {
-		return nil, fmt.Errorf("MX lookup failed: %w", err)
+		return nil, &LookupError{Category: classifyLookupError(ctx, domain, err), Err: err}
 	}
+	records = normalizeRecords(records)
 
 	// Update local cache with write lock
 	localCache.Lock()
@@ -95,3 +182,38 @@ func GetMXRecords(domain string) ([]*net.MX, error) {
 
 	return records, nil
 }
+
+// normalizeRecords sorts records by preference (lowest first) and drops duplicate hosts
+// Resolvers do not guarantee ordering, and a host listed twice keeps its best preference
+func normalizeRecords(records []*net.MX) []*net.MX {
+	sorted := make([]*net.MX, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Pref < sorted[j].Pref })
+
+	seen := make(map[string]bool, len(sorted))
+	unique := sorted[:0]
+	for _, record := range sorted {
+		host := strings.ToLower(strings.TrimSuffix(record.Host, "."))
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+		unique = append(unique, record)
+	}
+	return unique
+}
+
+// ImplicitMX returns the domain itself as mail host when it has A/AAAA records
+// Used when a domain publishes no MX records (RFC 5321 section 5.1)
+func ImplicitMX(domain string) ([]*net.MX, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := resolver.LookupHost(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address records for %s", domain)
+	}
+	return []*net.MX{{Host: domain}}, nil
+}
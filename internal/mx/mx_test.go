@@ -0,0 +1,62 @@
+package mx
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// describe renders records as "host/pref" in order
+func describe(records []*net.MX) []string {
+	out := make([]string, len(records))
+	for i, r := range records {
+		out[i] = fmt.Sprintf("%s/%d", r.Host, r.Pref)
+	}
+	return out
+}
+
+func TestNormalizeRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []*net.MX
+		want    []string
+	}{
+		{"empty", nil, []string{}},
+		{"out of order", []*net.MX{
+			{Host: "mx3.example.com.", Pref: 30},
+			{Host: "mx1.example.com.", Pref: 10},
+			{Host: "mx2.example.com.", Pref: 20},
+		}, []string{"mx1.example.com./10", "mx2.example.com./20", "mx3.example.com./30"}},
+		{"equal preference keeps resolver order", []*net.MX{
+			{Host: "b.example.com.", Pref: 10},
+			{Host: "a.example.com.", Pref: 10},
+			{Host: "c.example.com.", Pref: 5},
+		}, []string{"c.example.com./5", "b.example.com./10", "a.example.com./10"}},
+		{"duplicate keeps best preference", []*net.MX{
+			{Host: "backup.example.com.", Pref: 50},
+			{Host: "mx.example.com.", Pref: 20},
+			{Host: "backup.example.com.", Pref: 5},
+		}, []string{"backup.example.com./5", "mx.example.com./20"}},
+		{"duplicates differ in case and trailing dot", []*net.MX{
+			{Host: "MX.Example.com.", Pref: 10},
+			{Host: "mx.example.com", Pref: 10},
+			{Host: "mx.example.com.", Pref: 20},
+		}, []string{"MX.Example.com./10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describe(normalizeRecords(tt.records)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("normalizeRecords = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeRecordsKeepsInput(t *testing.T) {
+	records := []*net.MX{{Host: "b.example.com.", Pref: 20}, {Host: "a.example.com.", Pref: 10}, {Host: "a.example.com.", Pref: 10}}
+	normalizeRecords(records)
+	if got := describe(records); !reflect.DeepEqual(got, []string{"b.example.com./20", "a.example.com./10", "a.example.com./10"}) {
+		t.Fatalf("input changed to %v", got)
+	}
+}
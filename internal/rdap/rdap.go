@@ -0,0 +1,137 @@
+// Package rdap looks up domain registration dates over RDAP (HTTP/JSON successor of WHOIS)
+package rdap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/shuliakovsky/email-checker/internal/cache"
+	"github.com/shuliakovsky/email-checker/internal/logger"
+)
+
+const (
+	registeredTTL  = 30 * 24 * time.Hour // Registration dates practically never change
+	unknownTTL     = 24 * time.Hour      // Domains without registration data are retried daily
+	defaultBackoff = time.Minute         // Pause after a 429 without a usable Retry-After header
+)
+
+// ErrRateLimited is returned while the RDAP service asked us to back off
+var ErrRateLimited = errors.New("rdap rate limited")
+
+var (
+	// Bootstrap service redirecting to the authoritative RDAP server of each TLD
+	bootstrapURL = "https://rdap.org/domain/"
+
+	httpClient = &http.Client{Timeout: 5 * time.Second}
+
+	// Registration dates per registrable domain (zero time when unknown)
+	registrations cache.Provider = cache.NewInMemoryCache()
+
+	// Global back-off window after the RDAP service rate limited us
+	backoff struct {
+		sync.RWMutex
+		until time.Time
+	}
+)
+
+// domainResponse is the subset of an RDAP domain object we need
+type domainResponse struct {
+	Events []struct {
+		Action string    `json:"eventAction"`
+		Date   time.Time `json:"eventDate"`
+	} `json:"events"`
+}
+
+// DomainAgeDays returns the age of the domain's registration in whole days
+// Subdomains are resolved to their registrable domain first. ok is false when the
+// registry publishes no registration date; err is set for lookup failures and rate limits
+func DomainAgeDays(ctx context.Context, domain string) (days int, ok bool, err error) {
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(domain))
+	if err != nil {
+		return 0, false, err
+	}
+
+	registered, err := registrationDate(ctx, registrable)
+	if err != nil || registered.IsZero() {
+		return 0, false, err
+	}
+	return int(time.Since(registered).Hours() / 24), true, nil
+}
+
+// registrationDate returns the cached or freshly looked up registration date
+func registrationDate(ctx context.Context, domain string) (time.Time, error) {
+	if cached, ok := registrations.Get(domain); ok {
+		return cached.(time.Time), nil
+	}
+
+	backoff.RLock()
+	limited := time.Now().Before(backoff.until)
+	backoff.RUnlock()
+	if limited {
+		return time.Time{}, ErrRateLimited
+	}
+
+	registered, err := lookup(ctx, domain)
+	if err != nil {
+		return time.Time{}, err
+	}
+	ttl := registeredTTL
+	if registered.IsZero() {
+		ttl = unknownTTL
+	}
+	registrations.Set(domain, registered, ttl)
+	return registered, nil
+}
+
+// lookup queries RDAP for the registration event of a domain
+// Unknown domains and responses without a registration event yield the zero time
+func lookup(ctx context.Context, domain string) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bootstrapURL+domain, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		wait := defaultBackoff
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+		backoff.Lock()
+		backoff.until = time.Now().Add(wait)
+		backoff.Unlock()
+		logger.Log(fmt.Sprintf("[RDAP] Rate limited, pausing lookups for %v", wait))
+		return time.Time{}, ErrRateLimited
+	case resp.StatusCode == http.StatusNotFound:
+		return time.Time{}, nil // No RDAP data for this domain or TLD
+	case resp.StatusCode != http.StatusOK:
+		return time.Time{}, fmt.Errorf("rdap lookup for %s: unexpected status %d", domain, resp.StatusCode)
+	}
+
+	var body domainResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return time.Time{}, fmt.Errorf("rdap lookup for %s: %w", domain, err)
+	}
+	for _, event := range body.Events {
+		if event.Action == "registration" {
+			return event.Date, nil
+		}
+	}
+	return time.Time{}, nil
+}
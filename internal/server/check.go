@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/checker"
+	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+const (
+	syncCheckTimeout = 30 * time.Second // Upper bound for a synchronous check including SMTP
+
+	// DefaultMaxBatchEmails is the default maximum number of emails per /check-batch request
+	DefaultMaxBatchEmails = 50
+)
+
+// handleCheck verifies a single email synchronously and returns its report
+// Accepts GET /check?email= or POST /check with {"email": "..."}; may take a few seconds due to SMTP
+func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
+	key := r.Context().Value("api_key").(*auth.APIKey)
+
+	var (
+		email  string
+		checks *types.CheckToggles
+		fresh  bool
+		err    error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		email = r.URL.Query().Get("email")
+		checks, err = checksFromQuery(r.URL.Query())
+	case http.MethodPost:
+		var request struct {
+			Email  string          `json:"email"`
+			Checks json.RawMessage `json:"checks"`
+			Fresh  bool            `json:"fresh"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request format")
+			return
+		}
+		email = request.Email
+		fresh = request.Fresh
+		checks, err = parseChecks(request.Checks)
+	default:
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	maxAge, err := maxAgeFromQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	freshQuery, err := freshFromQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if email == "" {
+		respondError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+	if len(email) > maxEmailLength && !s.lenientEmailLength {
+		respondError(w, http.StatusBadRequest, "Email too long")
+		return
+	}
+
+	cfg := s.checkerConfig()
+	cfg.RequestID = logger.RequestID(r.Context())
+	cfg.MaxAge = maxAge
+	cfg.SkipCache = fresh || freshQuery
+	applyChecks(&cfg, checks)
+
+	ctx, cancel := context.WithTimeout(r.Context(), syncCheckTimeout)
+	defer cancel()
+
+	// The verification ends its SMTP sessions at the deadline too, so nothing outlives the request for long
+	done := make(chan types.EmailReport, 1)
+	go func() {
+		done <- checker.CheckEmailContext(ctx, email, cfg)
+	}()
+
+	var report types.EmailReport
+	select {
+	case report = <-done:
+	case <-ctx.Done():
+		respondError(w, http.StatusGatewayTimeout, "Verification timed out")
+		return
+	}
+	if report.ErrorCategory == checker.NotChecked { // Cut short by the deadline: no verdict to charge
+		respondError(w, http.StatusGatewayTimeout, "Verification timed out")
+		return
+	}
+
+	// Charge one check for the completed verification
+	s.chargeQuota(key.Key, "check-"+s.generateID(), 1)
+
+	writeReport(w, report)
+}
+
+// handleCheckBatch verifies a small list of emails synchronously and returns their reports in order
+// Emails not finished within the deadline are returned with the not_checked category and not charged
+func (s *Server) handleCheckBatch(w http.ResponseWriter, r *http.Request) {
+	key := r.Context().Value("api_key").(*auth.APIKey)
+
+	var request struct {
+		Emails []string        `json:"emails"`
+		Checks json.RawMessage `json:"checks"`
+		Fresh  bool            `json:"fresh"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+	if len(request.Emails) == 0 {
+		respondError(w, http.StatusBadRequest, "Emails are required")
+		return
+	}
+	if limit := s.batchLimit(); len(request.Emails) > limit {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Too many emails (max %d per synchronous batch, use /tasks for larger lists)", limit))
+		return
+	}
+	if len(request.Emails) > key.Remaining {
+		respondError(w, http.StatusForbidden, "Not enough remaining checks")
+		return
+	}
+	if s.rejectOversizedEmails(w, request.Emails) {
+		return
+	}
+	checks, err := parseChecks(request.Checks)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	maxAge, err := maxAgeFromQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fresh, err := freshFromQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cfg := s.checkerConfig()
+	cfg.RequestID = logger.RequestID(r.Context())
+	cfg.MaxAge = maxAge
+	cfg.SkipCache = fresh || request.Fresh
+	applyChecks(&cfg, checks)
+
+	ctx, cancel := context.WithTimeout(r.Context(), syncCheckTimeout)
+	defer cancel()
+	reports := checker.ProcessEmailsWithContext(ctx, request.Emails, cfg)
+
+	// Charge each distinct verified email once
+	s.chargeQuota(key.Key, "check-batch-"+s.generateID(), checker.CountCharged(reports))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reports)
+}
+
+// SetMaxBatchEmails sets the maximum number of emails per /check-batch request
+func (s *Server) SetMaxBatchEmails(n int) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.maxBatchEmails = n
+}
+
+// batchLimit returns the synchronous batch size limit (DefaultMaxBatchEmails if unset)
+func (s *Server) batchLimit() int {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	if s.maxBatchEmails > 0 {
+		return s.maxBatchEmails
+	}
+	return DefaultMaxBatchEmails
+}
+
+// writeReport encodes a report, answering 429 with Retry-After while its domain is throttled
+func writeReport(w http.ResponseWriter, report types.EmailReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(report.RetryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/cache"
+	"github.com/shuliakovsky/email-checker/internal/smtp"
+	"github.com/shuliakovsky/email-checker/internal/storage"
+)
+
+func TestHandleCheckBoundsVerificationByDeadline(t *testing.T) {
+	provider := cache.NewInMemoryCache()
+	provider.Set("mx:deadline.example", []*net.MX{{Host: "mx.deadline.example."}}, time.Hour)
+	s := &Server{storage: storage.NewMemoryStorage(provider), maxWorkers: 1}
+
+	deadlines := make(chan time.Time, 1)
+	smtp.RegisterVerifier("deadline.example", smtp.VerifierFunc(func(ctx context.Context, email string, mxRecords []*net.MX) (smtp.Result, error) {
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+		return smtp.Result{Exists: true}, nil
+	}))
+	t.Cleanup(func() { smtp.RegisterVerifier("deadline.example", nil) })
+
+	req := httptest.NewRequest(http.MethodGet, "/check?email=user@deadline.example", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "api_key", &auth.APIKey{Remaining: 1}))
+	rec := httptest.NewRecorder()
+	s.handleCheck(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /check = %d: %s", rec.Code, rec.Body)
+	}
+
+	// The verification runs under the synchronous deadline, not the unbounded request context
+	deadline := <-deadlines
+	if deadline.IsZero() || time.Until(deadline) > syncCheckTimeout {
+		t.Fatalf("verifier deadline = %v, want within %v", deadline, syncCheckTimeout)
+	}
+}
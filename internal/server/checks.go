@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/checker"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// parseChecks decodes the optional "checks" object of a request body
+// Unknown check names are rejected so typos don't silently keep a check enabled
+func parseChecks(raw json.RawMessage) (*types.CheckToggles, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	var checks types.CheckToggles
+	if err := decoder.Decode(&checks); err != nil {
+		return nil, fmt.Errorf("invalid checks: %v", err)
+	}
+	return &checks, nil
+}
+
+// checksFromQuery reads check toggles from the disposable and role query parameters
+func checksFromQuery(query url.Values) (*types.CheckToggles, error) {
+	var checks types.CheckToggles
+	for name, target := range map[string]**bool{
+		"disposable": &checks.Disposable,
+		"role":       &checks.Role,
+	} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: must be true or false", name)
+		}
+		*target = &enabled
+	}
+	if checks == (types.CheckToggles{}) {
+		return nil, nil
+	}
+	return &checks, nil
+}
+
+// maxAgeFromQuery reads the max_age query parameter (a duration such as 72h)
+// Cached reports checked longer ago are verified again; 0 when absent
+func maxAgeFromQuery(query url.Values) (time.Duration, error) {
+	value := query.Get("max_age")
+	if value == "" {
+		return 0, nil
+	}
+	maxAge, err := time.ParseDuration(value)
+	if err != nil || maxAge < 0 {
+		return 0, fmt.Errorf("invalid max_age: must be a non-negative duration such as 72h")
+	}
+	return maxAge, nil
+}
+
+// freshFromQuery reads the fresh query parameter requesting checks that ignore cached reports
+func freshFromQuery(query url.Values) (bool, error) {
+	value := query.Get("fresh")
+	if value == "" {
+		return false, nil
+	}
+	fresh, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid fresh: must be true or false")
+	}
+	return fresh, nil
+}
+
+// applyChecks disables the optional checks a request switched off; unset toggles stay enabled
+func applyChecks(cfg *checker.Config, checks *types.CheckToggles) {
+	if checks == nil {
+		return
+	}
+	if checks.Disposable != nil && !*checks.Disposable {
+		cfg.SkipDisposable = true
+	}
+	if checks.Role != nil && !*checks.Role {
+		cfg.SkipRole = true
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/checker"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// QuotaEstimateResponse is the cost of a list compared with the key's remaining quota
+type QuotaEstimateResponse struct {
+	types.QuotaEstimate
+	Remaining  int  `json:"remaining"`  // Checks left on the key
+	Sufficient bool `json:"sufficient"` // Whether the remaining quota covers the chargeable checks
+}
+
+// handleEstimateTask reports how many checks a list would consume, without creating a task or charging quota
+func (s *Server) handleEstimateTask(w http.ResponseWriter, r *http.Request) {
+	key := r.Context().Value("api_key").(*auth.APIKey)
+
+	var request struct {
+		Emails         []string `json:"emails"`
+		ExcludeInvalid bool     `json:"exclude_invalid"` // Leave syntactically invalid addresses out of the cost
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+	if limit := s.maxTaskEmails(key); len(request.Emails) > limit {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Too many emails (max %d for %s keys)", limit, key.Type))
+		return
+	}
+
+	estimate := checker.EstimateCost(request.Emails, request.ExcludeInvalid)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(QuotaEstimateResponse{
+		QuotaEstimate: estimate,
+		Remaining:     key.Remaining,
+		Sufficient:    estimate.Chargeable <= key.Remaining,
+	})
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/export"
+	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+const (
+	exportUploadTimeout = 10 * time.Minute // Bounds one results upload
+	exportPageSize      = 1000             // Results read from storage at a time while writing an export
+	exportDefaultRegion = "us-east-1"
+)
+
+// SetExportDefaults sets the S3-compatible destination of exports requested without their own bucket
+// Call it before Start
+func (s *Server) SetExportDefaults(cfg types.ExportConfig) {
+	if cfg.Region == "" {
+		cfg.Region = exportDefaultRegion
+	}
+	s.exportDefaults = cfg
+}
+
+// exportRequest is the export of a task request; its secret key is never stored with the task
+type exportRequest struct {
+	types.ExportConfig
+	SecretKey string `json:"secret_key,omitempty"`
+}
+
+// prepareExport validates the export requested for a new task and fills in the default format
+// It returns the config stored with the task and, apart from it, the secret key of an own destination
+// A destination of the client's own is subject to the webhook policy, as the server connects to it
+func (s *Server) prepareExport(ctx context.Context, req *exportRequest) (*types.ExportConfig, string, error) {
+	if req == nil {
+		return nil, "", nil
+	}
+	cfg := req.ExportConfig
+	switch cfg.Format {
+	case "":
+		cfg.Format = "json"
+	case "json", "csv":
+	default:
+		return nil, "", fmt.Errorf("export format must be json or csv")
+	}
+	withSecret := cfg
+	withSecret.SecretKey = req.SecretKey
+	if _, err := s.exportTarget("", &withSecret); err != nil {
+		return nil, "", err
+	}
+	if ownExportDestination(&cfg) {
+		if s.clusterMode {
+			// The secret key stays on the node accepting the task, which may not be the one completing it
+			return nil, "", fmt.Errorf("exports to an own bucket are not available in cluster mode; omit endpoint and bucket to use the server's export bucket")
+		}
+		if _, err := export.ParseEndpoint(cfg.Endpoint); err != nil {
+			return nil, "", err
+		}
+		if err := s.webhookPolicy.checkURL(ctx, cfg.Endpoint); err != nil {
+			return nil, "", fmt.Errorf("export endpoint: %w", err)
+		}
+	}
+	return &cfg, req.SecretKey, nil
+}
+
+// keepExportSecret holds the secret key of a task's own export destination in process memory until the upload
+func (s *Server) keepExportSecret(taskID, secret string) {
+	if secret != "" {
+		s.exportSecrets.Store(taskID, secret)
+	}
+}
+
+// ownExportDestination reports whether the export names its own bucket instead of the server's
+func ownExportDestination(cfg *types.ExportConfig) bool {
+	return cfg.Bucket != "" || cfg.Endpoint != ""
+}
+
+// exportTarget resolves the object the results of taskID are uploaded to
+// Server credentials are only ever used with the server's own endpoint and bucket
+func (s *Server) exportTarget(taskID string, cfg *types.ExportConfig) (export.S3Target, error) {
+	key := cfg.Prefix + taskID + "." + cfg.Format
+	if !ownExportDestination(cfg) {
+		def := s.exportDefaults
+		if def.Bucket == "" {
+			return export.S3Target{}, fmt.Errorf("no export bucket configured on the server; pass endpoint, bucket and credentials")
+		}
+		return export.S3Target{Endpoint: def.Endpoint, Region: def.Region, Bucket: def.Bucket, Key: def.Prefix + key,
+			AccessKey: def.AccessKey, SecretKey: def.SecretKey}, nil
+	}
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return export.S3Target{}, fmt.Errorf("export to an own bucket needs endpoint, bucket, access_key and secret_key")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = exportDefaultRegion
+	}
+	return export.S3Target{Endpoint: cfg.Endpoint, Region: region, Bucket: cfg.Bucket, Key: key,
+		AccessKey: cfg.AccessKey, SecretKey: cfg.SecretKey}, nil
+}
+
+// exportResults uploads the stored results of a task that requested an export,
+// recording the object URL or the failure on the task
+func (s *Server) exportResults(task *types.Task) {
+	if task.Export == nil {
+		return
+	}
+	defer s.exportSecrets.Delete(task.ID)
+	url, err := s.uploadResults(task)
+	if err != nil {
+		task.ExportError = err.Error()
+		logger.LogFields("[Export] Upload failed", logger.Fields{"task_id": task.ID, "request_id": task.RequestID, "error": err.Error()})
+		return
+	}
+	task.ExportURL, task.ExportError = url, ""
+	logger.LogFields("[Export] Results uploaded", logger.Fields{"task_id": task.ID, "request_id": task.RequestID, "url": url})
+}
+
+// uploadResults writes the results to a temporary file, so memory stays bounded, and uploads it
+func (s *Server) uploadResults(task *types.Task) (string, error) {
+	cfg := *task.Export
+	if ownExportDestination(&cfg) {
+		secret, ok := s.exportSecrets.Load(task.ID)
+		if !ok {
+			return "", fmt.Errorf("export credentials are no longer available, the server restarted since the task was created")
+		}
+		cfg.SecretKey = secret.(string)
+	}
+	target, err := s.exportTarget(task.ID, &cfg)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp("", "email-checker-export-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportUploadTimeout)
+	defer cancel()
+	if err := s.writeExport(ctx, file, task); err != nil {
+		return "", err
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	client := &http.Client{} // The operator's endpoint may be internal
+	if ownExportDestination(task.Export) {
+		client = &http.Client{Transport: s.webhookTransport, CheckRedirect: s.webhookPolicy.checkRedirect}
+	}
+	contentType := "application/json"
+	if task.Export.Format == "csv" {
+		contentType = "text/csv"
+	}
+	return export.PutObject(ctx, client, target, file, size, contentType)
+}
+
+// writeExport writes every stored result of task to w in the export format, a page at a time
+func (s *Server) writeExport(ctx context.Context, w io.Writer, task *types.Task) error {
+	buffered := bufio.NewWriter(w)
+	var (
+		csvWriter *csv.Writer
+		written   int
+	)
+	if task.Export.Format == "csv" {
+		csvWriter = csv.NewWriter(buffered)
+		if err := csvWriter.Write(export.CSVHeader); err != nil {
+			return err
+		}
+	} else {
+		buffered.WriteString("[")
+	}
+
+	for {
+		page, total, err := s.storage.GetTaskResultsPage(ctx, task.ID, written, exportPageSize)
+		if err != nil {
+			return err
+		}
+		for _, report := range page {
+			if csvWriter != nil {
+				if err := csvWriter.Write(export.CSVRow(report)); err != nil {
+					return err
+				}
+			} else {
+				data, err := json.Marshal(report)
+				if err != nil {
+					return err
+				}
+				if written > 0 {
+					buffered.WriteString(",")
+				}
+				buffered.WriteString("\n")
+				buffered.Write(data)
+			}
+			written++
+		}
+		if len(page) == 0 || written >= total {
+			break
+		}
+	}
+	if written < len(task.Emails) {
+		return fmt.Errorf("only %d of %d results are stored", written, len(task.Emails))
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+	} else {
+		buffered.WriteString("\n]\n")
+	}
+	return buffered.Flush()
+}
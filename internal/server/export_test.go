@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// ownBucketExport is an export request naming the client's own destination
+func ownBucketExport() *exportRequest {
+	return &exportRequest{
+		ExportConfig: types.ExportConfig{Endpoint: "http://127.0.0.1:9000", Bucket: "results", AccessKey: "AKIDEXAMPLE"},
+		SecretKey:    "top-secret",
+	}
+}
+
+func TestExportSecretIsNotStoredWithTask(t *testing.T) {
+	policy, err := newWebhookPolicy(nil, []string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{webhookPolicy: policy}
+
+	cfg, secret, err := s.prepareExport(context.Background(), ownBucketExport())
+	if err != nil {
+		t.Fatalf("prepareExport: %v", err)
+	}
+	if secret != "top-secret" || cfg.SecretKey != "" {
+		t.Fatalf("secret = %q, stored config secret = %q; want the secret kept apart", secret, cfg.SecretKey)
+	}
+	if cfg.Format != "json" {
+		t.Errorf("format = %q, want default json", cfg.Format)
+	}
+
+	data, err := json.Marshal(&types.Task{ID: "t", Export: &types.ExportConfig{AccessKey: "AKIDEXAMPLE", SecretKey: "top-secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "top-secret") {
+		t.Fatalf("serialized task contains the secret key: %s", data)
+	}
+
+	s.keepExportSecret("t", secret)
+	if held, ok := s.exportSecrets.Load("t"); !ok || held != "top-secret" {
+		t.Fatalf("held secret = %v, %v", held, ok)
+	}
+}
+
+func TestExportRequestDecodesSecretKey(t *testing.T) {
+	var req exportRequest
+	if err := json.Unmarshal([]byte(`{"bucket": "b", "access_key": "a", "secret_key": "s"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Bucket != "b" || req.AccessKey != "a" || req.SecretKey != "s" {
+		t.Fatalf("decoded %+v", req)
+	}
+}
+
+func TestOwnBucketExportRejectedInClusterMode(t *testing.T) {
+	s := &Server{clusterMode: true}
+	if _, _, err := s.prepareExport(context.Background(), ownBucketExport()); err == nil {
+		t.Fatal("own-bucket export accepted in cluster mode")
+	}
+}
+
+func TestUploadWithoutHeldSecretFails(t *testing.T) {
+	s := &Server{}
+	task := &types.Task{ID: "restarted", Export: &types.ExportConfig{Format: "json", Endpoint: "http://127.0.0.1:9000", Bucket: "b", AccessKey: "a"}}
+	if _, err := s.uploadResults(task); err == nil || !strings.Contains(err.Error(), "no longer available") {
+		t.Fatalf("err = %v, want missing credentials", err)
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// resultFilter narrows task results by outcome; all set conditions must match (AND semantics)
+type resultFilter struct {
+	valid      *bool  // Match EmailReport.Valid
+	exists     *bool  // Match EmailReport.Exists (reports without SMTP verdict never match)
+	disposable *bool  // Match EmailReport.Disposable
+	category   string // Match EmailReport.ErrorCategory exactly
+}
+
+// parseResultFilter reads valid, exists, disposable and category query parameters
+func parseResultFilter(query url.Values) (resultFilter, error) {
+	var filter resultFilter
+	for name, target := range map[string]**bool{
+		"valid":      &filter.valid,
+		"exists":     &filter.exists,
+		"disposable": &filter.disposable,
+	} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return resultFilter{}, fmt.Errorf("invalid '%s' filter: expected true or false", name)
+		}
+		*target = &value
+	}
+	filter.category = query.Get("category")
+	return filter, nil
+}
+
+// active reports whether any filter condition is set
+func (f resultFilter) active() bool {
+	return f.valid != nil || f.exists != nil || f.disposable != nil || f.category != ""
+}
+
+// matches reports whether a report satisfies every set condition
+func (f resultFilter) matches(report types.EmailReport) bool {
+	if f.valid != nil && report.Valid != *f.valid {
+		return false
+	}
+	if f.exists != nil && (report.Exists == nil || *report.Exists != *f.exists) {
+		return false
+	}
+	if f.disposable != nil && report.Disposable != *f.disposable {
+		return false
+	}
+	if f.category != "" && report.ErrorCategory != f.category {
+		return false
+	}
+	return true
+}
+
+// apply returns the reports matching the filter
+func (f resultFilter) apply(reports []types.EmailReport) []types.EmailReport {
+	filtered := make([]types.EmailReport, 0, len(reports))
+	for _, report := range reports {
+		if f.matches(report) {
+			filtered = append(filtered, report)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyHeader    = "Idempotency-Key" // Request header carrying the client-chosen key
+	idempotencyTTL       = 24 * time.Hour    // Matches the task retention period
+	maxIdempotencyKeyLen = 255               // Upper bound for accepted key length
+)
+
+// errIdempotencyKeyTooLong rejects keys longer than maxIdempotencyKeyLen
+var errIdempotencyKeyTooLong = fmt.Errorf("%s too long (max %d)", idempotencyHeader, maxIdempotencyKeyLen)
+
+// reserveTaskID returns the task ID to use for a task creation request
+// Without an Idempotency-Key header a fresh ID is generated. With one, the first request
+// reserves a fresh ID under the key (scoped to the API key) and repeats get the same ID
+// back with replay set, so the caller must not create or charge the task again.
+// The returned release function frees the reservation if the task could not be saved.
+func (s *Server) reserveTaskID(r *http.Request, apiKey string) (taskID string, replay bool, release func(), err error) {
+	taskID = s.generateID()
+	release = func() {}
+
+	key := r.Header.Get(idempotencyHeader)
+	if key == "" {
+		return taskID, false, release, nil
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		return "", false, release, errIdempotencyKeyTooLong
+	}
+
+	scoped := apiKey + ":" + key
+	stored, created, err := s.storage.ReserveIdempotencyKey(r.Context(), scoped, taskID, idempotencyTTL)
+	if err != nil {
+		return "", false, release, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if !created {
+		return stored, true, release, nil
+	}
+
+	release = func() {
+		_ = s.storage.ReleaseIdempotencyKey(r.Context(), scoped)
+	}
+	return taskID, false, release, nil
+}
+
+// respondTaskReservationError maps reserveTaskID failures to HTTP responses
+func respondTaskReservationError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errIdempotencyKeyTooLong) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondError(w, http.StatusInternalServerError, err.Error())
+}
+
+// respondTaskCreated writes the task creation response, marking idempotent replays
+func respondTaskCreated(w http.ResponseWriter, taskID string, replay bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if replay {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	json.NewEncoder(w).Encode(map[string]string{"task_id": taskID})
+}
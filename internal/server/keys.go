@@ -10,6 +10,7 @@ import (
 
 	"github.com/shuliakovsky/email-checker/internal/auth"
 	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/pkg/types"
 )
 
 // generateAPIKey creates a cryptographically secure random key
@@ -212,8 +213,152 @@ func (s *Server) handleDeleteKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Очищаем кэш Redis
-	s.redisClient.Del(r.Context(), "apikey:"+apiKey)
+	// Очищаем кэш ключа
+	s.authService.InvalidateKey(r.Context(), apiKey)
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// handleSetKeyWebhook sets the default webhook of tasks created by the key
+// Requests carrying their own webhook config still use that one
+func (s *Server) handleSetKeyWebhook(w http.ResponseWriter, r *http.Request) {
+	apiKey := r.PathValue("api_key")
+	if apiKey == "" {
+		respondError(w, http.StatusBadRequest, "Missing API key parameter")
+		return
+	}
+
+	var webhook types.WebhookConfig
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+	if webhook.ClientCert != "" || webhook.ClientKey != "" {
+		// Default webhooks are stored in the key database, where private keys must not end up
+		respondError(w, http.StatusBadRequest, "Default webhooks cannot use client certificates")
+		return
+	}
+	if err := s.prepareWebhook(r.Context(), &webhook); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.updateKeyWebhook(w, r, apiKey, &webhook)
+}
+
+// handleDeleteKeyWebhook removes the default webhook of the key
+func (s *Server) handleDeleteKeyWebhook(w http.ResponseWriter, r *http.Request) {
+	apiKey := r.PathValue("api_key")
+	if apiKey == "" {
+		respondError(w, http.StatusBadRequest, "Missing API key parameter")
+		return
+	}
+	s.updateKeyWebhook(w, r, apiKey, nil)
+}
+
+// updateKeyWebhook stores the default webhook of the key (nil clears it)
+func (s *Server) updateKeyWebhook(w http.ResponseWriter, r *http.Request, apiKey string, webhook *types.WebhookConfig) {
+	var value interface{} // NULL unless a webhook is set
+	if webhook != nil {
+		data, _ := json.Marshal(webhook)
+		value = string(data)
+	}
+	result, err := s.db.ExecContext(r.Context(), `
+        UPDATE api_keys 
+        SET default_webhook = $1
+        WHERE api_key = $2`, value, apiKey)
+	if err != nil {
+		logger.Log("DB error: " + err.Error())
+		respondError(w, http.StatusInternalServerError, "Update failed")
+		return
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		respondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+}
+
+// handleKeyUsage returns aggregated usage history for a key within a time range
+func (s *Server) handleKeyUsage(w http.ResponseWriter, r *http.Request) {
+	apiKey := r.PathValue("api_key")
+	if apiKey == "" {
+		respondError(w, http.StatusBadRequest, "Missing API key parameter")
+		return
+	}
+
+	// Default range covers the last 30 days
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = parseUsageTime(v); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid 'from' parameter (use RFC3339 or YYYY-MM-DD)")
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = parseUsageEnd(v); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid 'to' parameter (use RFC3339 or YYYY-MM-DD)")
+			return
+		}
+	}
+
+	var daily []struct {
+		Day    time.Time `db:"day" json:"day"`
+		Tasks  int       `db:"tasks" json:"tasks"`
+		Checks int       `db:"checks" json:"checks"`
+	}
+
+	err = s.db.SelectContext(r.Context(), &daily, `
+        SELECT date_trunc('day', created_at) AS day,
+               COUNT(*) AS tasks,
+               SUM(checks) AS checks
+        FROM api_key_usage
+        WHERE api_key = $1 AND created_at >= $2 AND created_at < $3
+        GROUP BY day
+        ORDER BY day`, apiKey, from, to)
+
+	if err != nil {
+		logger.Log("DB error: " + err.Error())
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve usage")
+		return
+	}
+
+	totalTasks, totalChecks := 0, 0
+	for _, d := range daily {
+		totalTasks += d.Tasks
+		totalChecks += d.Checks
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"api_key": apiKey,
+		"from":    from.Format(time.RFC3339),
+		"to":      to.Format(time.RFC3339),
+		"tasks":   totalTasks,
+		"checks":  totalChecks,
+		"daily":   daily,
+	})
+}
+
+// parseUsageTime accepts RFC3339 timestamps or plain dates
+func parseUsageTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// parseUsageEnd parses the exclusive end of a usage range; a plain date includes that whole day
+func parseUsageEnd(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return t, err
+	}
+	return t.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	maxEmailLength     = 254 // Longest address accepted in strict mode (RFC 5321)
+	maxReportedEmails  = 100 // Oversized entries listed in a rejection
+	maxReportedPreview = 64  // Characters of an oversized address echoed back
+)
+
+// oversizedEmail identifies an address rejected for its length
+type oversizedEmail struct {
+	Index  int    `json:"index"`  // Position in the submitted emails array
+	Length int    `json:"length"` // Address length in bytes
+	Email  string `json:"email"`  // Leading part of the address
+}
+
+// SetStrictEmailLength rejects whole batches containing over-long addresses (default)
+// When disabled such addresses are checked and reported as invalid with address_too_long
+func (s *Server) SetStrictEmailLength(strict bool) {
+	s.lenientEmailLength = !strict
+}
+
+// rejectOversizedEmails responds 400 listing over-long addresses in strict mode
+// Returns true when the request was rejected
+func (s *Server) rejectOversizedEmails(w http.ResponseWriter, emails []string) bool {
+	if s.lenientEmailLength {
+		return false
+	}
+
+	var (
+		oversized []oversizedEmail
+		total     int
+	)
+	for i, email := range emails {
+		if len(email) <= maxEmailLength {
+			continue
+		}
+		total++
+		if len(oversized) < maxReportedEmails {
+			preview := email
+			if len(preview) > maxReportedPreview {
+				preview = preview[:maxReportedPreview] + "..."
+			}
+			oversized = append(oversized, oversizedEmail{Index: i, Length: len(email), Email: preview})
+		}
+	}
+	if total == 0 {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  fmt.Sprintf("%d email(s) longer than %d characters", total, maxEmailLength),
+		"code":   errorCode(http.StatusBadRequest),
+		"emails": oversized,
+	})
+	return true
+}
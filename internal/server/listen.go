@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/shuliakovsky/email-checker/internal/logger"
+)
+
+// proxyHeaderTimeout bounds how long a new connection may take to send its PROXY header
+const proxyHeaderTimeout = 10 * time.Second
+
+// SetTLS serves HTTPS (with HTTP/2) using the given certificate and key files; empty paths keep plain HTTP
+func (s *Server) SetTLS(certFile, keyFile string) {
+	s.tlsCert = certFile
+	s.tlsKey = keyFile
+}
+
+// SetHTTPRedirect listens for plain HTTP on addr and redirects every request to HTTPS (TLS only)
+func (s *Server) SetHTTPRedirect(addr string) {
+	s.redirectAddr = addr
+}
+
+// SetMetricsAddr serves /metrics, /healthz and /readyz on a separate internal listener at addr (host:port)
+// and removes /metrics from the public API; empty keeps /metrics on the API listener
+func (s *Server) SetMetricsAddr(addr string) {
+	s.metricsAddr = addr
+}
+
+// serveMetrics starts the internal monitoring listener
+// The address is bound before returning so a port conflict stops startup instead of going unnoticed
+func (s *Server) serveMetrics() error {
+	router := http.NewServeMux()
+	router.Handle("/metrics", promhttp.Handler())
+	router.HandleFunc("GET /healthz", s.handleHealthz)
+	router.HandleFunc("GET /readyz", s.handleReadyz)
+
+	ln, err := net.Listen("tcp", s.metricsAddr)
+	if err != nil {
+		return fmt.Errorf("metrics listener: %w", err)
+	}
+	srv := &http.Server{Handler: router}
+	s.track(srv)
+	go func() {
+		if err := serveResult(srv.Serve(ln)); err != nil {
+			logger.Log(fmt.Sprintf("[WARN] Metrics listener on %s stopped: %v", s.metricsAddr, err))
+		}
+	}()
+	logger.Log(fmt.Sprintf("Serving metrics on %s", s.metricsAddr))
+	return nil
+}
+
+// SetProxyProtocol expects a PROXY protocol v1/v2 header on every API connection, as sent by
+// HAProxy or an AWS NLB, so request logs see the client address instead of the load balancer's
+// Connections without a header are refused; only the load balancer should reach the port
+func (s *Server) SetProxyProtocol(enabled bool) {
+	s.proxyProtocol = enabled
+}
+
+// bind opens a public TCP listener at addr, parsing PROXY headers when enabled
+func (s *Server) bind(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if s.proxyProtocol {
+		return &proxyproto.Listener{
+			Listener:          ln,
+			Policy:            requireProxyHeader,
+			ReadHeaderTimeout: proxyHeaderTimeout,
+		}, nil
+	}
+	return ln, nil
+}
+
+// requireProxyHeader refuses connections that do not start with a PROXY header, whatever their peer
+func requireProxyHeader(net.Addr) (proxyproto.Policy, error) {
+	return proxyproto.REQUIRE, nil
+}
+
+// listen serves handler over HTTP, or HTTPS when a certificate is configured, until Shutdown is called
+func (s *Server) listen(handler http.Handler) error {
+	srv := &http.Server{Addr: s.addr(), Handler: handler}
+	ln, err := s.bind(srv.Addr)
+	if err != nil {
+		return err
+	}
+	if s.tlsCert == "" {
+		s.track(srv)
+		return serveResult(srv.Serve(ln))
+	}
+
+	// HTTP/2 is negotiated automatically over TLS
+	srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	s.track(srv)
+	if s.redirectAddr != "" {
+		redirect := &http.Server{Addr: s.redirectAddr, Handler: http.HandlerFunc(s.redirectToHTTPS)}
+		redirectLn, err := s.bind(s.redirectAddr)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		s.track(redirect)
+		go func() {
+			if err := serveResult(redirect.Serve(redirectLn)); err != nil {
+				logger.Log(fmt.Sprintf("[WARN] HTTP redirect listener on %s stopped: %v", s.redirectAddr, err))
+			}
+		}()
+	}
+	return serveResult(srv.ServeTLS(ln, s.tlsCert, s.tlsKey))
+}
+
+// addr is the host:port the API listener binds to
+func (s *Server) addr() string {
+	return net.JoinHostPort(s.host, s.port)
+}
+
+// redirectToHTTPS sends plain HTTP clients to the same path on the TLS listener
+func (s *Server) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if s.port != "443" {
+		host = net.JoinHostPort(host, s.port)
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+// track registers a listener so Shutdown can stop it
+func (s *Server) track(srv *http.Server) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.listeners = append(s.listeners, srv)
+}
+
+// Shutdown gracefully stops every listener, waiting for in-flight requests until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.listenersMu.Lock()
+	listeners := s.listeners
+	s.listenersMu.Unlock()
+
+	var errs []error
+	for _, srv := range listeners {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// serveResult treats a graceful shutdown as a clean exit
+func serveResult(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// clientIP returns the address of the client that sent r, taken from the PROXY header when enabled
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
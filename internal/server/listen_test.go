@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxyV2Header builds a binary PROXY header for a TCP over IPv4 connection with optional TLVs
+func proxyV2Header(command byte, src, dst string, srcPort, dstPort uint16, tlvs ...[]byte) []byte {
+	var body bytes.Buffer
+	body.Write(net.ParseIP(src).To4())
+	body.Write(net.ParseIP(dst).To4())
+	binary.Write(&body, binary.BigEndian, srcPort)
+	binary.Write(&body, binary.BigEndian, dstPort)
+	for _, tlv := range tlvs {
+		body.Write(tlv)
+	}
+	header := []byte("\r\n\r\n\x00\r\nQUIT\n")
+	header = append(header, 0x20|command, 0x11) // Version 2; TCP over IPv4
+	header = binary.BigEndian.AppendUint16(header, uint16(body.Len()))
+	return append(header, body.Bytes()...)
+}
+
+// tlv encodes one type-length-value entry of a v2 header
+func tlv(kind byte, value string) []byte {
+	entry := []byte{kind}
+	entry = binary.BigEndian.AppendUint16(entry, uint16(len(value)))
+	return append(entry, value...)
+}
+
+// sendThroughProxyListener writes data on a new connection to a PROXY protocol listener and closes the write side;
+// it returns the remote address the server sees and what the server reads from the connection
+func sendThroughProxyListener(t *testing.T, data []byte) (net.Addr, string, error) {
+	t.Helper()
+	s := &Server{proxyProtocol: true}
+	ln, err := s.bind("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	go func() {
+		client.Write(data)
+		client.(*net.TCPConn).CloseWrite()
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	payload, err := io.ReadAll(conn)
+	return conn.RemoteAddr(), string(payload), err
+}
+
+func TestProxyProtocolHeaders(t *testing.T) {
+	const request = "GET / HTTP/1.1\r\n\r\n"
+	tests := []struct {
+		name   string
+		header []byte
+		source string // Expected client address; empty means the TCP peer
+	}{
+		{"v1 tcp4", []byte("PROXY TCP4 203.0.113.7 198.51.100.1 51234 443\r\n"), "203.0.113.7:51234"},
+		{"v1 tcp6", []byte("PROXY TCP6 2001:db8::7 2001:db8::1 51234 443\r\n"), "[2001:db8::7]:51234"},
+		{"v1 unknown", []byte("PROXY UNKNOWN\r\n"), ""},
+		{"v2 proxy", proxyV2Header(0x1, "203.0.113.9", "198.51.100.1", 40000, 443), "203.0.113.9:40000"},
+		{"v2 proxy with tlvs", proxyV2Header(0x1, "203.0.113.10", "198.51.100.1", 40001, 443,
+			tlv(0x02, "api.example.com"), tlv(0x05, "unique-id")), "203.0.113.10:40001"},
+		{"v2 local", proxyV2Header(0x0, "0.0.0.0", "0.0.0.0", 0, 0), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, payload, err := sendThroughProxyListener(t, append(tt.header, request...))
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if payload != request {
+				t.Fatalf("payload = %q, want the request after the header", payload)
+			}
+			want := tt.source
+			if want == "" {
+				if host, _, _ := net.SplitHostPort(addr.String()); host != "127.0.0.1" {
+					t.Fatalf("remote address = %s, want the TCP peer", addr)
+				}
+				return
+			}
+			if addr.String() != want {
+				t.Fatalf("remote address = %s, want %s", addr, want)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolRejectsInvalidHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"missing header", []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")},
+		{"empty connection", nil},
+		{"v1 oversize line", []byte("PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n")},
+		{"v1 bad address", []byte("PROXY TCP4 203.0.113.300 198.51.100.1 51234 443\r\n")},
+		{"v1 bad port", []byte("PROXY TCP4 203.0.113.7 198.51.100.1 99999 443\r\n")},
+		{"v1 missing fields", []byte("PROXY TCP4 203.0.113.7\r\n")},
+		{"v1 family mismatch", []byte("PROXY TCP6 203.0.113.7 198.51.100.1 51234 443\r\n")},
+		{"v1 without crlf", []byte("PROXY TCP4 203.0.113.7 198.51.100.1 51234 443\n")},
+		{"v2 bad version", append(proxyV2Header(0x1, "203.0.113.9", "198.51.100.1", 1, 2)[:12], 0x31, 0x11, 0, 12)},
+		{"v2 length beyond data", append(proxyV2Header(0x1, "203.0.113.9", "198.51.100.1", 1, 2)[:14], 0x01, 0x00)},
+		{"v2 length too short for ipv4", append(proxyV2Header(0x1, "203.0.113.9", "198.51.100.1", 1, 2)[:14], 0, 4, 1, 2, 3, 4)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, payload, err := sendThroughProxyListener(t, tt.data); err == nil {
+				t.Fatalf("connection accepted, read %q", payload)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolRejectsTruncatedHeaders(t *testing.T) {
+	headers := map[string][]byte{
+		"v1": []byte("PROXY TCP4 203.0.113.7 198.51.100.1 51234 443\r\n"),
+		"v2": proxyV2Header(0x1, "203.0.113.9", "198.51.100.1", 40000, 443, tlv(0x02, "api.example.com")),
+	}
+	for name, header := range headers {
+		// Every strict prefix of a valid header must fail instead of being taken for a header or for data
+		for n := 0; n < len(header); n++ {
+			if _, payload, err := sendThroughProxyListener(t, header[:n]); err == nil {
+				t.Fatalf("%s header truncated to %d bytes was accepted, read %q", name, n, payload)
+			}
+		}
+	}
+}
@@ -5,12 +5,22 @@ import (
 	"encoding/json"
 	"github.com/spf13/viper"
 	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 
 	"github.com/shuliakovsky/email-checker/internal/auth"
 	"github.com/shuliakovsky/email-checker/internal/logger"
 	"github.com/shuliakovsky/email-checker/internal/metrics"
+	"github.com/shuliakovsky/email-checker/internal/tracing"
 )
 
+// requestIDPattern limits accepted X-Request-ID values to short log-safe tokens
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
 // APIKeyMiddleware validates API keys and enforces authentication
 func APIKeyMiddleware(authService *auth.AuthService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -40,23 +50,74 @@ func APIKeyMiddleware(authService *auth.AuthService) func(http.Handler) http.Han
 	}
 }
 
-// respondError sends standardized JSON error responses
+// respondError sends standardized JSON error responses: {"error": message, "code": errorCode(status)}
 func respondError(w http.ResponseWriter, code int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	if err := json.NewEncoder(w).Encode(map[string]string{"error": message}); err != nil {
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": message, "code": errorCode(code)}); err != nil {
 		logger.Log("Failed to write error response: " + err.Error())
 	}
 }
 
+// errorCode returns the machine-readable code of an HTTP error status, e.g. "not_found" for 404
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// requestIDMiddleware assigns every request a correlation ID
+// A well-formed incoming X-Request-ID is reused, otherwise a UUID is generated; the ID is
+// echoed in the response header and stored in the request context for log lines
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// tracingMiddleware records a server span per request, continuing an incoming W3C traceparent
+// The span is named after the matched route pattern to keep names low-cardinality
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.StartServer(r.Context(), propagation.HeaderCarrier(r.Header), "HTTP "+r.Method)
+		defer span.End()
+
+		lrw := newLoggingResponseWriter(w)
+		r = r.WithContext(ctx)
+		next.ServeHTTP(lrw, r)
+
+		if r.Pattern != "" {
+			span.SetName("HTTP " + r.Method + " " + strings.TrimPrefix(r.Pattern, r.Method+" "))
+		}
+		span.SetAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.Int("http.response.status_code", lrw.statusCode),
+		)
+		if lrw.statusCode >= http.StatusInternalServerError {
+			tracing.Fail(span, http.StatusText(lrw.statusCode))
+		}
+	})
+}
+
 // corsMiddleware handles Cross-Origin Resource Sharing headers
-// TODO: Move CORS configuration to external config
-func corsMiddleware(next http.Handler) http.Handler {
+// Any origin is allowed unless SetCORSOrigins restricts them
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set permissive CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if origin := s.allowedOrigin(r.Header.Get("Origin")); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && len(s.corsOrigins) > 0 {
+			w.Header().Add("Vary", "Origin") // The allowed origin depends on the request
+		}
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Request-ID")
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
@@ -68,6 +129,27 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// allowedOrigin returns the Access-Control-Allow-Origin value for a request origin ("" to omit it)
+func (s *Server) allowedOrigin(origin string) string {
+	if len(s.corsOrigins) == 0 {
+		return "*"
+	}
+	for _, allowed := range s.corsOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// SetCORSOrigins restricts cross-origin requests to the given origins (empty or "*" allows any)
+func (s *Server) SetCORSOrigins(origins []string) {
+	s.corsOrigins = origins
+}
+
 // AdminMiddleware enforces admin-level access control
 func AdminMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/storage"
+)
+
+func TestHandlerErrorsShareOneJSONShape(t *testing.T) {
+	s := &Server{storage: storage.NewMemoryStorage(nil)}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+		status  int
+		code    string
+	}{
+		{"tasks method", s.handleTasks, http.MethodGet, "/tasks", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"tasks body", s.handleTasks, http.MethodPost, "/tasks", "{", http.StatusBadRequest, "bad_request"},
+		{"task status", s.handleTaskStatus, http.MethodGet, "/tasks/missing", "", http.StatusNotFound, "not_found"},
+		{"task results", s.handleTaskResults, http.MethodGet, "/tasks-results/missing", "", http.StatusNotFound, "not_found"},
+		{"webhook tasks method", s.handleTasksWithWebhook, http.MethodGet, "/tasks-with-webhook", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"webhook tasks body", s.handleTasksWithWebhook, http.MethodPost, "/tasks-with-webhook", "{", http.StatusBadRequest, "bad_request"},
+		{"check method", s.handleCheck, http.MethodDelete, "/check", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"check body", s.handleCheck, http.MethodPost, "/check", "{", http.StatusBadRequest, "bad_request"},
+		{"check batch empty", s.handleCheckBatch, http.MethodPost, "/check-batch", `{"emails": []}`, http.StatusBadRequest, "bad_request"},
+		{"cache flush method", s.handleFlushCache, http.MethodGet, "/cache/flush", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			key := &auth.APIKey{Key: "key", Type: auth.KeyTypePayAsYouGo, Remaining: 100}
+			req = req.WithContext(context.WithValue(req.Context(), "api_key", key))
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("Content-Type = %q, want application/json", ct)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %q is not a JSON object of strings: %v", rec.Body, err)
+			}
+			if len(body) != 2 || body["error"] == "" || body["code"] != tt.code {
+				t.Fatalf("body = %v, want {error, code: %s}", body, tt.code)
+			}
+		})
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	for status, want := range map[int]string{
+		http.StatusNotFound:            "not_found",
+		http.StatusTooManyRequests:     "too_many_requests",
+		http.StatusInternalServerError: "internal_server_error",
+		599:                            "error",
+	} {
+		if got := errorCode(status); got != want {
+			t.Errorf("errorCode(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
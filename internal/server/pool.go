@@ -0,0 +1,120 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// idleRetryDelay is how long a task loop waits after finding the queue empty
+const idleRetryDelay = time.Second
+
+// workerPool runs a resizable set of task processing loops
+// A stopped loop finishes the task it is processing before it exits
+type workerPool struct {
+	mu      sync.Mutex
+	loop    func(stop <-chan struct{}) // One task processing loop, returning once stop is closed
+	stops   []chan struct{}            // Stop channel of every loop still wanted
+	running atomic.Int64               // Loops not exited yet, including stopped ones finishing a task
+}
+
+// newWorkerPool creates an empty pool running loop in each worker
+func newWorkerPool(loop func(stop <-chan struct{})) *workerPool {
+	return &workerPool{loop: loop}
+}
+
+// resize starts or stops loops until n of them are wanted
+func (p *workerPool) resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.stops) < n {
+		stop := make(chan struct{})
+		p.stops = append(p.stops, stop)
+		p.running.Add(1)
+		go func() {
+			defer p.running.Add(-1)
+			p.loop(stop)
+		}()
+	}
+	for len(p.stops) > n {
+		last := len(p.stops) - 1
+		close(p.stops[last])
+		p.stops = p.stops[:last]
+	}
+}
+
+// size returns the target number of loops
+func (p *workerPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.stops)
+}
+
+// active returns the number of loops still running, which exceeds size while stopped loops finish their task
+func (p *workerPool) active() int {
+	return int(p.running.Load())
+}
+
+// stopped reports whether stop is closed
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// idle waits idleRetryDelay unless the loop is stopped meanwhile
+func idle(stop <-chan struct{}) {
+	select {
+	case <-stop:
+	case <-time.After(idleRetryDelay):
+	}
+}
+
+// startTaskPool starts the task loops of the processing mode, sized by the task concurrency
+func (s *Server) startTaskPool(loop func(stop <-chan struct{})) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.taskPool = newWorkerPool(loop)
+	s.taskPool.resize(s.concurrentTasks())
+}
+
+// SetTaskConcurrency sets how many tasks a node processes at once; each task runs maxWorkers email workers
+// On a running server the task loops are resized: new ones start right away, surplus ones exit after their current task
+func (s *Server) SetTaskConcurrency(n int) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.taskConcurrency = n
+	if s.taskPool != nil {
+		s.taskPool.resize(s.concurrentTasks())
+	}
+}
+
+// targetTaskLoops returns the number of task loops a node is meant to run
+func (s *Server) targetTaskLoops() int {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.concurrentTasks()
+}
+
+// concurrentTasks returns the number of task processing loops (DefaultTaskConcurrency if unset)
+// Callers hold settingsMu
+func (s *Server) concurrentTasks() int {
+	if s.taskConcurrency > 0 {
+		return s.taskConcurrency
+	}
+	return DefaultTaskConcurrency
+}
+
+// activeTaskLoops returns the task loops still running (0 before Start)
+func (s *Server) activeTaskLoops() int {
+	s.settingsMu.RLock()
+	pool := s.taskPool
+	s.settingsMu.RUnlock()
+	if pool == nil {
+		return 0
+	}
+	return pool.active()
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/storage"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// blockingTasks counts the tasks its loops process at once; each task runs until release is closed
+type blockingTasks struct {
+	store   *storage.MemoryStorage
+	running atomic.Int64
+	release chan struct{}
+}
+
+// loop dequeues tasks like localWorker and holds each one until released
+func (b *blockingTasks) loop(stop <-chan struct{}) {
+	for !stopped(stop) {
+		if _, err := b.store.DequeueTask(); err != nil {
+			idle(stop)
+			continue
+		}
+		b.running.Add(1)
+		<-b.release
+		b.running.Add(-1)
+	}
+}
+
+// waitRunning waits until exactly n tasks are processed at once
+func waitRunning(t *testing.T, b *blockingTasks, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if b.running.Load() == int64(n) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("running tasks = %d, want %d", b.running.Load(), n)
+}
+
+func TestWorkerPoolResizeChangesConcurrentTasks(t *testing.T) {
+	b := &blockingTasks{store: storage.NewMemoryStorage(nil), release: make(chan struct{})}
+	for i := 0; i < 10; i++ {
+		b.store.EnqueueTask(&types.Task{ID: fmt.Sprintf("task-%d", i)})
+	}
+
+	pool := newWorkerPool(b.loop)
+	pool.resize(2)
+	waitRunning(t, b, 2)
+	time.Sleep(50 * time.Millisecond) // Extra loops would have picked up more tasks by now
+	if got := b.running.Load(); got != 2 {
+		t.Fatalf("running tasks = %d, want 2", got)
+	}
+
+	pool.resize(5)
+	waitRunning(t, b, 5)
+	if got := pool.size(); got != 5 {
+		t.Fatalf("size = %d, want 5", got)
+	}
+
+	// Stopped loops finish their current task before exiting
+	pool.resize(1)
+	if got := pool.active(); got != 5 {
+		t.Fatalf("active after shrinking = %d, want 5 while tasks run", got)
+	}
+	close(b.release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.active() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := pool.active(); got != 1 {
+		t.Fatalf("active = %d, want 1 after surplus loops finished", got)
+	}
+	pool.resize(0)
+}
+
+func TestSetTaskConcurrencyResizesRunningPool(t *testing.T) {
+	b := &blockingTasks{store: storage.NewMemoryStorage(nil), release: make(chan struct{})}
+	for i := 0; i < 10; i++ {
+		b.store.EnqueueTask(&types.Task{ID: fmt.Sprintf("task-%d", i)})
+	}
+
+	s := &Server{taskConcurrency: 1}
+	s.startTaskPool(b.loop)
+	waitRunning(t, b, 1)
+
+	s.SetTaskConcurrency(3)
+	waitRunning(t, b, 3)
+	if got := s.targetTaskLoops(); got != 3 {
+		t.Fatalf("targetTaskLoops = %d, want 3", got)
+	}
+
+	close(b.release)
+	s.SetTaskConcurrency(0) // Falls back to the default
+	if got := s.targetTaskLoops(); got != DefaultTaskConcurrency {
+		t.Fatalf("targetTaskLoops = %d, want default %d", got, DefaultTaskConcurrency)
+	}
+	s.taskPool.resize(0)
+}
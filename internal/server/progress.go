@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/checker"
+	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/internal/storage"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// taskProgress persists the results of a running task as they arrive and tallies what completion
+// and billing need, so only the reports awaiting the next save are held in memory
+type taskProgress struct {
+	storage  storage.Storage
+	task     *types.Task
+	pending  []types.EmailReport // Reports not saved yet
+	summary  types.TaskSummary   // Outcome breakdown of all results
+	skipped  int                 // Results not checked before the deadline
+	charged  map[string]struct{} // Distinct checked addresses, billed once each
+	lastSave time.Time
+}
+
+// newTaskProgress starts tallying task, counting the results it already holds (e.g. after a requeue)
+func newTaskProgress(store storage.Storage, task *types.Task) *taskProgress {
+	p := &taskProgress{
+		storage:  store,
+		task:     task,
+		charged:  make(map[string]struct{}),
+		lastSave: time.Now(),
+	}
+	for _, report := range task.Results {
+		p.count(report)
+	}
+	return p
+}
+
+// count adds one report to the tallies
+func (p *taskProgress) count(report types.EmailReport) {
+	if report.ErrorCategory == checker.NotChecked {
+		p.skipped++
+		return
+	}
+	checker.AddToSummary(&p.summary, report)
+	p.charged[checker.NormalizeEmail(report.Email)] = struct{}{}
+}
+
+// add records a report, saving pending reports every progressInterval or progressBatch reports
+func (p *taskProgress) add(report types.EmailReport) {
+	p.count(report)
+	p.pending = append(p.pending, report)
+	if len(p.pending) >= progressBatch || time.Since(p.lastSave) >= progressInterval {
+		p.save()
+	}
+}
+
+// save appends the pending reports to the stored task along with its current metadata
+// Reports that failed to save are kept and retried with the next save
+func (p *taskProgress) save() {
+	p.lastSave = time.Now()
+	if err := p.storage.AppendTaskResults(context.Background(), p.task, p.pending); err != nil {
+		logger.Log(fmt.Sprintf("[Task] Failed to save progress of %s: %v", p.task.ID, err))
+		return
+	}
+	p.pending = p.pending[:0]
+}
+
+// complete marks the task finished with its status and outcome summary and saves the last reports
+func (p *taskProgress) complete() {
+	summary := p.summary
+	p.task.CompletedAt = time.Now()
+	p.task.Status = "completed"
+	if p.skipped > 0 {
+		p.task.Status = "completed_partial" // Cut short by the timeout
+	}
+	p.task.Summary = &summary
+	p.save()
+}
+
+// chargedEmails returns the number of distinct addresses checked so far
+func (p *taskProgress) chargedEmails() int {
+	return len(p.charged)
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/shuliakovsky/email-checker/internal/lock"
+	"github.com/shuliakovsky/email-checker/internal/storage"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// newClusterTestServer returns a cluster-mode server backed by an in-process Redis
+func newClusterTestServer(t *testing.T) (*Server, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &Server{
+		storage:     storage.NewRedisStorage(client),
+		redisClient: client,
+		clusterMode: true,
+		maxWorkers:  1,
+	}, mr
+}
+
+func TestStalledTaskIsRequeuedAndReprocessed(t *testing.T) {
+	s, mr := newClusterTestServer(t)
+	ctx := context.Background()
+
+	// A node took the task and died: its lock is no longer refreshed and is about to expire
+	task := &types.Task{ID: "stalled", Status: "processing", Emails: []string{"not-an-email"}, CreatedAt: time.Now()}
+	if err := s.storage.SaveTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	lockKey := "lock:task:" + task.ID
+	mr.Set(lockKey, "lock:dead-node")
+	mr.SetTTL(lockKey, stalledLockTTL/2)
+
+	if err := s.recoverStalledLocks(ctx); err != nil {
+		t.Fatalf("recoverStalledLocks: %v", err)
+	}
+	if mr.Exists(lockKey) {
+		t.Fatal("stalled lock was not removed")
+	}
+	if depth, _ := s.storage.QueueLen(); depth != 1 {
+		t.Fatalf("queue depth = %d, want 1", depth)
+	}
+
+	// The queue holds the full task, so a task loop can decode and process it
+	queued, err := s.dequeueTaskWithLock()
+	if err != nil {
+		t.Fatalf("dequeueTaskWithLock: %v", err)
+	}
+	if queued.ID != task.ID || queued.Status != "pending" || len(queued.Emails) != 1 {
+		t.Fatalf("dequeued %+v, want pending task %s with its emails", queued, task.ID)
+	}
+	s.processClusterTask(queued)
+
+	stored, err := s.storage.GetTask(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != "completed" || len(stored.Results) != 1 {
+		t.Fatalf("reprocessed task has status %q and %d results, want completed with 1", stored.Status, len(stored.Results))
+	}
+	if mr.Exists(lockKey) {
+		t.Fatal("processing lock was not released")
+	}
+}
+
+func TestActiveTaskLockIsNotRecovered(t *testing.T) {
+	s, mr := newClusterTestServer(t)
+	ctx := context.Background()
+
+	task := &types.Task{ID: "active", Status: "processing", Emails: []string{"not-an-email"}, CreatedAt: time.Now()}
+	if err := s.storage.SaveTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	lockKey := "lock:task:" + task.ID
+	mr.Set(lockKey, "lock:live-node")
+	mr.SetTTL(lockKey, 5*time.Minute) // Still refreshed by its node
+
+	if err := s.recoverStalledLocks(ctx); err != nil {
+		t.Fatalf("recoverStalledLocks: %v", err)
+	}
+	if !mr.Exists(lockKey) {
+		t.Fatal("lock of an active task was removed")
+	}
+	if depth, _ := s.storage.QueueLen(); depth != 0 {
+		t.Fatalf("queue depth = %d, want 0", depth)
+	}
+}
+
+func TestNewTaskTakesProcessingLock(t *testing.T) {
+	s, mr := newClusterTestServer(t)
+
+	// Created tasks go through the queue, so the task loop holds lock:task:<id> while it runs
+	task := &types.Task{ID: "new", Status: "pending", Emails: []string{"not-an-email"}, CreatedAt: time.Now()}
+	if err := s.storage.SaveTask(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.storage.EnqueueTask(task); err != nil {
+		t.Fatal(err)
+	}
+	queued, err := s.dequeueTaskWithLock()
+	if err != nil {
+		t.Fatalf("dequeueTaskWithLock: %v", err)
+	}
+
+	locked := make(chan bool, 1)
+	s.storage = &lockProbe{Storage: s.storage, onUpdate: func() {
+		select {
+		case locked <- mr.Exists("lock:task:" + task.ID):
+		default:
+		}
+	}}
+	s.processClusterTask(queued)
+	if !<-locked {
+		t.Fatal("task was processed without holding its lock")
+	}
+}
+
+// lockProbe calls onUpdate whenever a task is updated while processing
+type lockProbe struct {
+	storage.Storage
+	onUpdate func()
+}
+
+func (p *lockProbe) UpdateTask(ctx context.Context, task *types.Task) error {
+	p.onUpdate()
+	return p.Storage.UpdateTask(ctx, task)
+}
+
+func TestStalledRedlockTaskIsRequeued(t *testing.T) {
+	s, mr := newClusterTestServer(t)
+	ctx := context.Background()
+
+	// With Redlock the task locks live on the independent nodes only
+	var nodes []*miniredis.Miniredis
+	var clients []redis.UniversalClient
+	for range 3 {
+		node := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: node.Addr()})
+		t.Cleanup(func() { client.Close() })
+		nodes = append(nodes, node)
+		clients = append(clients, client)
+	}
+	lock.SetRedlockClients(clients)
+	t.Cleanup(func() { lock.SetRedlockClients(nil) })
+
+	task := &types.Task{ID: "stalled", Status: "processing", Emails: []string{"not-an-email"}, CreatedAt: time.Now()}
+	if err := s.storage.SaveTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	lockKey := "lock:task:" + task.ID
+	for _, node := range nodes {
+		node.Set(lockKey, "lock:dead-node")
+		node.SetTTL(lockKey, stalledLockTTL/2)
+	}
+
+	if err := s.recoverStalledLocks(ctx); err != nil {
+		t.Fatalf("recoverStalledLocks: %v", err)
+	}
+	for _, node := range nodes {
+		if node.Exists(lockKey) {
+			t.Fatal("stalled lock was not removed from a Redlock node")
+		}
+	}
+	if depth, _ := s.storage.QueueLen(); depth != 1 {
+		t.Fatalf("queue depth = %d, want 1", depth)
+	}
+
+	// A second recovery pass, e.g. on another node, does not queue the task again
+	for _, node := range nodes {
+		node.Set(lockKey, "lock:dead-node")
+		node.SetTTL(lockKey, stalledLockTTL/2)
+	}
+	if err := s.recoverStalledLocks(ctx); err != nil {
+		t.Fatalf("recoverStalledLocks: %v", err)
+	}
+	if depth, _ := s.storage.QueueLen(); depth != 1 {
+		t.Fatalf("queue depth after a second pass = %d, want 1", depth)
+	}
+
+	// Admin requeue clears the lock where it is kept
+	cleared, err := s.clearTaskLock(ctx, task.ID)
+	if err != nil || !cleared {
+		t.Fatalf("clearTaskLock = %v, %v; want true, nil", cleared, err)
+	}
+	if mr.Exists(lockKey) {
+		t.Fatal("task lock unexpectedly kept on the main Redis")
+	}
+}
+
+func TestRequeuedTaskResumesFromStoredResults(t *testing.T) {
+	s, _ := newClusterTestServer(t)
+	ctx := context.Background()
+
+	task := &types.Task{ID: "resumed", Status: "processing", Emails: []string{"done-email", "not-an-email"}, CreatedAt: time.Now()}
+	if err := s.storage.SaveTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.storage.AppendTaskResults(ctx, task, []types.EmailReport{{Email: "done-email"}}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.storage.GetTask(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.requeueTask(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	// The queue entry carries no results; the worker picks them up from storage
+	queued, err := s.dequeueTaskWithLock()
+	if err != nil {
+		t.Fatalf("dequeueTaskWithLock: %v", err)
+	}
+	if len(queued.Results) != 0 {
+		t.Fatalf("queued task carries %d results, want none", len(queued.Results))
+	}
+	s.processClusterTask(queued)
+
+	stored, err = s.storage.GetTask(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != "completed" || len(stored.Results) != 2 {
+		t.Fatalf("resumed task has status %q and %d results, want completed with 2", stored.Status, len(stored.Results))
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/shuliakovsky/email-checker/internal/lock"
+	"github.com/shuliakovsky/email-checker/internal/logger"
+)
+
+// RequeueResponse is returned by POST /admin/tasks/{task_id}/requeue
+type RequeueResponse struct {
+	TaskID         string `json:"task_id"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status"` // Status the task was stuck in
+	KeptResults    int    `json:"kept_results"`    // Stored results the requeued task resumes from
+	LockCleared    bool   `json:"lock_cleared"`    // Whether a processing lock was still held
+}
+
+// handleRequeueTask puts a task stuck in pending or processing back on the queue
+// Any processing lock is removed first so another worker can pick the task up;
+// with reset=true stored partial results are discarded and the task starts over
+func (s *Server) handleRequeueTask(w http.ResponseWriter, r *http.Request) {
+	reset := false
+	if value := r.URL.Query().Get("reset"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "reset must be true or false")
+			return
+		}
+		reset = parsed
+	}
+
+	task, err := s.storage.GetTask(r.Context(), r.PathValue("task_id"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if taskFinished(task) {
+		respondError(w, http.StatusConflict, "Task is already completed")
+		return
+	}
+
+	lockCleared, err := s.clearTaskLock(r.Context(), task.ID)
+	if err != nil {
+		logger.Log(fmt.Sprintf("[Task] Failed to clear lock of %s: %v", task.ID, err))
+		respondError(w, http.StatusInternalServerError, "Failed to clear task lock")
+		return
+	}
+
+	previous := task.Status
+	if reset {
+		task.Results = nil
+		task.Summary = nil
+		if err := s.storage.SaveTask(r.Context(), task); err != nil { // Replaces the stored results
+			logger.Log(fmt.Sprintf("[Task] Failed to reset %s: %v", task.ID, err))
+			respondError(w, http.StatusInternalServerError, "Failed to reset task results")
+			return
+		}
+	}
+	if err := s.requeueTask(r.Context(), task); err != nil {
+		logger.Log(fmt.Sprintf("[Task] Failed to re-queue %s: %v", task.ID, err))
+		respondError(w, http.StatusInternalServerError, "Failed to re-queue task")
+		return
+	}
+	logger.LogFields("[Admin] Task re-queued", logger.Fields{
+		"task_id":    task.ID,
+		"request_id": logger.RequestID(r.Context()),
+		"reset":      strconv.FormatBool(reset),
+		"kept":       strconv.Itoa(len(task.Results)),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(RequeueResponse{
+		TaskID:         task.ID,
+		Status:         task.Status,
+		PreviousStatus: previous,
+		KeptResults:    len(task.Results),
+		LockCleared:    lockCleared,
+	})
+}
+
+// clearTaskLock deletes the cluster processing lock of a task, reporting whether one existed
+// The lock is removed from every node it is kept on, the Redlock nodes when configured; local mode keeps no locks
+func (s *Server) clearTaskLock(ctx context.Context, taskID string) (bool, error) {
+	if s.redisClient == nil {
+		return false, nil
+	}
+	return lock.Remove(ctx, lock.Nodes(s.redisClient), fmt.Sprintf("lock:task:%s", taskID))
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/internal/smtp"
+)
+
+// RuntimeConfig holds the settings tunable through /admin/config
+// In PATCH requests and the overrides file, unset fields keep their current value
+type RuntimeConfig struct {
+	SMTPConnectTimeout string `json:"smtp_connect_timeout,omitempty"` // e.g. "3s"
+	SMTPCommandTimeout string `json:"smtp_command_timeout,omitempty"` // e.g. "8s"
+	SMTPMaxRetries     *int   `json:"smtp_max_retries,omitempty"`     // Attempts per host and port
+	SMTPRetryDelay     string `json:"smtp_retry_delay,omitempty"`     // e.g. "1s"
+	SMTPIPPreference   string `json:"smtp_ip_preference,omitempty"`   // any, ipv4 or ipv6
+	ThrottleTTL        string `json:"throttle_ttl,omitempty"`         // Block duration of throttled domains
+	TaskConcurrency    *int   `json:"task_concurrency,omitempty"`     // Task loops of the node, resized without a restart
+}
+
+// SetRuntimeConfigFile persists /admin/config overrides to path and applies the ones saved by a previous run
+func (s *Server) SetRuntimeConfigFile(path string) error {
+	s.runtimeMu.Lock()
+	defer s.runtimeMu.Unlock()
+	s.runtimeFile = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved RuntimeConfig
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	if err := s.applyRuntimeConfig(saved); err != nil {
+		return fmt.Errorf("apply %s: %w", path, err)
+	}
+	s.runtimeOverrides = saved
+	logger.Log(fmt.Sprintf("[Config] Applied runtime overrides from %s", path))
+	return nil
+}
+
+// ReapplyRuntimeOverrides applies the /admin/config overrides again so they keep precedence
+// over settings re-read from the config file
+func (s *Server) ReapplyRuntimeOverrides() {
+	s.runtimeMu.Lock()
+	defer s.runtimeMu.Unlock()
+	if err := s.applyRuntimeConfig(s.runtimeOverrides); err != nil {
+		logger.Log(fmt.Sprintf("[WARN] Failed to reapply runtime overrides: %v", err))
+	}
+}
+
+// currentRuntimeConfig reports the effective values of every tunable setting
+func (s *Server) currentRuntimeConfig() RuntimeConfig {
+	opts := smtp.GetOptions()
+	taskLoops := s.targetTaskLoops()
+	return RuntimeConfig{
+		SMTPConnectTimeout: opts.ConnectTimeout.String(),
+		SMTPCommandTimeout: opts.CommandTimeout.String(),
+		SMTPMaxRetries:     &opts.MaxRetries,
+		SMTPRetryDelay:     opts.RetryDelay.String(),
+		SMTPIPPreference:   string(opts.IPPreference),
+		ThrottleTTL:        s.throttleManager.TTL().String(),
+		TaskConcurrency:    &taskLoops,
+	}
+}
+
+// applyRuntimeConfig validates the set fields and applies them all, or none on error
+func (s *Server) applyRuntimeConfig(cfg RuntimeConfig) error {
+	opts := smtp.GetOptions()
+	throttleTTL := s.throttleManager.TTL()
+
+	durations := []struct {
+		name  string
+		value string
+		min   time.Duration
+		dst   *time.Duration
+	}{
+		{"smtp_connect_timeout", cfg.SMTPConnectTimeout, time.Millisecond, &opts.ConnectTimeout},
+		{"smtp_command_timeout", cfg.SMTPCommandTimeout, time.Millisecond, &opts.CommandTimeout},
+		{"smtp_retry_delay", cfg.SMTPRetryDelay, 0, &opts.RetryDelay},
+		{"throttle_ttl", cfg.ThrottleTTL, time.Second, &throttleTTL},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed < d.min {
+			return fmt.Errorf("invalid %s %q", d.name, d.value)
+		}
+		*d.dst = parsed
+	}
+	if cfg.SMTPMaxRetries != nil {
+		if *cfg.SMTPMaxRetries < 1 {
+			return fmt.Errorf("invalid smtp_max_retries %d (must be at least 1)", *cfg.SMTPMaxRetries)
+		}
+		opts.MaxRetries = *cfg.SMTPMaxRetries
+	}
+	if cfg.SMTPIPPreference != "" {
+		preference, err := smtp.ParseIPPreference(cfg.SMTPIPPreference)
+		if err != nil {
+			return err
+		}
+		opts.IPPreference = preference
+	}
+	if cfg.TaskConcurrency != nil && *cfg.TaskConcurrency < 1 {
+		return fmt.Errorf("invalid task_concurrency %d (must be at least 1)", *cfg.TaskConcurrency)
+	}
+
+	smtp.SetOptions(opts)
+	s.throttleManager.SetTTL(throttleTTL)
+	if cfg.TaskConcurrency != nil {
+		s.SetTaskConcurrency(*cfg.TaskConcurrency)
+	}
+	return nil
+}
+
+// mergeRuntimeConfig overlays the set fields of patch onto base
+func mergeRuntimeConfig(base, patch RuntimeConfig) RuntimeConfig {
+	if patch.SMTPConnectTimeout != "" {
+		base.SMTPConnectTimeout = patch.SMTPConnectTimeout
+	}
+	if patch.SMTPCommandTimeout != "" {
+		base.SMTPCommandTimeout = patch.SMTPCommandTimeout
+	}
+	if patch.SMTPMaxRetries != nil {
+		base.SMTPMaxRetries = patch.SMTPMaxRetries
+	}
+	if patch.SMTPRetryDelay != "" {
+		base.SMTPRetryDelay = patch.SMTPRetryDelay
+	}
+	if patch.SMTPIPPreference != "" {
+		base.SMTPIPPreference = patch.SMTPIPPreference
+	}
+	if patch.ThrottleTTL != "" {
+		base.ThrottleTTL = patch.ThrottleTTL
+	}
+	if patch.TaskConcurrency != nil {
+		base.TaskConcurrency = patch.TaskConcurrency
+	}
+	return base
+}
+
+// saveRuntimeOverrides writes the overrides atomically via a temporary file and rename
+func saveRuntimeOverrides(path string, cfg RuntimeConfig) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// handleGetConfig returns the effective runtime-tunable settings
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	s.runtimeMu.Lock()
+	cfg := s.currentRuntimeConfig()
+	s.runtimeMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cfg)
+}
+
+// handleUpdateConfig applies runtime overrides to subsequent checks and persists them
+func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
+	var patch RuntimeConfig
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	s.runtimeMu.Lock()
+	defer s.runtimeMu.Unlock()
+	if err := s.applyRuntimeConfig(patch); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.runtimeOverrides = mergeRuntimeConfig(s.runtimeOverrides, patch)
+	if s.runtimeFile != "" {
+		if err := saveRuntimeOverrides(s.runtimeFile, s.runtimeOverrides); err != nil {
+			logger.Log(fmt.Sprintf("[WARN] Failed to persist runtime config to %s: %v", s.runtimeFile, err))
+			respondError(w, http.StatusInternalServerError, "Config applied but could not be persisted")
+			return
+		}
+	}
+	logger.LogFields("[Config] Runtime config updated", logger.Fields{"request_id": logger.RequestID(r.Context())})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.currentRuntimeConfig())
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"math/rand"
+	"time"
+)
+
+// maintenanceJitter is the fraction of an interval randomly added to each maintenance run
+// so cluster nodes started together do not hit Redis and PostgreSQL at the same moment
+const maintenanceJitter = 0.2
+
+// every runs fn in the background once per interval plus a random jitter
+func every(interval time.Duration, fn func()) {
+	go func() {
+		for {
+			time.Sleep(jittered(interval))
+			fn()
+		}
+	}()
+}
+
+// jittered returns interval extended by a random share of up to maintenanceJitter
+func jittered(interval time.Duration) time.Duration {
+	return interval + time.Duration(rand.Int63n(int64(float64(interval)*maintenanceJitter)+1))
+}
@@ -3,9 +3,13 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"runtime"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -13,39 +17,45 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"go.opentelemetry.io/otel/attribute"
 
 	_ "github.com/shuliakovsky/email-checker/docs"
 	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/cache"
 	"github.com/shuliakovsky/email-checker/internal/checker"
 	"github.com/shuliakovsky/email-checker/internal/lock"
 	"github.com/shuliakovsky/email-checker/internal/logger"
 	"github.com/shuliakovsky/email-checker/internal/metrics"
+	"github.com/shuliakovsky/email-checker/internal/smtp"
 	"github.com/shuliakovsky/email-checker/internal/storage"
 	"github.com/shuliakovsky/email-checker/internal/throttle"
+	"github.com/shuliakovsky/email-checker/internal/tracing"
 	"github.com/shuliakovsky/email-checker/pkg/types"
 )
 
 // Creates a new Server instance with specified configuration
 func NewServer(host string, port string, store storage.Storage, redisClient redis.UniversalClient, maxWorkers int, clusterMode bool, throttleManager *throttle.ThrottleManager, db *sqlx.DB) *Server {
 	return &Server{
-		storage:         store,
-		redisClient:     redisClient,
-		host:            host,
-		port:            port,
-		maxWorkers:      maxWorkers,
-		clusterMode:     clusterMode,
-		throttleManager: throttleManager,
-		authService:     auth.NewAuthService(db, redisClient, clusterMode),
-		db:              db,
+		storage:          store,
+		redisClient:      redisClient,
+		host:             host,
+		port:             port,
+		maxWorkers:       maxWorkers,
+		clusterMode:      clusterMode,
+		throttleManager:  throttleManager,
+		authService:      auth.NewAuthService(db, redisClient, clusterMode),
+		db:               db,
+		webhookTransport: webhookPolicy{}.transport(),
 	}
 }
 
-// Processes tasks in local mode using in-memory queue
-func (s *Server) localWorker() {
-	for {
+// Processes tasks in local mode using in-memory queue until stop is closed
+// A loop blocked on the Redis queue notices stop only after the next task it receives
+func (s *Server) localWorker(stop <-chan struct{}) {
+	for !stopped(stop) {
 		task, err := s.storage.DequeueTask()
 		if err != nil {
-			time.Sleep(1 * time.Second)
+			idle(stop)
 			continue
 		}
 		s.processTask(task)
@@ -55,6 +65,22 @@ func (s *Server) localWorker() {
 // Starts the HTTP server and task processing infrastructure
 func (s *Server) Start() error {
 	s.startKeyCleanup()
+	s.startTaskCleanup()
+	s.startRetryScheduler()
+	s.startQueueMetrics()
+	if s.clusterMode && s.redisClient == nil {
+		return fmt.Errorf("cluster mode requires a Redis client")
+	}
+	if s.preloadCache {
+		go func() {
+			tasks, preloaded, err := s.preloadStoredResults(context.Background(), "")
+			if err != nil {
+				logger.Log("Cache preload failed: " + err.Error())
+				return
+			}
+			logger.Log(fmt.Sprintf("[Cache] Preloaded %d results from %d stored tasks", preloaded, tasks))
+		}()
+	}
 	if s.clusterMode {
 		s.startClusterTaskProcessor()
 		s.startStalledTasksRecovery()
@@ -67,38 +93,83 @@ func (s *Server) Start() error {
 	// cache
 	router.HandleFunc("/cache/flush", s.handleFlushCache)
 	router.HandleFunc("/cache/status", s.handleCacheStatus)
+	router.Handle("POST /admin/cache/preload", AdminMiddleware(http.HandlerFunc(s.handlePreloadCache)))
+	router.Handle("POST /admin/tasks/{task_id}/requeue", AdminMiddleware(http.HandlerFunc(s.handleRequeueTask)))
+	router.Handle("GET /admin/stats", AdminMiddleware(http.HandlerFunc(s.handleStats)))
 
 	// keys
 	router.Handle("/keys", AdminMiddleware(http.HandlerFunc(s.handleCreateKey)))
 	router.Handle("GET /admin/keys", AdminMiddleware(http.HandlerFunc(s.handleListKeys)))
 	router.Handle("GET /admin/keys/{api_key}", AdminMiddleware(http.HandlerFunc(s.handleGetKey)))
+	router.Handle("GET /admin/keys/{api_key}/usage", AdminMiddleware(http.HandlerFunc(s.handleKeyUsage)))
 	router.Handle("PATCH /admin/keys/{api_key}", AdminMiddleware(http.HandlerFunc(s.handleUpdateKey)))
 	router.Handle("DELETE /admin/keys/{api_key}", AdminMiddleware(http.HandlerFunc(s.handleDeleteKey)))
+	router.Handle("PUT /admin/keys/{api_key}/webhook", AdminMiddleware(http.HandlerFunc(s.handleSetKeyWebhook)))
+	router.Handle("DELETE /admin/keys/{api_key}/webhook", AdminMiddleware(http.HandlerFunc(s.handleDeleteKeyWebhook)))
+
+	// runtime config
+	router.Handle("GET /admin/config", AdminMiddleware(http.HandlerFunc(s.handleGetConfig)))
+	router.Handle("PATCH /admin/config", AdminMiddleware(http.HandlerFunc(s.handleUpdateConfig)))
+
+	//	prometheus metrics, unless they are served on the internal listener
+	if s.metricsAddr == "" {
+		router.Handle("/metrics", promhttp.Handler())
+	} else if err := s.serveMetrics(); err != nil {
+		return err
+	}
+
+	// liveness and readiness
+	router.HandleFunc("GET /healthz", s.handleHealthz)
+	router.HandleFunc("GET /readyz", s.handleReadyz)
 
-	//	prometheus metrics
-	router.Handle("/metrics", promhttp.Handler())
+	// build information
+	router.HandleFunc("GET /version", s.handleVersion)
 
 	// tasks
 	router.Handle("/tasks", APIKeyMiddleware(s.authService)(http.HandlerFunc(s.handleTasks)))
+	router.Handle("POST /tasks/stream", APIKeyMiddleware(s.authService)(http.HandlerFunc(s.handleTasksStream)))
+	router.Handle("POST /tasks/estimate", APIKeyMiddleware(s.authService)(http.HandlerFunc(s.handleEstimateTask)))
 	router.Handle("/tasks/", APIKeyMiddleware(s.authService)(http.HandlerFunc(s.handleTaskStatus)))
 	router.Handle("/tasks-results/", APIKeyMiddleware(s.authService)(http.HandlerFunc(s.handleTaskResults)))
 	router.Handle("/tasks-with-webhook", APIKeyMiddleware(s.authService)(http.HandlerFunc(s.handleTasksWithWebhook)))
+	router.Handle("POST /tasks/{task_id}/webhook/retry", APIKeyMiddleware(s.authService)(http.HandlerFunc(s.handleWebhookRetry)))
+
+	// synchronous check
+	router.Handle("/check", APIKeyMiddleware(s.authService)(http.HandlerFunc(s.handleCheck)))
+	router.Handle("POST /check-batch", APIKeyMiddleware(s.authService)(http.HandlerFunc(s.handleCheckBatch)))
 
 	// swagger
 	router.HandleFunc("/swagger/", httpSwagger.WrapHandler)
 
-	handler := corsMiddleware(router)
-	loggedRouter := loggingMiddleware(handler)
-	return http.ListenAndServe(s.host+":"+s.port, loggedRouter)
+	handler := s.corsMiddleware(router)
+	loggedRouter := requestIDMiddleware(loggingMiddleware(tracingMiddleware(handler)))
+	return s.listen(loggedRouter)
 }
 
-// Lua script for atomic task dequeue with lock acquisition
+const (
+	taskCleanupInterval  = 5 * time.Minute  // How often expired tasks are purged from storage
+	stalledScanBatch     = 100              // Lock keys requested per SCAN call during recovery
+	stalledLockTTL       = time.Minute      // Locks expiring sooner than this belong to stalled tasks
+	retryScanInterval    = 5 * time.Second  // How often due email retries are re-checked
+	progressInterval     = 2 * time.Second  // How often partial results are persisted while a task runs
+	progressBatch        = 500              // Reports held before they are persisted regardless of progressInterval
+	queueMetricsInterval = 15 * time.Second // How often the task queue depth gauge is refreshed
+
+	// DefaultMaxTaskEmails is the default maximum number of emails per task
+	DefaultMaxTaskEmails = 10000
+
+	// DefaultTaskConcurrency is the default number of tasks processed at once per node
+	DefaultTaskConcurrency = 2
+)
+
+// Lua script for atomic task dequeue with a short-lived claim
+// The claim uses its own key: the processing lock (lock:task:<id>) is acquired by processClusterTask
 const dequeueScript = `
 local task_data = redis.call('RPOP', KEYS[1])
 if not task_data then return nil end
 local task = cjson.decode(task_data)
-local lock_key = 'lock:task:' .. task.id
-if redis.call('SET', lock_key, ARGV[1], 'NX', 'EX', ARGV[2]) then
+local claim_key = 'claim:task:' .. task.id
+if redis.call('SET', claim_key, ARGV[1], 'NX', 'EX', ARGV[2]) then
 	return task_data
 else
 	redis.call('LPUSH', KEYS[1], task_data)
@@ -107,18 +178,16 @@ end`
 
 // Starts cluster-aware task processing workers
 func (s *Server) startClusterTaskProcessor() {
-	for i := 0; i < s.maxWorkers; i++ {
-		go func() {
-			for {
-				task, err := s.dequeueTaskWithLock()
-				if err != nil {
-					time.Sleep(1 * time.Second)
-					continue
-				}
-				s.processClusterTask(task)
+	s.startTaskPool(func(stop <-chan struct{}) {
+		for !stopped(stop) {
+			task, err := s.dequeueTaskWithLock()
+			if err != nil {
+				idle(stop)
+				continue
 			}
-		}()
-	}
+			s.processClusterTask(task)
+		}
+	})
 }
 
 // Atomically dequeues task with Redis lock acquisition
@@ -144,29 +213,106 @@ func (s *Server) dequeueTaskWithLock() (*types.Task, error) {
 
 // Periodically recovers stalled tasks with expired locks
 func (s *Server) startStalledTasksRecovery() {
-	ticker := time.NewTicker(5 * time.Minute)
-	go func() {
-		for range ticker.C {
-			script := `
-				local locks = redis.call('KEYS', 'lock:task:*')
-				for _, lock_key in ipairs(locks) do
-					local ttl = redis.call('TTL', lock_key)
-					if ttl == -1 or ttl < 60 then
-						local task_id = string.sub(lock_key, 11)
-						redis.call('LPUSH', KEYS[1], task_id)
-						redis.call('DEL', lock_key)
-					end
-				end
-			`
-			s.redisClient.Eval(context.Background(), script, []string{storage.TaskQueueKey})
+	every(5*time.Minute, func() {
+		if err := s.recoverStalledLocks(context.Background()); err != nil {
+			logger.Log("Stalled task recovery failed: " + err.Error())
 		}
-	}()
+	})
+}
+
+// recoverStalledLocks walks task locks with cursor-based SCAN and re-queues tasks
+// whose lock has no expiry or is about to expire
+// Locks are looked up where they are kept: on the Redlock nodes when configured, otherwise on the main Redis.
+// The queue holds serialized tasks, so the full task is loaded from storage and re-enqueued
+func (s *Server) recoverStalledLocks(ctx context.Context) error {
+	nodes := lock.Nodes(s.redisClient)
+	var (
+		mu       sync.Mutex
+		lockKeys = make(map[string]struct{}) // Redlock keeps a copy of each lock per node
+	)
+	for _, node := range nodes {
+		err := forEachNode(ctx, node, func(ctx context.Context, client redis.UniversalClient) error {
+			iter := client.Scan(ctx, 0, "lock:task:*", stalledScanBatch).Iterator()
+			for iter.Next(ctx) {
+				mu.Lock()
+				lockKeys[iter.Val()] = struct{}{}
+				mu.Unlock()
+			}
+			return iter.Err()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for lockKey := range lockKeys {
+		if !lock.Stalled(ctx, nodes, lockKey, stalledLockTTL) {
+			continue // Lock released meanwhile or still actively refreshed
+		}
+		// Only the node that claims the recovery re-queues the task; the claim expires on its own,
+		// by when the stalled lock is gone or taken again by the worker processing the task
+		claim := lock.NewClusterLock(s.redisClient, "recover:"+lockKey, stalledLockTTL, true)
+		if !claim.Acquire(ctx) {
+			continue
+		}
+		if _, err := lock.Remove(ctx, nodes, lockKey); err != nil {
+			logger.Log(fmt.Sprintf("[Recovery] Failed to remove stalled lock %s: %v", lockKey, err))
+			continue
+		}
+		taskID := strings.TrimPrefix(lockKey, "lock:task:")
+		if err := s.requeueStalledTask(ctx, taskID); err != nil {
+			logger.Log(fmt.Sprintf("[Recovery] Failed to re-queue task %s: %v", taskID, err))
+		}
+	}
+	return nil
+}
+
+// forEachNode calls fn with client, or with every master of a Redis Cluster since SCAN only covers one node
+func forEachNode(ctx context.Context, client redis.UniversalClient, fn func(context.Context, redis.UniversalClient) error) error {
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return fn(ctx, node)
+		})
+	}
+	return fn(ctx, client)
+}
+
+// requeueStalledTask puts an unfinished task back on the queue for another worker
+func (s *Server) requeueStalledTask(ctx context.Context, taskID string) error {
+	task, err := s.storage.GetTask(ctx, taskID)
+	if err != nil {
+		return err // Expired or deleted meanwhile; nothing left to process
+	}
+	if taskFinished(task) {
+		return nil
+	}
+	if err := s.requeueTask(ctx, task); err != nil {
+		return err
+	}
+	logger.LogFields("[Recovery] Stalled task re-queued", logger.Fields{"task_id": task.ID, "request_id": task.RequestID})
+	return nil
+}
+
+// requeueTask marks task pending and puts it back on the queue
+// Stored results are kept; processing resumes with the remaining emails
+func (s *Server) requeueTask(ctx context.Context, task *types.Task) error {
+	task.Status = "pending"
+	if err := s.storage.UpdateTask(ctx, task); err != nil {
+		return err
+	}
+	return s.storage.EnqueueTask(task)
+}
+
+// taskFinished reports whether task reached a final status
+func taskFinished(task *types.Task) bool {
+	return task.Status == "completed" || task.Status == "completed_partial"
 }
 
 // Processes task in cluster mode with distributed locking
+// The lock marks the task as in flight so stalled-task recovery can re-queue it if this node dies
 func (s *Server) processClusterTask(task *types.Task) {
 	lockKey := fmt.Sprintf("lock:task:%s", task.ID)
-	lock := lock.NewLock(s.redisClient, lockKey, 5*time.Minute, s.clusterMode)
+	lock := lock.NewClusterLock(s.redisClient, lockKey, 5*time.Minute, s.clusterMode)
 
 	if !lock.Acquire(context.Background()) {
 		return
@@ -178,22 +324,196 @@ func (s *Server) processClusterTask(task *types.Task) {
 	lock.StartRefresh(refreshCtx)
 	defer lock.Release(context.Background())
 
-	task.Status = "processing"
-	s.storage.UpdateTask(context.Background(), task)
+	s.processTask(task)
+}
+
+// checkerConfig builds the email checker configuration shared by all processing paths
+func (s *Server) checkerConfig() checker.Config {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return checker.Config{
+		MaxWorkers:       s.maxWorkers,
+		CacheProvider:    s.storage.GetCacheProvider(),
+		MXCacheProvider:  s.mxCache,
+		DomainCacheTTL:   24 * time.Hour,
+		ExistTTL:         30 * 24 * time.Hour,
+		NotExistTTL:      24 * time.Hour,
+		ThrottleManager:  s.throttleManager,
+		DryRun:           s.dryRun,
+		GroupByDomain:    s.groupByDomain,
+		DomainAge:        s.domainAge,
+		Overrides:        s.overrides,
+		TTLJitter:        s.ttlJitter,
+		RejectDisposable: s.rejectDisposable,
+		AllowDomains:     s.allowDomains,
+		BlockDomains:     s.blockDomains,
+	}
+}
+
+// taskContext bounds the processing of one task by the configured task timeout
+// It also starts the task.process span, continuing the trace of the request that created the task;
+// the span ends when the returned cancel function is called
+func (s *Server) taskContext(task *types.Task) (context.Context, context.CancelFunc) {
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), task.TraceContext), "task.process",
+		attribute.String("task.id", task.ID), attribute.Int("task.emails", len(task.Emails)))
+	s.settingsMu.RLock()
+	timeout := s.taskTimeout
+	s.settingsMu.RUnlock()
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	return ctx, func() {
+		cancel()
+		span.End()
+	}
+}
+
+// runTask checks the emails of task that have no result yet and completes it, persisting reports
+// through progress as they arrive, so status polls see real progress, a task re-queued after a
+// crash resumes instead of starting over, and memory does not grow with the task size
+// task.Results is left as loaded; the stored task holds the full results
+func (s *Server) runTask(ctx context.Context, task *types.Task, cfg checker.Config, progress *taskProgress) {
+	checker.ProcessEmailsFunc(ctx, remainingEmails(task), cfg, progress.add)
+	s.finishTask(task, progress)
+}
 
-	cfg := checker.Config{
-		MaxWorkers:     s.maxWorkers,
-		CacheProvider:  s.storage.GetCacheProvider(),
-		DomainCacheTTL: 24 * time.Hour,
-		ExistTTL:       720 * time.Hour,
-		NotExistTTL:    24 * time.Hour,
+// finishTask uploads the results when the task requested an export, then marks it completed,
+// so a completed status and the webhook already carry the export outcome
+func (s *Server) finishTask(task *types.Task, progress *taskProgress) {
+	if task.Export != nil {
+		progress.save() // The export reads the stored results
+		s.exportResults(task)
 	}
+	progress.complete()
+}
+
+// remainingEmails returns the emails of task not yet covered by a stored result
+// Addresses are compared normalized and repeated ones are matched one result each
+func remainingEmails(task *types.Task) []string {
+	if len(task.Results) == 0 {
+		return task.Emails
+	}
+	done := make(map[string]int, len(task.Results))
+	for _, report := range task.Results {
+		done[checker.NormalizeEmail(report.Email)]++
+	}
+	remaining := make([]string, 0, len(task.Emails)-len(task.Results))
+	for _, email := range task.Emails {
+		if key := checker.NormalizeEmail(email); done[key] > 0 {
+			done[key]--
+			continue
+		}
+		remaining = append(remaining, email)
+	}
+	return remaining
+}
+
+// chargeQuota decrements the key quota for processed checks
+// Requests without an API key, empty results and dry runs are not charged
+func (s *Server) chargeQuota(apiKey, taskID string, count int) {
+	if apiKey == "" || count <= 0 || s.dryRun {
+		return
+	}
+	// Use background context since request context might be expired
+	if err := s.authService.DecrementQuota(context.Background(), apiKey, taskID, count); err != nil {
+		logger.Log(fmt.Sprintf("Failed to decrement quota: %v", err))
+	}
+}
 
-	results := checker.ProcessEmailsWithConfig(task.Emails, cfg)
-	task.Status = "completed"
-	task.Results = results
+// SetTaskLimits sets the maximum emails per task; monthly keys use monthlyMax when it is positive
+func (s *Server) SetTaskLimits(defaultMax, monthlyMax int) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.maxEmails = defaultMax
+	s.maxEmailsMonthly = monthlyMax
+}
+
+// maxTaskEmails returns the batch size limit applicable to the key
+func (s *Server) maxTaskEmails(key *auth.APIKey) int {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	if key.Type == auth.KeyTypeMonthly && s.maxEmailsMonthly > 0 {
+		return s.maxEmailsMonthly
+	}
+	if s.maxEmails > 0 {
+		return s.maxEmails
+	}
+	return DefaultMaxTaskEmails
+}
+
+// SetWorkers sets the number of concurrent email workers used by tasks started from now on
+func (s *Server) SetWorkers(n int) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.maxWorkers = n
+}
+
+// SetGroupByDomain toggles sequential per-domain processing within a task
+func (s *Server) SetGroupByDomain(enabled bool) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.groupByDomain = enabled
+}
+
+// SetBuildInfo records the build reported by /version; the Go version is filled in automatically
+func (s *Server) SetBuildInfo(info BuildInfo) {
+	info.GoVersion = runtime.Version()
+	s.buildInfo = info
+}
+
+// SetRejectDisposable reports disposable addresses as undeliverable without DNS/SMTP checks
+func (s *Server) SetRejectDisposable(enabled bool) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.rejectDisposable = enabled
+}
+
+// SetDomainPolicy sets the domains accepted or rejected without SMTP checks (nil lists match nothing)
+func (s *Server) SetDomainPolicy(allow, block *checker.DomainList) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.allowDomains = allow
+	s.blockDomains = block
+}
+
+// SetCacheTTLJitter sets the random +/- fraction applied to cache TTLs
+func (s *Server) SetCacheTTLJitter(fraction float64) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.ttlJitter = fraction
+}
+
+// SetMXCacheProvider keeps MX records in provider instead of the cache shared with email results,
+// e.g. in process memory while verdicts stay in Redis (nil shares the result cache)
+func (s *Server) SetMXCacheProvider(provider cache.Provider) {
+	s.mxCache = provider
+}
+
+// SetOverrides registers predetermined reports returned instead of checking those emails
+func (s *Server) SetOverrides(overrides map[string]types.EmailReport) {
+	s.overrides = overrides
+}
+
+// SetDomainAge toggles RDAP domain age lookups for every check
+func (s *Server) SetDomainAge(enabled bool) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.domainAge = enabled
+}
+
+// SetTaskTimeout limits how long a single task may run (0 disables the limit)
+func (s *Server) SetTaskTimeout(timeout time.Duration) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.taskTimeout = timeout
+}
 
-	s.storage.UpdateTask(context.Background(), task)
+// SetDryRun toggles dry-run mode where SMTP servers are never contacted and quota is not charged
+func (s *Server) SetDryRun(enabled bool) {
+	s.dryRun = enabled
 }
 
 // Generates unique task ID using nanosecond timestamp
@@ -203,9 +523,17 @@ func (s *Server) generateID() string {
 
 // Initializes local task processing workers
 func (s *Server) startLocalTaskProcessor() {
-	for i := 0; i < s.maxWorkers; i++ {
-		go s.localWorker()
+	s.startTaskPool(s.localWorker)
+}
+
+// respondSaveTaskError reports a failed task save, answering 413 for tasks too large to store
+func respondSaveTaskError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrTaskTooLarge) {
+		logger.Log(fmt.Sprintf("[Task] Rejected: %v", err))
+		respondError(w, http.StatusRequestEntityTooLarge, "Task too large to store, split the emails into smaller tasks")
+		return
 	}
+	respondError(w, http.StatusInternalServerError, "Failed to save task")
 }
 
 // Handles task creation requests
@@ -214,53 +542,108 @@ func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == http.MethodPost {
 		var request struct {
-			Emails []string `json:"emails"`
+			Emails []string        `json:"emails"`
+			Checks json.RawMessage `json:"checks"`
+			Fresh  bool            `json:"fresh"`
+			Export *exportRequest  `json:"export"` // Upload the results on completion
 		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+
+		// A retried request with the same Idempotency-Key gets the original task back before any other check,
+		// so a retry still succeeds once the quota is spent or a default webhook changed
+		taskID, replay, release, err := s.reserveTaskID(r, key.Key)
+		if err != nil {
+			respondTaskReservationError(w, err)
+			return
+		}
+		if replay {
+			respondTaskCreated(w, taskID, true)
+			return
+		}
+		created := false
+		defer func() {
+			if !created {
+				release() // Rejected or failed requests leave the key free for a corrected retry
+			}
+		}()
+
 		// check email quota
 		if len(request.Emails) > key.Remaining {
 			respondError(w, http.StatusForbidden, "Not enough remaining checks")
 			return
 		}
-
-		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
+		// limit the batch size by the key type
+		if limit := s.maxTaskEmails(key); len(request.Emails) > limit {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Too many emails (max %d for %s keys)", limit, key.Type))
 			return
 		}
-		// limit emails length with 10 000
-		if len(request.Emails) > 10000 {
-			http.Error(w, "Too many emails (max 10000)", http.StatusBadRequest)
+		// base check for email length
+		if s.rejectOversizedEmails(w, request.Emails) {
 			return
 		}
-		// base check for email length
-		for _, email := range request.Emails {
-			if len(email) > 254 {
-				http.Error(w, "Email too long", http.StatusBadRequest)
-				return
-			}
+		checks, err := parseChecks(request.Checks)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		fresh, err := freshFromQuery(r.URL.Query())
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		exportConfig, exportSecret, err := s.prepareExport(r.Context(), request.Export)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Tasks of keys with a default webhook notify it like /tasks-with-webhook
+		defaultWebhook, err := s.defaultWebhook(r.Context(), key.Key)
+		if err != nil {
+			logger.Log(fmt.Sprintf("[Webhook] Failed to load default webhook: %v", err))
+			respondError(w, http.StatusInternalServerError, "Failed to load default webhook")
+			return
 		}
 
-		taskID := s.generateID()
 		task := &types.Task{
-			ID:        taskID,
-			Status:    "pending",
-			Emails:    request.Emails,
-			CreatedAt: time.Now(),
-			APIKey:    key.Key,
+			ID:           taskID,
+			Status:       "pending",
+			Emails:       request.Emails,
+			CreatedAt:    time.Now(),
+			APIKey:       key.Key,
+			RequestID:    logger.RequestID(r.Context()),
+			TraceContext: tracing.Inject(r.Context()),
+			Checks:       checks,
+			Fresh:        fresh || request.Fresh,
+			Export:       exportConfig,
+		}
+		if defaultWebhook != nil {
+			task.Webhooks = []types.WebhookConfig{*defaultWebhook}
 		}
 
 		if err := s.storage.SaveTask(r.Context(), task); err != nil {
-			http.Error(w, "Failed to save task", http.StatusInternalServerError)
+			respondSaveTaskError(w, err)
 			return
 		}
+		s.saveTaskWebhook(r.Context(), task)
+		s.keepExportSecret(task.ID, exportSecret)
 
-		go s.processTask(task)
+		if err := s.storage.EnqueueTask(task); err != nil {
+			s.exportSecrets.Delete(task.ID)
+			logger.Log(fmt.Sprintf("[Task] Failed to queue %s: %v", task.ID, err))
+			respondError(w, http.StatusInternalServerError, "Failed to queue task")
+			return
+		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"task_id": taskID})
+		created = true
+		respondTaskCreated(w, taskID, false)
 		return
 	}
 
-	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
 }
 
 // Provides task status information
@@ -269,27 +652,39 @@ func (s *Server) handleTaskStatus(w http.ResponseWriter, r *http.Request) {
 
 	task, err := s.storage.GetTask(r.Context(), taskID)
 	if err != nil {
-		http.Error(w, "Task not found", http.StatusNotFound)
+		respondError(w, http.StatusNotFound, "Task not found")
 		return
 	}
 
 	var totalPages int
-	if task.Status == "completed" {
+	if task.Status == "completed" || task.Status == "completed_partial" {
 		totalPages = (len(task.Results) + 99) / 100
 	}
 
+	skipped := checker.CountNotChecked(task.Results)
+	summary := task.Summary
+	if summary == nil && len(task.Results) > 0 {
+		computed := checker.Summarize(task.Results) // Tasks stored before summaries existed, or still streaming
+		summary = &computed
+	}
 	response := TaskStatusResponse{
 		Status:       task.Status,
+		TotalEmails:  len(task.Emails),
 		TotalResults: len(task.Results),
+		Processed:    len(task.Results) - skipped,
+		Skipped:      skipped,
 		CreatedAt:    task.CreatedAt,
 		TotalPages:   totalPages,
+		Summary:      summary,
+		ExportURL:    task.ExportURL,
+		ExportError:  task.ExportError,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// Serves paginated task results
+// Serves paginated task results, optionally filtered by outcome
 func (s *Server) handleTaskResults(w http.ResponseWriter, r *http.Request) {
 	taskID := r.URL.Path[len("/tasks-results/"):]
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
@@ -302,19 +697,36 @@ func (s *Server) handleTaskResults(w http.ResponseWriter, r *http.Request) {
 		page = 1
 	}
 
-	task, err := s.storage.GetTask(r.Context(), taskID)
+	filter, err := parseResultFilter(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Task not found", http.StatusNotFound)
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	start := (page - 1) * perPage
-	if start < 0 || start >= len(task.Results) {
-		start = 0
-	}
-	end := start + perPage
-	if end > len(task.Results) {
-		end = len(task.Results)
+	var (
+		results []types.EmailReport
+		total   int
+		offset  = (page - 1) * perPage
+	)
+	if filter.active() {
+		// Filtering needs every result; pagination is applied to the filtered set
+		task, err := s.storage.GetTask(r.Context(), taskID)
+		if err != nil {
+			respondError(w, http.StatusNotFound, "Task not found")
+			return
+		}
+		filtered := filter.apply(task.Results)
+		total = len(filtered)
+		results = []types.EmailReport{}
+		if offset < total {
+			results = filtered[offset:min(offset+perPage, total)]
+		}
+	} else {
+		results, total, err = s.storage.GetTaskResultsPage(r.Context(), taskID, offset, perPage)
+		if err != nil {
+			respondError(w, http.StatusNotFound, "Task not found")
+			return
+		}
 	}
 
 	response := struct {
@@ -322,9 +734,9 @@ func (s *Server) handleTaskResults(w http.ResponseWriter, r *http.Request) {
 		Page  int                 `json:"page"`
 		Total int                 `json:"total"`
 	}{
-		Data:  task.Results[start:end],
+		Data:  results,
 		Page:  page,
-		Total: len(task.Results),
+		Total: total,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -333,55 +745,126 @@ func (s *Server) handleTaskResults(w http.ResponseWriter, r *http.Request) {
 
 // Executes email validation task and updates state
 func (s *Server) processTask(task *types.Task) {
+	// Queue entries carry the task without its results; resume from the stored task
+	if stored, err := s.storage.GetTask(context.Background(), task.ID); err == nil {
+		if taskFinished(stored) {
+			return // Queued again while it was processed
+		}
+		task = stored
+	}
+	progress := newTaskProgress(s.storage, task)
 	// Ensure quota decrement happens even if processing fails
 	defer func() {
-		// Only decrement quota for authenticated requests with results
-		if task.APIKey != "" && len(task.Results) > 0 {
-			// Use background context since request context might be expired
-			err := s.authService.DecrementQuota(context.Background(), task.APIKey, len(task.Results))
-			if err != nil {
-				logger.Log(fmt.Sprintf("Failed to decrement quota: %v", err))
-			}
-		}
+		s.chargeQuota(task.APIKey, task.ID, progress.chargedEmails())
 	}()
 
 	ctx := context.Background()
 	task.Status = "processing"
 	_ = s.storage.UpdateTask(ctx, task) // Error ignored for workflow continuity
+	logger.LogFields("[Task] Processing started", logger.Fields{"task_id": task.ID, "request_id": task.RequestID})
 
-	cfg := checker.Config{
-		MaxWorkers:     s.maxWorkers,
-		CacheProvider:  s.storage.GetCacheProvider(),
-		DomainCacheTTL: 24 * time.Hour,
-		ExistTTL:       30 * 24 * time.Hour,
-		NotExistTTL:    24 * time.Hour,
-	}
+	cfg := s.checkerConfig()
+	cfg.RequestID = task.RequestID // Correlate worker and SMTP logs with the originating request
+	applyChecks(&cfg, task.Checks)
+	cfg.SkipCache = task.Fresh
+	taskCtx, cancelTask := s.taskContext(task)
+	defer cancelTask()
 
-	results := checker.ProcessEmailsWithConfig(task.Emails, cfg)
-	task.Status = "completed"
-	task.Results = results
-	_ = s.storage.UpdateTask(ctx, task)
-	if task.Webhook != nil {
+	s.runTask(taskCtx, task, cfg, progress)
+	if len(taskWebhooks(task)) > 0 {
 		s.triggerWebhook(task)
 	}
 }
 
+// Reports that the process is up and serving requests
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Reports service readiness; fails while outbound SMTP port 25 appears blocked
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if smtp.OutboundBlocked() {
+		respondError(w, http.StatusServiceUnavailable, "Outbound SMTP port 25 appears to be blocked")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+}
+
+// Reports the deployed build so rollouts can be verified
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	info := s.buildInfo
+	if info.GoVersion == "" {
+		info.GoVersion = runtime.Version()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
 // Handles cache flush operations
 func (s *Server) handleFlushCache(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	s.storage.GetCacheProvider().Flush()
+	if s.mxCache != nil {
+		s.mxCache.Flush()
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Cache successfully flushed"))
 }
 
+// SetPreloadCache caches the results of stored tasks in the background when the server starts
+func (s *Server) SetPreloadCache(enabled bool) {
+	s.preloadCache = enabled
+}
+
+// preloadStoredResults caches results of one stored task, or of all tasks when taskID is empty
+// Returns the number of tasks visited and results cached
+func (s *Server) preloadStoredResults(ctx context.Context, taskID string) (int, int, error) {
+	cfg := s.checkerConfig()
+	if taskID != "" {
+		task, err := s.storage.GetTask(ctx, taskID)
+		if err != nil {
+			return 0, 0, err
+		}
+		return 1, checker.PreloadTask(task, cfg), nil
+	}
+
+	tasks, preloaded := 0, 0
+	err := s.storage.WalkTasks(ctx, func(task *types.Task) error {
+		tasks++
+		preloaded += checker.PreloadTask(task, cfg)
+		return nil
+	})
+	return tasks, preloaded, err
+}
+
+// Rehydrates the email cache from stored task results
+func (s *Server) handlePreloadCache(w http.ResponseWriter, r *http.Request) {
+	taskID := r.URL.Query().Get("task_id")
+	tasks, preloaded, err := s.preloadStoredResults(r.Context(), taskID)
+	if err != nil && taskID != "" {
+		respondError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to read stored tasks")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"tasks": tasks, "preloaded": preloaded})
+}
+
 // Provides cache system statistics
 func (s *Server) handleCacheStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -402,10 +885,12 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 // Adds request logging to HTTP handlers
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
 		lrw := newLoggingResponseWriter(w)
 		next.ServeHTTP(lrw, r)
 
 		statusCode := strconv.Itoa(lrw.statusCode)
+		logger.LogContext(r.Context(), fmt.Sprintf("[HTTP] %s %s %s %v from %s", r.Method, r.URL.Path, statusCode, time.Since(startTime), clientIP(r)))
 		metrics.HttpRequests.WithLabelValues(
 			r.Method,
 			r.URL.Path,
@@ -414,25 +899,44 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// startTaskCleanup periodically purges tasks older than the task TTL from storage
+// Redis expires tasks natively, so this only frees memory for in-memory storage
+func (s *Server) startTaskCleanup() {
+	every(taskCleanupInterval, func() {
+		purged, err := s.storage.PurgeExpiredTasks(context.Background())
+		if err != nil {
+			logger.Log("Task cleanup failed: " + err.Error())
+			return
+		}
+		if purged > 0 {
+			logger.Log(fmt.Sprintf("[Cleanup] Purged %d expired tasks", purged))
+		}
+	})
+}
+
+// startRetryScheduler periodically re-checks emails whose retry after a temporary failure became due
+// Retries are scheduled per node, in memory; refreshed reports go to the shared cache
+func (s *Server) startRetryScheduler() {
+	every(retryScanInterval, func() {
+		if rechecked := checker.RecheckDue(s.checkerConfig()); rechecked > 0 {
+			logger.Log(fmt.Sprintf("[Retry] Re-checked %d emails", rechecked))
+		}
+	})
+}
+
 // startKeyCleanup initiates periodic background cleanup of expired API keys
 func (s *Server) startKeyCleanup() {
-	// Create daily ticker for maintenance tasks
-	ticker := time.NewTicker(24 * time.Hour)
-
-	// Launch background goroutine for recurring cleanup
-	go func() {
-		// Process cleanup on each tick interval
-		for range ticker.C {
-			// Remove expired keys with exhausted quotas
-			_, err := s.db.Exec(`
+	// Run daily maintenance in the background, jittered across cluster nodes
+	every(24*time.Hour, func() {
+		// Remove expired keys with exhausted quotas
+		_, err := s.db.Exec(`
                 DELETE FROM api_keys 
                 WHERE expires_at < NOW() 
                 AND remaining_checks = 0`)
 
-			if err != nil {
-				// Log failures but continue cleanup schedule
-				logger.Log("Key cleanup failed: " + err.Error())
-			}
+		if err != nil {
+			// Log failures but continue cleanup schedule
+			logger.Log("Key cleanup failed: " + err.Error())
 		}
-	}()
+	})
 }
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/storage"
+)
+
+func TestNewServerBindAddress(t *testing.T) {
+	tests := []struct {
+		host, port, want string
+	}{
+		{"0.0.0.0", "8080", "0.0.0.0:8080"},
+		{"127.0.0.1", "9000", "127.0.0.1:9000"},
+		{"::1", "8080", "[::1]:8080"},
+		{"", "8080", ":8080"},
+	}
+	for _, tt := range tests {
+		store := storage.NewMemoryStorage(nil)
+		s := NewServer(tt.host, tt.port, store, nil, 4, false, nil, nil)
+		if got := s.addr(); got != tt.want {
+			t.Errorf("NewServer(%q, %q).addr() = %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+		if s.storage != store || s.maxWorkers != 4 || s.clusterMode {
+			t.Errorf("NewServer(%q, %q) did not keep its arguments", tt.host, tt.port)
+		}
+		if s.authService == nil || s.webhookTransport == nil {
+			t.Errorf("NewServer(%q, %q) left services unset", tt.host, tt.port)
+		}
+	}
+}
+
+func TestNewServerListensOnHost(t *testing.T) {
+	s := NewServer("127.0.0.1", "0", storage.NewMemoryStorage(nil), nil, 1, false, nil, nil)
+	ln, err := s.bind(s.addr())
+	if err != nil {
+		t.Fatalf("bind %s: %v", s.addr(), err)
+	}
+	defer ln.Close()
+	if got := ln.Addr().String(); !strings.HasPrefix(got, "127.0.0.1:") {
+		t.Fatalf("listening on %s, want 127.0.0.1", got)
+	}
+}
+
+func TestHandleTasksChecksQuota(t *testing.T) {
+	s := &Server{storage: storage.NewMemoryStorage(nil)}
+	key := &auth.APIKey{Key: "key", Type: auth.KeyTypePayAsYouGo, Remaining: 1}
+
+	rec := postTask(s, key, `{"emails": ["a@example.com", "b@example.com"]}`)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("POST /tasks over quota = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body)
+	}
+	if n, _ := s.storage.QueueLen(); n != 0 {
+		t.Fatalf("%d tasks queued over quota, want none", n)
+	}
+
+	if rec := postTask(s, key, `{"emails": ["a@example.com"]}`); rec.Code != http.StatusOK {
+		t.Fatalf("POST /tasks within quota = %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestIdempotentRetryAfterQuotaIsSpent(t *testing.T) {
+	s := &Server{storage: storage.NewMemoryStorage(nil)}
+	key := &auth.APIKey{Key: "key", Type: auth.KeyTypePayAsYouGo, Remaining: 1}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body))
+		req.Header.Set(idempotencyHeader, "order-1")
+		req = req.WithContext(context.WithValue(req.Context(), "api_key", key))
+		rec := httptest.NewRecorder()
+		s.handleTasks(rec, req)
+		return rec
+	}
+	taskID := func(rec *httptest.ResponseRecorder) string {
+		var response struct {
+			TaskID string `json:"task_id"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response.TaskID
+	}
+
+	first := post(`{"emails": ["a@example.com"]}`)
+	if first.Code != http.StatusOK {
+		t.Fatalf("POST /tasks = %d: %s", first.Code, first.Body)
+	}
+
+	// The task used up the quota; the retry still gets the original task instead of 403
+	key.Remaining = 0
+	retry := post(`{"emails": ["a@example.com"]}`)
+	if retry.Code != http.StatusOK || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry = %d (replayed %q): %s", retry.Code, retry.Header().Get("Idempotent-Replayed"), retry.Body)
+	}
+	if a, b := taskID(first), taskID(retry); a != b {
+		t.Fatalf("retry returned task %q, want %q", b, a)
+	}
+}
+
+func TestRejectedTaskReleasesIdempotencyKey(t *testing.T) {
+	store := storage.NewMemoryStorage(nil)
+	s := &Server{storage: store}
+	key := &auth.APIKey{Key: "key", Type: auth.KeyTypePayAsYouGo, Remaining: 1}
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"emails": ["a@example.com", "b@example.com"]}`))
+	req.Header.Set(idempotencyHeader, "order-2")
+	req = req.WithContext(context.WithValue(req.Context(), "api_key", key))
+	rec := httptest.NewRecorder()
+	s.handleTasks(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("POST /tasks over quota = %d, want 403", rec.Code)
+	}
+	if _, created, _ := store.ReserveIdempotencyKey(context.Background(), "key:order-2", "other", time.Minute); !created {
+		t.Fatal("idempotency key still reserved by a rejected request")
+	}
+}
+
+func TestUsageRangeEndIncludesWholeDay(t *testing.T) {
+	end, err := parseUsageEnd("2024-03-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Fatalf("date-only end = %v, want %v", end, want)
+	}
+	end, err = parseUsageEnd("2024-03-10T12:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Fatalf("timestamp end = %v, want %v", end, want)
+	}
+}
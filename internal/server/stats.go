@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/shuliakovsky/email-checker/internal/cache"
+	"github.com/shuliakovsky/email-checker/internal/checker"
+	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/internal/metrics"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// StatsResponse is the system snapshot returned by GET /admin/stats
+type StatsResponse struct {
+	Cache            cache.Stats    `json:"cache"`             // Same figures as /cache/status
+	ThrottledDomains int            `json:"throttled_domains"` // Domains blocked by this node
+	PendingRetries   int            `json:"pending_retries"`   // Emails waiting for a re-check after a temporary failure
+	QueueDepth       int            `json:"queue_depth"`       // Tasks waiting to be picked up
+	ActiveWorkers    int            `json:"active_workers"`    // Email workers running on this node
+	TaskLoops        int            `json:"task_loops"`        // Task processing loops of this node (task concurrency)
+	ActiveTaskLoops  int            `json:"active_task_loops"` // Loops still running, above task_loops while stopped ones finish their task
+	Tasks            map[string]int `json:"tasks"`             // Stored tasks by status
+}
+
+// handleStats gathers cache, throttle, queue, worker and task figures into one snapshot
+// Task counts walk every stored task, so the endpoint is meant for occasional operator use
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	queueDepth, err := s.storage.QueueLen()
+	if err != nil {
+		logger.Log(fmt.Sprintf("[Stats] Failed to read queue length: %v", err))
+		respondError(w, http.StatusInternalServerError, "Failed to read queue length")
+		return
+	}
+
+	tasks := make(map[string]int)
+	err = s.storage.WalkTasks(r.Context(), func(task *types.Task) error {
+		tasks[task.Status]++
+		return nil
+	})
+	if err != nil {
+		logger.Log(fmt.Sprintf("[Stats] Failed to count tasks: %v", err))
+		respondError(w, http.StatusInternalServerError, "Failed to count tasks")
+		return
+	}
+
+	stats := StatsResponse{
+		Cache:           s.storage.GetCacheProvider().GetStats(),
+		QueueDepth:      queueDepth,
+		ActiveWorkers:   checker.ActiveWorkers(),
+		TaskLoops:       s.targetTaskLoops(),
+		ActiveTaskLoops: s.activeTaskLoops(),
+		Tasks:           tasks,
+	}
+	if s.throttleManager != nil {
+		stats.ThrottledDomains = s.throttleManager.ThrottledDomains()
+		stats.PendingRetries = s.throttleManager.PendingRetries()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// startQueueMetrics periodically publishes the task queue depth, so a growing backlog can be alerted on
+func (s *Server) startQueueMetrics() {
+	every(queueMetricsInterval, s.updateQueueDepth)
+}
+
+// updateQueueDepth sets the queue depth gauge to the tasks waiting for a task loop
+// Every created or re-queued task passes through the queue, so this is the backlog of the deployment
+func (s *Server) updateQueueDepth() {
+	depth, err := s.storage.QueueLen()
+	if err != nil {
+		logger.Log("Queue depth update failed: " + err.Error())
+		return
+	}
+	metrics.TaskQueueDepth.Set(float64(depth))
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/metrics"
+	"github.com/shuliakovsky/email-checker/internal/storage"
+)
+
+// postTask submits emails to handleTasks as key and returns the response
+func postTask(s *Server, key *auth.APIKey, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), "api_key", key))
+	rec := httptest.NewRecorder()
+	s.handleTasks(rec, req)
+	return rec
+}
+
+func TestQueueDepthCountsCreatedTasks(t *testing.T) {
+	store := storage.NewMemoryStorage(nil)
+	s := &Server{storage: store} // No task loops run, so created tasks stay queued
+	key := &auth.APIKey{Key: "key", Type: auth.KeyTypePayAsYouGo, Remaining: 100}
+
+	for i := 0; i < 3; i++ {
+		if rec := postTask(s, key, `{"emails": ["user@example.com"]}`); rec.Code != http.StatusOK {
+			t.Fatalf("POST /tasks = %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	s.updateQueueDepth()
+	var m dto.Metric
+	if err := metrics.TaskQueueDepth.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetGauge().GetValue(); got != 3 {
+		t.Fatalf("task_queue_depth = %v, want 3", got)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/logger"
+	"github.com/shuliakovsky/email-checker/internal/tracing"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// streamChunkSize is the number of emails received between saves of a streamed task
+const streamChunkSize = 1000
+
+// handleTasksStream ingests newline-delimited emails from a streaming request body
+// Lines are read one at a time and the task is saved every streamChunkSize emails, so GET /tasks/{id} shows the
+// ingestion progress as status "receiving". The batch is bounded like POST /tasks by the key quota and the per-task
+// limit of the key type; lines past either are not accepted and the response reports the task as truncated. Once the
+// body is read the task is put on the task queue like any other task and the response returns, without waiting for
+// processing to start. A body that cannot be read to the end fails the request and no task is kept.
+func (s *Server) handleTasksStream(w http.ResponseWriter, r *http.Request) {
+	key := r.Context().Value("api_key").(*auth.APIKey)
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") {
+		respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be text/plain")
+		return
+	}
+
+	checks, err := checksFromQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fresh, err := freshFromQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// A retried request with the same Idempotency-Key gets the original task back without reading the body
+	taskID, replay, release, err := s.reserveTaskID(r, key.Key)
+	if err != nil {
+		respondTaskReservationError(w, err)
+		return
+	}
+	if replay {
+		respondTaskCreated(w, taskID, true)
+		return
+	}
+
+	// Tasks of keys with a default webhook notify it like /tasks-with-webhook
+	defaultWebhook, err := s.defaultWebhook(r.Context(), key.Key)
+	if err != nil {
+		release()
+		logger.Log(fmt.Sprintf("[Webhook] Failed to load default webhook: %v", err))
+		respondError(w, http.StatusInternalServerError, "Failed to load default webhook")
+		return
+	}
+
+	task := &types.Task{
+		ID:           taskID,
+		Status:       "receiving",
+		CreatedAt:    time.Now(),
+		APIKey:       key.Key,
+		RequestID:    logger.RequestID(r.Context()),
+		TraceContext: tracing.Inject(r.Context()),
+		Checks:       checks,
+		Fresh:        fresh,
+	}
+	if defaultWebhook != nil {
+		task.Webhooks = []types.WebhookConfig{*defaultWebhook}
+	}
+	if err := s.storage.SaveTask(r.Context(), task); err != nil {
+		release()
+		respondSaveTaskError(w, err)
+		return
+	}
+	// Drops the half-received task so a failed request leaves nothing behind and can be retried
+	discard := func() {
+		if err := s.storage.DeleteTask(context.WithoutCancel(r.Context()), taskID); err != nil {
+			logger.Log(fmt.Sprintf("[Stream] Failed to remove task %s: %v", taskID, err))
+		}
+		release()
+	}
+
+	limit := min(key.Remaining, s.maxTaskEmails(key))
+	truncated := false
+	saved := 0
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		email := strings.TrimSpace(scanner.Text())
+		if email == "" || strings.HasPrefix(email, "#") {
+			continue
+		}
+		// Stop accepting emails once the key quota or the task limit is used up
+		if len(task.Emails) >= limit {
+			truncated = true
+			break
+		}
+		task.Emails = append(task.Emails, email)
+		if len(task.Emails)-saved < streamChunkSize {
+			continue
+		}
+		if err := s.storage.UpdateTask(r.Context(), task); err != nil {
+			discard()
+			respondSaveTaskError(w, err)
+			return
+		}
+		saved = len(task.Emails)
+	}
+	if err := scanner.Err(); err != nil {
+		discard()
+		logger.Log(fmt.Sprintf("[Stream] Task %s: body read interrupted after %d emails: %v", taskID, len(task.Emails), err))
+		if errors.Is(err, bufio.ErrTooLong) {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Line longer than %d bytes", bufio.MaxScanTokenSize))
+			return
+		}
+		respondError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	// Over-long addresses are rejected like in POST /tasks, indexed by their position among the accepted emails
+	if s.rejectOversizedEmails(w, task.Emails) {
+		discard()
+		return
+	}
+
+	task.Status = "pending"
+	if err := s.storage.UpdateTask(r.Context(), task); err != nil {
+		discard()
+		respondSaveTaskError(w, err)
+		return
+	}
+	s.saveTaskWebhook(r.Context(), task)
+	if err := s.storage.EnqueueTask(task); err != nil {
+		discard()
+		logger.Log(fmt.Sprintf("[Task] Failed to queue %s: %v", task.ID, err))
+		respondError(w, http.StatusInternalServerError, "Failed to queue task")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"task_id":   taskID,
+		"accepted":  len(task.Emails),
+		"truncated": truncated,
+	})
+}
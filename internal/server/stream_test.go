@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/storage"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// postStream submits a newline-delimited body to handleTasksStream as key
+func postStream(s *Server, key *auth.APIKey, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/tasks/stream", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/plain")
+	req = req.WithContext(context.WithValue(req.Context(), "api_key", key))
+	rec := httptest.NewRecorder()
+	s.handleTasksStream(rec, req)
+	return rec
+}
+
+func TestStreamedTaskIsQueued(t *testing.T) {
+	store := storage.NewMemoryStorage(nil)
+	s := &Server{storage: store} // No task loops run: the response must not wait for processing
+	key := &auth.APIKey{Key: "key", Remaining: 100}
+
+	rec := postStream(s, key, "a@example.com\n# comment\n\nb@example.com\n c@example.com \n")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /tasks/stream = %d: %s", rec.Code, rec.Body)
+	}
+	var response struct {
+		TaskID    string `json:"task_id"`
+		Accepted  int    `json:"accepted"`
+		Truncated bool   `json:"truncated"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Accepted != 3 || response.Truncated {
+		t.Fatalf("accepted %d, truncated %v; want 3, false", response.Accepted, response.Truncated)
+	}
+
+	queued, err := store.DequeueTask()
+	if err != nil {
+		t.Fatalf("streamed task was not queued: %v", err)
+	}
+	if queued.ID != response.TaskID || queued.Status != "pending" {
+		t.Fatalf("queued task %s with status %q, want %s pending", queued.ID, queued.Status, response.TaskID)
+	}
+	want := []string{"a@example.com", "b@example.com", "c@example.com"}
+	if strings.Join(queued.Emails, ",") != strings.Join(want, ",") {
+		t.Fatalf("queued emails %v, want %v", queued.Emails, want)
+	}
+}
+
+func TestStreamStopsAtQuota(t *testing.T) {
+	store := storage.NewMemoryStorage(nil)
+	s := &Server{storage: store}
+	key := &auth.APIKey{Key: "key", Remaining: 2}
+
+	rec := postStream(s, key, "a@example.com\nb@example.com\nc@example.com\n")
+	var response struct {
+		Accepted  int  `json:"accepted"`
+		Truncated bool `json:"truncated"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Accepted != 2 || !response.Truncated {
+		t.Fatalf("accepted %d, truncated %v; want 2, true", response.Accepted, response.Truncated)
+	}
+	if depth, _ := store.QueueLen(); depth != 1 {
+		t.Fatalf("queue depth = %d, want 1", depth)
+	}
+}
+
+func TestStreamRequiresTextPlain(t *testing.T) {
+	s := &Server{storage: storage.NewMemoryStorage(nil)}
+	req := httptest.NewRequest(http.MethodPost, "/tasks/stream", strings.NewReader("a@example.com"))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), "api_key", &auth.APIKey{Remaining: 1}))
+	rec := httptest.NewRecorder()
+	s.handleTasksStream(rec, req)
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status = %d, want 415", rec.Code)
+	}
+}
+
+func TestStreamStopsAtTaskLimit(t *testing.T) {
+	store := storage.NewMemoryStorage(nil)
+	s := &Server{storage: store}
+	s.SetTaskLimits(2, 0)
+	key := &auth.APIKey{Key: "key", Remaining: 100}
+
+	rec := postStream(s, key, "a@example.com\nb@example.com\nc@example.com\n")
+	var response struct {
+		Accepted  int  `json:"accepted"`
+		Truncated bool `json:"truncated"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Accepted != 2 || !response.Truncated {
+		t.Fatalf("accepted %d, truncated %v; want 2, true", response.Accepted, response.Truncated)
+	}
+}
+
+// storedTasks returns the number of tasks in store
+func storedTasks(t *testing.T, store storage.Storage) int {
+	t.Helper()
+	n := 0
+	if err := store.WalkTasks(context.Background(), func(*types.Task) error { n++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestStreamReadErrorFailsWithoutLeavingTask(t *testing.T) {
+	store := storage.NewMemoryStorage(nil)
+	s := &Server{storage: store}
+	key := &auth.APIKey{Key: "key", Remaining: 100}
+
+	for name, body := range map[string]io.Reader{
+		"interrupted":   io.MultiReader(strings.NewReader("a@example.com\n"), iotest.ErrReader(errors.New("connection reset"))),
+		"line too long": strings.NewReader("a@example.com\n" + strings.Repeat("x", bufio.MaxScanTokenSize+1) + "\n"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/tasks/stream", body)
+			req.Header.Set("Content-Type", "text/plain")
+			req.Header.Set(idempotencyHeader, "retry-"+name)
+			req = req.WithContext(context.WithValue(req.Context(), "api_key", key))
+			rec := httptest.NewRecorder()
+			s.handleTasksStream(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+			}
+			if n := storedTasks(t, store); n != 0 {
+				t.Fatalf("%d tasks stored after a failed stream, want 0", n)
+			}
+			if depth, _ := store.QueueLen(); depth != 0 {
+				t.Fatalf("queue depth = %d, want 0", depth)
+			}
+			// The idempotency key is released so the client can retry
+			if _, created, _ := store.ReserveIdempotencyKey(context.Background(), key.Key+":retry-"+name, "other", time.Minute); !created {
+				t.Fatal("idempotency key still reserved after a failed stream")
+			}
+		})
+	}
+}
+
+func TestStreamRejectsOversizedEmails(t *testing.T) {
+	store := storage.NewMemoryStorage(nil)
+	s := &Server{storage: store}
+	key := &auth.APIKey{Key: "key", Remaining: 100}
+
+	rec := postStream(s, key, "a@example.com\n"+strings.Repeat("x", maxEmailLength)+"@example.com\n")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+	}
+	if n := storedTasks(t, store); n != 0 {
+		t.Fatalf("%d tasks stored after a rejected stream, want 0", n)
+	}
+}
+
+func TestStreamIdempotentReplay(t *testing.T) {
+	store := storage.NewMemoryStorage(nil)
+	s := &Server{storage: store}
+	key := &auth.APIKey{Key: "key", Remaining: 1}
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/tasks/stream", strings.NewReader("a@example.com\n"))
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set(idempotencyHeader, "once")
+		req = req.WithContext(context.WithValue(req.Context(), "api_key", key))
+		rec := httptest.NewRecorder()
+		s.handleTasksStream(rec, req)
+		return rec
+	}
+	taskID := func(rec *httptest.ResponseRecorder) string {
+		var response struct {
+			TaskID string `json:"task_id"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response.TaskID
+	}
+
+	first := post()
+	replayed := post()
+	if replayed.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("repeated stream was not reported as a replay")
+	}
+	if a, b := taskID(first), taskID(replayed); a == "" || a != b {
+		t.Fatalf("replay returned task %q, want %q", b, a)
+	}
+	if depth, _ := store.QueueLen(); depth != 1 {
+		t.Fatalf("queue depth = %d, want 1", depth)
+	}
+}
+
+// failingUpdates fails UpdateTask after the given number of calls
+type failingUpdates struct {
+	storage.Storage
+	updates int
+	failAt  int
+}
+
+func (f *failingUpdates) UpdateTask(ctx context.Context, task *types.Task) error {
+	f.updates++
+	if f.updates == f.failAt {
+		return errors.New("storage unavailable")
+	}
+	return f.Storage.UpdateTask(ctx, task)
+}
+
+func TestStreamSavesInChunks(t *testing.T) {
+	var body strings.Builder
+	for i := range 2*streamChunkSize + 1 {
+		fmt.Fprintf(&body, "user%d@example.com\n", i)
+	}
+	key := &auth.APIKey{Key: "key", Remaining: 10 * streamChunkSize}
+
+	store := &failingUpdates{Storage: storage.NewMemoryStorage(nil)}
+	s := &Server{storage: store}
+	s.SetTaskLimits(10*streamChunkSize, 0)
+	if rec := postStream(s, key, body.String()); rec.Code != http.StatusOK {
+		t.Fatalf("POST /tasks/stream = %d: %s", rec.Code, rec.Body)
+	}
+	if store.updates != 3 { // Two full chunks and the final pending save
+		t.Fatalf("task saved %d times, want 3", store.updates)
+	}
+
+	// A failed chunk save removes the task instead of leaving it receiving
+	store = &failingUpdates{Storage: storage.NewMemoryStorage(nil), failAt: 2}
+	s.storage = store
+	if rec := postStream(s, key, body.String()); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500: %s", rec.Code, rec.Body)
+	}
+	if n := storedTasks(t, store); n != 0 {
+		t.Fatalf("%d tasks stored after a failed save, want 0", n)
+	}
+}
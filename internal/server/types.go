@@ -2,6 +2,7 @@ package server
 
 import (
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -9,29 +10,83 @@ import (
 
 	_ "github.com/shuliakovsky/email-checker/docs"
 	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/cache"
+	"github.com/shuliakovsky/email-checker/internal/checker"
 	"github.com/shuliakovsky/email-checker/internal/storage"
 	"github.com/shuliakovsky/email-checker/internal/throttle"
+	"github.com/shuliakovsky/email-checker/pkg/types"
 )
 
 // Represents task status information for API responses
 type TaskStatusResponse struct {
 	Status       string    `json:"status"`
+	TotalEmails  int       `json:"total_emails"` // Emails in the task; total_results/total_emails is the progress
 	TotalResults int       `json:"total_results"`
+	Processed    int       `json:"processed"`
+	Skipped      int       `json:"skipped"`
 	CreatedAt    time.Time `json:"created_at"`
 	TotalPages   int       `json:"total_pages,omitempty"`
+
+	Summary *types.TaskSummary `json:"summary,omitempty"` // Deliverability breakdown of the results so far
+
+	ExportURL   string `json:"export_url,omitempty"`   // Object the results were uploaded to, for tasks requesting an export
+	ExportError string `json:"export_error,omitempty"` // Why the export upload failed
+}
+
+// BuildInfo identifies the running build for the /version endpoint
+type BuildInfo struct {
+	Version    string `json:"version"`
+	CommitHash string `json:"commit_hash,omitempty"`
+	BuildTime  string `json:"build_time,omitempty"`
+	GoVersion  string `json:"go_version"`
 }
 
 // Core server structure holding dependencies and configuration
 type Server struct {
-	storage         storage.Storage
-	redisClient     redis.UniversalClient
-	host            string
-	port            string
-	maxWorkers      int
-	clusterMode     bool
-	throttleManager *throttle.ThrottleManager
-	authService     *auth.AuthService
-	db              *sqlx.DB
+	storage             storage.Storage
+	redisClient         redis.UniversalClient
+	host                string
+	port                string
+	maxWorkers          int
+	taskConcurrency     int                 // Tasks processed at once; each uses maxWorkers email workers
+	taskPool            *workerPool         // Task processing loops, resized with taskConcurrency (nil before Start)
+	preloadCache        bool                // Cache stored task results at startup
+	mxCache             cache.Provider      // MX record cache; the storage cache is shared when nil
+	ttlJitter           float64             // Random +/- fraction applied to cache TTLs
+	rejectDisposable    bool                // Skip DNS/SMTP for disposable addresses
+	allowDomains        *checker.DomainList // Domains assumed deliverable without SMTP checks
+	blockDomains        *checker.DomainList // Domains reported undeliverable without SMTP checks
+	maxBatchEmails      int                 // Emails per /check-batch request (DefaultMaxBatchEmails if zero)
+	corsOrigins         []string            // Origins allowed for cross-origin requests (any if empty)
+	clusterMode         bool
+	throttleManager     *throttle.ThrottleManager
+	authService         *auth.AuthService
+	db                  *sqlx.DB
+	dryRun              bool
+	groupByDomain       bool
+	taskTimeout         time.Duration
+	domainAge           bool
+	buildInfo           BuildInfo
+	overrides           map[string]types.EmailReport // Known results bypassing network checks
+	lenientEmailLength  bool                         // Report over-long addresses as invalid instead of rejecting the batch
+	maxEmails           int                          // Emails per task limit (DefaultMaxTaskEmails if zero)
+	maxEmailsMonthly    int                          // Emails per task limit for monthly keys (maxEmails if zero)
+	tlsCert             string                       // TLS certificate file; plain HTTP when empty
+	tlsKey              string                       // TLS private key file
+	redirectAddr        string                       // Plain HTTP address redirecting to HTTPS (disabled if empty)
+	metricsAddr         string                       // Internal listener for /metrics and probes (public API if empty)
+	proxyProtocol       bool                         // Expect a PROXY protocol header on API connections
+	exportDefaults      types.ExportConfig           // S3-compatible destination of exports without their own bucket
+	exportSecrets       sync.Map                     // Secret keys of own export destinations by task ID, never stored
+	webhookCertificates sync.Map                     // Client certificates of task webhooks by task ID, never stored
+	settingsMu          sync.RWMutex                 // Guards settings replaced by config reloads (workers, limits, check options)
+	runtimeMu           sync.Mutex                   // Serializes /admin/config updates
+	runtimeFile         string                       // File persisting runtime overrides (disabled if empty)
+	runtimeOverrides    RuntimeConfig                // Overrides applied through /admin/config
+	listenersMu         sync.Mutex                   // Guards listeners
+	listeners           []*http.Server               // Active listeners stopped by Shutdown
+	webhookPolicy       webhookPolicy                // Destinations webhooks may reach
+	webhookTransport    *http.Transport              // Shared by webhooks without custom TLS settings
 }
 
 // response writer
@@ -5,93 +5,325 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/lib/pq"
+
 	_ "github.com/shuliakovsky/email-checker/docs"
+	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/logger"
 	"github.com/shuliakovsky/email-checker/internal/metrics"
+	"github.com/shuliakovsky/email-checker/internal/tracing"
 	"github.com/shuliakovsky/email-checker/pkg/types"
 )
 
+// headerNamePattern matches valid HTTP header field names
+var headerNamePattern = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+const (
+	maxWebhookDestinations = 10      // Limits the webhooks notified per task
+	undefinedColumn        = "42703" // PostgreSQL error code of a query naming a missing column
+)
+
 func (s *Server) handleTasksWithWebhook(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
 		var request struct {
-			Emails  []string            `json:"emails"`
-			Webhook types.WebhookConfig `json:"webhook"`
+			Emails   []string              `json:"emails"`
+			Webhook  *types.WebhookConfig  `json:"webhook"`  // Single destination, kept for compatibility
+			Webhooks []types.WebhookConfig `json:"webhooks"` // Destinations notified in addition to webhook
+			Checks   json.RawMessage       `json:"checks"`
+			Fresh    bool                  `json:"fresh"`
+			Export   *exportRequest        `json:"export"` // Upload the results on completion
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, "Invalid JSON format")
 			return
 		}
 
-		// Parse TTL from a string into time.Duration
-		ttl, err := time.ParseDuration(request.Webhook.TTLStr)
+		key := r.Context().Value("api_key").(*auth.APIKey)
+
+		// A retried request with the same Idempotency-Key gets the original task back before any other check,
+		// so a retry still succeeds once the quota is spent or a default webhook changed
+		taskID, replay, release, err := s.reserveTaskID(r, key.Key)
 		if err != nil {
-			http.Error(w, "Invalid TTL format (e.g., '1h', '30m')", http.StatusBadRequest)
+			respondTaskReservationError(w, err)
+			return
+		}
+		if replay {
+			respondTaskCreated(w, taskID, true)
 			return
 		}
-		request.Webhook.TTL = ttl // Save the converted value
+		created := false
+		defer func() {
+			if !created {
+				release() // Rejected or failed requests leave the key free for a corrected retry
+			}
+		}()
 
-		// Validate webhook parameters
-		if request.Webhook.URL == "" || request.Webhook.Retries <= 0 {
-			http.Error(w, "Invalid webhook config", http.StatusBadRequest)
+		// limit the batch size by the key type
+		if limit := s.maxTaskEmails(key); len(request.Emails) > limit {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Too many emails (max %d for %s keys)", limit, key.Type))
+			return
+		}
+		if s.rejectOversizedEmails(w, request.Emails) {
+			return
+		}
+
+		webhooks := request.Webhooks
+		if request.Webhook != nil {
+			webhooks = append([]types.WebhookConfig{*request.Webhook}, webhooks...)
+		}
+		if len(webhooks) > maxWebhookDestinations {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Too many webhooks (max %d)", maxWebhookDestinations))
+			return
+		}
+		for i := range webhooks {
+			if err := s.prepareWebhook(r.Context(), &webhooks[i]); err != nil {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		if len(webhooks) == 0 {
+			defaultWebhook, err := s.defaultWebhook(r.Context(), key.Key)
+			if err != nil {
+				logger.Log(fmt.Sprintf("[Webhook] Failed to load default webhook: %v", err))
+				respondError(w, http.StatusInternalServerError, "Failed to load default webhook")
+				return
+			}
+			if defaultWebhook == nil {
+				respondError(w, http.StatusBadRequest, "Missing webhook config and the key has no default webhook")
+				return
+			}
+			webhooks = []types.WebhookConfig{*defaultWebhook}
+		}
+		checks, err := parseChecks(request.Checks)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		fresh, err := freshFromQuery(r.URL.Query())
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		exportConfig, exportSecret, err := s.prepareExport(r.Context(), request.Export)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		taskID := s.generateID()
 		task := &types.Task{
-			ID:        taskID,
-			Status:    "pending",
-			Emails:    request.Emails,
-			CreatedAt: time.Now(),
-			Webhook:   &request.Webhook,
+			ID:           taskID,
+			Status:       "pending",
+			Emails:       request.Emails,
+			CreatedAt:    time.Now(),
+			Webhooks:     webhooks,
+			APIKey:       key.Key,
+			RequestID:    logger.RequestID(r.Context()),
+			TraceContext: tracing.Inject(r.Context()),
+			Checks:       checks,
+			Fresh:        fresh || request.Fresh,
+			Export:       exportConfig,
 		}
 
+		s.holdWebhookCertificates(task) // Before saving, so they are not stored with the task
+
 		// Save task and webhook to Redis
 		if err := s.storage.SaveTask(r.Context(), task); err != nil {
-			http.Error(w, "Failed to save task", http.StatusInternalServerError)
+			s.webhookCertificates.Delete(task.ID)
+			respondSaveTaskError(w, err)
 			return
 		}
 
-		// Save webhook separately for clustered mode
-		if s.clusterMode {
-			webhookKey := fmt.Sprintf("webhook:task:%s", taskID)
-			data, _ := json.Marshal(request.Webhook)
-			s.redisClient.Set(r.Context(), webhookKey, data, ttl) // Use ttl of type time.Duration
+		s.saveTaskWebhook(r.Context(), task)
+		s.keepExportSecret(task.ID, exportSecret)
+
+		if err := s.storage.EnqueueTask(task); err != nil {
+			s.exportSecrets.Delete(task.ID)
+			s.webhookCertificates.Delete(task.ID)
+			logger.Log(fmt.Sprintf("[Task] Failed to queue %s: %v", task.ID, err))
+			respondError(w, http.StatusInternalServerError, "Failed to queue task")
+			return
 		}
 
-		go s.processTask(task) // Start processing
+		created = true
+		respondTaskCreated(w, taskID, false)
+		return
+	}
+	respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+// prepareWebhook parses the TTL of a webhook config and validates its parameters and destination
+func (s *Server) prepareWebhook(ctx context.Context, cfg *types.WebhookConfig) error {
+	ttl, err := time.ParseDuration(cfg.TTLStr)
+	if err != nil {
+		return fmt.Errorf("Invalid TTL format (e.g., '1h', '30m')")
+	}
+	cfg.TTL = ttl
+	if cfg.URL == "" || cfg.Retries <= 0 {
+		return fmt.Errorf("Invalid webhook config")
+	}
+	if err := s.webhookPolicy.checkURL(ctx, cfg.URL); err != nil {
+		return err
+	}
+	if err := validateSignatureConfig(*cfg); err != nil {
+		return err
+	}
+	if s.clusterMode && (cfg.ClientCert != "" || cfg.ClientKey != "") {
+		// Client certificates stay on the node accepting the task, which may not be the one completing it
+		return fmt.Errorf("webhook client certificates are not available in cluster mode")
+	}
+	for name := range cfg.Headers {
+		if !headerNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid webhook header %q", name)
+		}
+	}
+	_, err = s.webhookClient(*cfg)
+	return err
+}
+
+// webhookCertificate is the client certificate of one webhook destination
+type webhookCertificate struct {
+	cert string
+	key  string
+}
+
+// holdWebhookCertificates moves the client certificates of the task's webhooks into process memory
+// for the longest webhook TTL, so the private keys are never written to storage
+func (s *Server) holdWebhookCertificates(task *types.Task) {
+	certs := make([]webhookCertificate, len(task.Webhooks))
+	held := false
+	var ttl time.Duration
+	for i := range task.Webhooks {
+		webhook := &task.Webhooks[i]
+		ttl = max(ttl, webhook.TTL)
+		if webhook.ClientCert == "" && webhook.ClientKey == "" {
+			continue
+		}
+		certs[i] = webhookCertificate{cert: webhook.ClientCert, key: webhook.ClientKey}
+		webhook.ClientCert, webhook.ClientKey = "", ""
+		held = true
+	}
+	if !held {
+		return
+	}
+	s.webhookCertificates.Store(task.ID, certs)
+	time.AfterFunc(ttl, func() { s.webhookCertificates.Delete(task.ID) })
+}
+
+// withWebhookCertificates returns a copy of the task's webhooks with the client certificates held in memory
+func (s *Server) withWebhookCertificates(taskID string, webhooks []types.WebhookConfig) []types.WebhookConfig {
+	held, ok := s.webhookCertificates.Load(taskID)
+	if !ok {
+		return webhooks
+	}
+	webhooks = append([]types.WebhookConfig(nil), webhooks...) // The stored task must not get them back
+	for i, cert := range held.([]webhookCertificate) {
+		if i < len(webhooks) && cert != (webhookCertificate{}) {
+			webhooks[i].ClientCert, webhooks[i].ClientKey = cert.cert, cert.key
+		}
+	}
+	return webhooks
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"task_id": taskID})
+// saveTaskWebhook stores the task's webhooks separately for clustered mode, kept for the longest webhook TTL
+func (s *Server) saveTaskWebhook(ctx context.Context, task *types.Task) {
+	webhooks := taskWebhooks(task)
+	if len(webhooks) == 0 || !s.clusterMode || s.redisClient == nil {
 		return
 	}
-	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	var ttl time.Duration
+	for _, webhook := range webhooks {
+		ttl = max(ttl, webhook.TTL)
+	}
+	data, _ := json.Marshal(webhooks)
+	s.redisClient.Set(ctx, fmt.Sprintf("webhook:task:%s", task.ID), data, ttl)
+}
+
+// defaultWebhook returns the webhook an administrator configured for the key (nil if none)
+// A key database without migration 003 has no default webhooks, so task creation keeps working before it is applied
+func (s *Server) defaultWebhook(ctx context.Context, apiKey string) (*types.WebhookConfig, error) {
+	if s.db == nil {
+		return nil, nil
+	}
+	var data []byte
+	err := s.db.GetContext(ctx, &data, `
+        SELECT default_webhook
+        FROM api_keys
+        WHERE api_key = $1`, apiKey)
+	if noDefaultWebhook(err) {
+		return nil, nil
+	}
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var webhook types.WebhookConfig
+	if err := json.Unmarshal(data, &webhook); err != nil {
+		return nil, fmt.Errorf("decode default webhook: %w", err)
+	}
+	webhook.TTL, _ = time.ParseDuration(webhook.TTLStr) // TTL is not serialized
+	return &webhook, nil
+}
+
+// noDefaultWebhook reports whether a default webhook lookup failed only because there is none:
+// the key row is missing or the default_webhook column does not exist yet
+func noDefaultWebhook(err error) bool {
+	var pqErr *pq.Error
+	return errors.Is(err, sql.ErrNoRows) || (errors.As(err, &pqErr) && pqErr.Code == undefinedColumn)
 }
 
 // sendWebhookRequest executes HTTP POST request to webhook URL
-func (s *Server) sendWebhookRequest(task *types.Task, cfg types.WebhookConfig, attemptKey string) bool {
+func (s *Server) sendWebhookRequest(client *http.Client, task *types.Task, cfg types.WebhookConfig, attempts int) bool {
 	startTime := time.Now()
 
-	attempts, _ := s.redisClient.Get(context.Background(), attemptKey).Int()
-
-	payload, _ := json.Marshal(map[string]interface{}{
+	body := map[string]interface{}{
 		"task_id":  task.ID,
 		"status":   task.Status,
-		"results":  len(task.Results),
+		"results":  len(task.Emails), // Every email yields one result once the task completes
 		"ttl":      cfg.TTLStr,
 		"attempts": attempts,
 		"lifetime": time.Since(task.CreatedAt).String(),
-	})
+	}
+	if task.ExportURL != "" {
+		body["export_url"] = task.ExportURL
+	}
+	if task.ExportError != "" {
+		body["export_error"] = task.ExportError
+	}
+	payload, _ := json.Marshal(body)
 
 	req, _ := http.NewRequest("POST", cfg.URL, bytes.NewBuffer(payload))
+	for name, value := range cfg.Headers {
+		req.Header.Set(name, value)
+	}
 	req.Header.Set("Content-Type", "application/json")
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Webhook-Timestamp", timestamp) // Lets receivers reject stale or replayed deliveries
 	if cfg.Secret != "" {
-		req.Header.Set("X-Signature", generateSignature(payload, cfg.Secret))
+		header := cfg.SignatureHeader
+		if header == "" {
+			header = "X-Signature"
+		}
+		signed := payload
+		if cfg.SignTimestamp {
+			signed = append([]byte(timestamp+"."), payload...) // Bind the signature to the timestamp
+		}
+		req.Header.Set(header, cfg.SignaturePrefix+generateSignature(signed, cfg))
 	}
 
 	defer func() {
@@ -99,7 +331,10 @@ func (s *Server) sendWebhookRequest(task *types.Task, cfg types.WebhookConfig, a
 	}()
 
 	// Send request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
 	success := err == nil && resp.StatusCode < 400
 
 	// Update metrics
@@ -109,47 +344,181 @@ func (s *Server) sendWebhookRequest(task *types.Task, cfg types.WebhookConfig, a
 	}
 	metrics.WebhookAttempts.WithLabelValues(task.ID, statusLabel).Inc()
 
-	if !success && attempts > 0 {
+	if !success && attempts > 1 {
 		metrics.WebhookRetries.Inc()
 	}
 
 	return success
 }
 
-// triggerWebhook sends notification and handles retries
+// triggerWebhook notifies every webhook of the task in parallel and returns once all deliveries finished
+// Each destination is retried independently, so a failing receiver does not delay or block the others
 func (s *Server) triggerWebhook(task *types.Task) {
-	webhookKey := fmt.Sprintf("webhook:task:%s", task.ID)
-	var webhook types.WebhookConfig
+	var wg sync.WaitGroup
+	for i, webhook := range s.withWebhookCertificates(task.ID, s.webhookConfigs(task)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.deliverWebhook(task, i, webhook)
+		}()
+	}
+	wg.Wait()
+}
 
-	// Get webhook config from Redis or local storage
-	if s.clusterMode {
-		data, err := s.redisClient.Get(context.Background(), webhookKey).Result()
-		if err != nil {
+// deliverWebhook sends the notification to one destination and handles retries
+// Attempts are counted in memory and mirrored to Redis when it is configured
+func (s *Server) deliverWebhook(task *types.Task, index int, webhook types.WebhookConfig) {
+	host := metrics.DomainLabel(webhookHost(webhook.URL)) // Client-supplied, so capped like domains
+	client, err := s.webhookClient(webhook)
+	if err != nil {
+		logger.Log(fmt.Sprintf("[Webhook] Task %s delivery to %s skipped: %v", task.ID, webhook.URL, err))
+		metrics.WebhookDeliveries.WithLabelValues(host, "failure").Inc()
+		return
+	}
+
+	attemptKey := fmt.Sprintf("webhook:task:%s:attempts", task.ID)
+	if index > 0 {
+		attemptKey = fmt.Sprintf("webhook:task:%s:%d:attempts", task.ID, index)
+	}
+	for attempt := 1; attempt <= webhook.Retries; attempt++ {
+		if s.redisClient != nil {
+			s.redisClient.Set(context.Background(), attemptKey, attempt, webhook.TTL) // Share attempt counter across nodes
+		}
+		if s.sendWebhookRequest(client, task, webhook, attempt) {
+			logger.Log(fmt.Sprintf("[Webhook] Task %s delivered to %s after %d attempt(s)", task.ID, webhook.URL, attempt))
+			metrics.WebhookDeliveries.WithLabelValues(host, "success").Inc()
 			return
 		}
-		json.Unmarshal([]byte(data), &webhook)
-	} else {
-		webhook = *task.Webhook
+		if attempt < webhook.Retries {
+			time.Sleep(2 * time.Second)
+		}
+	}
+	logger.Log(fmt.Sprintf("[Webhook] Task %s delivery to %s failed after %d attempts", task.ID, webhook.URL, webhook.Retries))
+	metrics.WebhookDeliveries.WithLabelValues(host, "failure").Inc()
+}
+
+// webhookHost returns the host of a webhook URL, used as the destination metric label
+func webhookHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "invalid"
+	}
+	return u.Host
+}
+
+// webhookClient returns the HTTP client for a webhook, with mutual TLS and a custom CA when configured
+// Every client only connects to destinations allowed by the webhook policy
+func (s *Server) webhookClient(cfg types.WebhookConfig) (*http.Client, error) {
+	if cfg.ClientCert == "" && cfg.ClientKey == "" && cfg.CACert == "" {
+		return &http.Client{Transport: s.webhookTransport, CheckRedirect: s.webhookPolicy.checkRedirect}, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(cfg.ClientCert), []byte(cfg.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(cfg.CACert)) {
+			return nil, fmt.Errorf("invalid webhook ca_cert: no PEM certificates found")
+		}
+		tlsConfig.RootCAs = pool
 	}
 
-	attemptKey := webhookKey + ":attempts"
-	s.redisClient.Set(context.Background(), attemptKey, 1, webhook.TTL) // Initialize counter
+	transport := s.webhookPolicy.transport()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, CheckRedirect: s.webhookPolicy.checkRedirect}, nil
+}
 
-	for i := 0; i < webhook.Retries; i++ {
-		currentAttempt, _ := s.redisClient.Get(context.Background(), attemptKey).Int()
-		success := s.sendWebhookRequest(task, webhook, attemptKey)
-		if success {
-			s.redisClient.Set(context.Background(), attemptKey, currentAttempt-1, webhook.TTL)
-			break
+// webhookConfigs returns the task's webhook configs from Redis (cluster mode) or the task itself
+func (s *Server) webhookConfigs(task *types.Task) []types.WebhookConfig {
+	if s.clusterMode && s.redisClient != nil {
+		data, err := s.redisClient.Get(context.Background(), fmt.Sprintf("webhook:task:%s", task.ID)).Bytes()
+		if err == nil {
+			var webhooks []types.WebhookConfig
+			if json.Unmarshal(data, &webhooks) != nil {
+				var single types.WebhookConfig // Stored by nodes predating multiple webhooks
+				if json.Unmarshal(data, &single) == nil {
+					webhooks = []types.WebhookConfig{single}
+				}
+			}
+			for i := range webhooks {
+				webhooks[i].TTL, _ = time.ParseDuration(webhooks[i].TTLStr) // TTL is not serialized
+			}
+			if len(webhooks) > 0 {
+				return webhooks
+			}
 		}
-		s.redisClient.Incr(context.Background(), attemptKey)
-		time.Sleep(2 * time.Second)
 	}
+	return taskWebhooks(task)
+}
+
+// taskWebhooks returns the destinations stored with the task, including the single webhook of older tasks
+func taskWebhooks(task *types.Task) []types.WebhookConfig {
+	if task.Webhook != nil {
+		return append([]types.WebhookConfig{*task.Webhook}, task.Webhooks...)
+	}
+	return task.Webhooks
+}
+
+// handleWebhookRetry re-sends the webhook of a completed task, e.g. after the receiver was down
+func (s *Server) handleWebhookRetry(w http.ResponseWriter, r *http.Request) {
+	key := r.Context().Value("api_key").(*auth.APIKey)
+	task, err := s.storage.GetTask(r.Context(), r.PathValue("task_id"))
+	if err != nil || task.APIKey != key.Key {
+		respondError(w, http.StatusNotFound, "Task not found") // Do not reveal tasks owned by other keys
+		return
+	}
+	if task.Status != "completed" && task.Status != "completed_partial" {
+		respondError(w, http.StatusNotFound, "Task is not completed")
+		return
+	}
+	if len(s.webhookConfigs(task)) == 0 {
+		respondError(w, http.StatusNotFound, "Task has no webhook")
+		return
+	}
+
+	logger.LogFields("[Webhook] Manual retry requested", logger.Fields{"task_id": task.ID, "request_id": logger.RequestID(r.Context())})
+	go s.triggerWebhook(task)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"task_id": task.ID, "status": "retrying"})
 }
 
-// generateSignature creates HMAC-SHA256 signature for webhook payload
-func generateSignature(payload []byte, secret string) string {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write(payload)
+// generateSignature creates the HMAC signature of the signed content using the configured hash and encoding
+// Defaults to hex-encoded HMAC-SHA256
+func generateSignature(content []byte, cfg types.WebhookConfig) string {
+	hash := sha256.New
+	if cfg.SignatureAlgorithm == "sha512" {
+		hash = sha512.New
+	}
+	h := hmac.New(hash, []byte(cfg.Secret))
+	h.Write(content)
+	if cfg.SignatureEncoding == "base64" {
+		return base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
 	return hex.EncodeToString(h.Sum(nil))
 }
+
+// validateSignatureConfig rejects unsupported webhook signature settings
+func validateSignatureConfig(cfg types.WebhookConfig) error {
+	switch cfg.SignatureAlgorithm {
+	case "", "sha256", "sha512":
+	default:
+		return fmt.Errorf("unsupported signature_algorithm %q (use sha256 or sha512)", cfg.SignatureAlgorithm)
+	}
+	switch cfg.SignatureEncoding {
+	case "", "hex", "base64":
+	default:
+		return fmt.Errorf("unsupported signature_encoding %q (use hex or base64)", cfg.SignatureEncoding)
+	}
+	if cfg.SignatureHeader != "" && !headerNamePattern.MatchString(cfg.SignatureHeader) {
+		return fmt.Errorf("invalid signature_header %q", cfg.SignatureHeader)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/checker"
+)
+
+// webhookResolveTimeout bounds the DNS lookup validating a webhook host
+const webhookResolveTimeout = 5 * time.Second
+
+// webhookPolicy restricts the destinations webhooks may reach, so clients cannot make
+// the server send requests to internal services (SSRF)
+type webhookPolicy struct {
+	hosts    *checker.DomainList // Hosts webhooks may target; nil allows any public host
+	networks []*net.IPNet        // Private, loopback or link-local ranges that may still be reached
+}
+
+// newWebhookPolicy parses the allowlisted hosts and CIDR ranges
+func newWebhookPolicy(hosts, networks []string) (webhookPolicy, error) {
+	policy := webhookPolicy{}
+	if len(hosts) > 0 {
+		policy.hosts = checker.NewDomainList(hosts)
+	}
+	for _, cidr := range networks {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return webhookPolicy{}, fmt.Errorf("invalid webhook network %q: %w", cidr, err)
+		}
+		policy.networks = append(policy.networks, network)
+	}
+	return policy, nil
+}
+
+// SetWebhookPolicy restricts webhook destinations to hosts matching the allowlist (any public host if empty)
+// Private, loopback and link-local addresses are refused unless they fall in one of networks (CIDR notation)
+// Call it before Start
+func (s *Server) SetWebhookPolicy(hosts, networks []string) error {
+	policy, err := newWebhookPolicy(hosts, networks)
+	if err != nil {
+		return err
+	}
+	s.webhookPolicy = policy
+	s.webhookTransport = policy.transport()
+	return nil
+}
+
+// allowedIP reports whether webhooks may connect to ip
+func (p webhookPolicy) allowedIP(ip net.IP) bool {
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return checker.PublicIP(ip)
+}
+
+// checkTarget validates the scheme of a webhook URL and its host against the allowlist
+func (p webhookPolicy) checkTarget(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook url must use http or https")
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("webhook url has no host")
+	}
+	if p.hosts != nil && !p.hosts.Match(host) {
+		return fmt.Errorf("webhook host %s is not allowed", host)
+	}
+	return nil
+}
+
+// checkURL validates a webhook URL and resolves its host, rejecting it when any address is not allowed
+func (p webhookPolicy) checkURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url")
+	}
+	if err := p.checkTarget(u); err != nil {
+		return err
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !p.allowedIP(ip) {
+			return fmt.Errorf("webhook address %s is not allowed", host)
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("cannot resolve webhook host %s", host)
+	}
+	for _, addr := range addrs {
+		if !p.allowedIP(addr.IP) {
+			return fmt.Errorf("webhook host %s resolves to a disallowed address", host)
+		}
+	}
+	return nil
+}
+
+// dialControl refuses connections to disallowed addresses
+// It runs on the resolved address, so a host re-resolving to an internal address after
+// validation (DNS rebinding) is still blocked
+func (p webhookPolicy) dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !p.allowedIP(ip) {
+		return fmt.Errorf("webhook address %s is not allowed", host)
+	}
+	return nil
+}
+
+// checkRedirect applies the scheme and host rules to redirects followed during delivery
+func (p webhookPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return p.checkTarget(req.URL)
+}
+
+// transport returns an HTTP transport that only connects to allowed addresses
+// Environment proxies are not used: the proxy would reach the destination on the server's behalf
+func (p webhookPolicy) transport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.dialControl,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
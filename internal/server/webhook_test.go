@@ -0,0 +1,233 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/shuliakovsky/email-checker/internal/auth"
+	"github.com/shuliakovsky/email-checker/internal/storage"
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+func TestNoDefaultWebhook(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no error", nil, false},
+		{"no row", sql.ErrNoRows, true},
+		{"wrapped no row", fmt.Errorf("lookup: %w", sql.ErrNoRows), true},
+		{"column missing before migration 003", &pq.Error{Code: undefinedColumn}, true},
+		{"other database error", &pq.Error{Code: "42P01"}, false},
+		{"connection error", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := noDefaultWebhook(tt.err); got != tt.want {
+				t.Errorf("noDefaultWebhook(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultWebhookWithoutKeyDatabase(t *testing.T) {
+	webhook, err := (&Server{}).defaultWebhook(context.Background(), "key")
+	if webhook != nil || err != nil {
+		t.Fatalf("defaultWebhook = %v, %v, want nil, nil", webhook, err)
+	}
+}
+
+// webhookReceiver records deliveries, answering the first failures requests with 500
+type webhookReceiver struct {
+	failures int32
+	requests atomic.Int32
+	payloads chan map[string]interface{}
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if rcv.requests.Add(1) <= rcv.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var payload map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&payload)
+	rcv.payloads <- payload
+}
+
+// newWebhookTestServer returns a server without Redis whose webhooks may reach the loopback receiver
+func newWebhookTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := &Server{storage: storage.NewMemoryStorage(nil)}
+	if err := s.SetWebhookPolicy(nil, []string{"127.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// completedTask stores a completed task notifying url
+func completedTask(t *testing.T, s *Server, url string, retries int) *types.Task {
+	t.Helper()
+	task := &types.Task{
+		ID:        "task-1",
+		Status:    "completed",
+		Emails:    []string{"a@example.com", "b@example.com"},
+		CreatedAt: time.Now(),
+		Webhook:   &types.WebhookConfig{URL: url, Retries: retries, TTLStr: "1h", TTL: time.Hour},
+	}
+	if err := s.storage.SaveTask(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestWebhookDeliveryWithoutRedis(t *testing.T) {
+	rcv := &webhookReceiver{payloads: make(chan map[string]interface{}, 1)}
+	receiver := httptest.NewServer(rcv)
+	defer receiver.Close()
+
+	s := newWebhookTestServer(t)
+	s.triggerWebhook(completedTask(t, s, receiver.URL, 1))
+
+	select {
+	case payload := <-rcv.payloads:
+		if payload["task_id"] != "task-1" || payload["status"] != "completed" || payload["results"] != float64(2) || payload["attempts"] != float64(1) {
+			t.Fatalf("payload = %v", payload)
+		}
+	default:
+		t.Fatal("webhook was not delivered")
+	}
+}
+
+func TestWebhookDeliveryRetriesWithoutRedis(t *testing.T) {
+	rcv := &webhookReceiver{failures: 1, payloads: make(chan map[string]interface{}, 1)}
+	receiver := httptest.NewServer(rcv)
+	defer receiver.Close()
+
+	s := newWebhookTestServer(t)
+	s.triggerWebhook(completedTask(t, s, receiver.URL, 2))
+
+	if got := rcv.requests.Load(); got != 2 {
+		t.Fatalf("receiver got %d requests, want 2", got)
+	}
+	select {
+	case payload := <-rcv.payloads:
+		if payload["attempts"] != float64(2) {
+			t.Fatalf("attempts = %v, want 2", payload["attempts"])
+		}
+	default:
+		t.Fatal("webhook was not delivered on retry")
+	}
+}
+
+func TestWebhookDeliveryRefusesLoopbackByDefault(t *testing.T) {
+	rcv := &webhookReceiver{payloads: make(chan map[string]interface{}, 1)}
+	receiver := httptest.NewServer(rcv)
+	defer receiver.Close()
+
+	s := NewServer("127.0.0.1", "0", storage.NewMemoryStorage(nil), nil, 1, false, nil, nil)
+	s.triggerWebhook(completedTask(t, s, receiver.URL, 1))
+	if got := rcv.requests.Load(); got != 0 {
+		t.Fatalf("receiver got %d requests, want none without an allowed network", got)
+	}
+}
+
+// testClientCertificate returns a self-signed PEM certificate and its private key
+func testClientCertificate(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return string(cert), string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestWebhookClientCertificatesAreNotStored(t *testing.T) {
+	cert, key := testClientCertificate(t)
+	s := newWebhookTestServer(t)
+	body, _ := json.Marshal(map[string]interface{}{
+		"emails": []string{"user@example.com"},
+		"webhooks": []types.WebhookConfig{
+			{URL: "http://127.0.0.1:9/plain", TTLStr: "1h", Retries: 1},
+			{URL: "http://127.0.0.1:9/mtls", TTLStr: "1h", Retries: 1, ClientCert: cert, ClientKey: key},
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/tasks-with-webhook", bytes.NewReader(body))
+	apiKey := &auth.APIKey{Key: "key", Type: auth.KeyTypePayAsYouGo, Remaining: 100}
+	req = req.WithContext(context.WithValue(req.Context(), "api_key", apiKey))
+	rec := httptest.NewRecorder()
+	s.handleTasksWithWebhook(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /tasks-with-webhook = %d: %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		TaskID string `json:"task_id"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	task, err := s.storage.GetTask(context.Background(), created.TaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := json.Marshal(task)
+	if bytes.Contains(stored, []byte("PRIVATE KEY")) || bytes.Contains(stored, []byte("CERTIFICATE")) {
+		t.Fatalf("stored task holds the client certificate: %s", stored)
+	}
+
+	webhooks := s.withWebhookCertificates(task.ID, s.webhookConfigs(task))
+	if webhooks[0].ClientCert != "" || webhooks[1].ClientCert != cert || webhooks[1].ClientKey != key {
+		t.Fatal("delivery does not get the client certificate back")
+	}
+	if task.Webhooks[1].ClientKey != "" {
+		t.Fatal("attaching the certificate changed the stored task")
+	}
+}
+
+func TestDefaultWebhookRefusesClientCertificates(t *testing.T) {
+	cert, key := testClientCertificate(t)
+	body, _ := json.Marshal(types.WebhookConfig{URL: "https://hooks.example.com", TTLStr: "1h", Retries: 1, ClientCert: cert, ClientKey: key})
+	req := httptest.NewRequest(http.MethodPut, "/admin/keys/key/webhook", bytes.NewReader(body))
+	req.SetPathValue("api_key", "key")
+	rec := httptest.NewRecorder()
+	(&Server{}).handleSetKeyWebhook(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("PUT default webhook with a client certificate = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestClusterModeRefusesWebhookClientCertificates(t *testing.T) {
+	cert, key := testClientCertificate(t)
+	s := newWebhookTestServer(t)
+	s.clusterMode = true
+	cfg := &types.WebhookConfig{URL: "http://127.0.0.1:9/", TTLStr: "1h", Retries: 1, ClientCert: cert, ClientKey: key}
+	if err := s.prepareWebhook(context.Background(), cfg); err == nil {
+		t.Fatal("cluster mode accepted a webhook client certificate")
+	}
+}
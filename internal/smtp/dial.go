@@ -0,0 +1,144 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/metrics" // Metrics functionality
+)
+
+// IPPreference selects which IP family is dialed first when connecting to MX hosts
+type IPPreference string
+
+const (
+	PreferAny  IPPreference = "any"  // Let the operating system pick the address
+	PreferIPv4 IPPreference = "ipv4" // Dial A records first, fall back to AAAA
+	PreferIPv6 IPPreference = "ipv6" // Dial AAAA records first, fall back to A
+)
+
+// ParseIPPreference validates an IP family preference; an empty value means PreferAny
+func ParseIPPreference(value string) (IPPreference, error) {
+	switch pref := IPPreference(value); pref {
+	case "":
+		return PreferAny, nil
+	case PreferAny, PreferIPv4, PreferIPv6:
+		return pref, nil
+	default:
+		return "", fmt.Errorf("unknown IP preference %q (expected any, ipv4 or ipv6)", value)
+	}
+}
+
+// tlsVersions maps configuration names to TLS protocol versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion converts "1.0".."1.3" to a TLS version; an empty value means the Go default (0)
+func ParseTLSVersion(value string) (uint16, error) {
+	if value == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[value]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q (expected 1.0, 1.1, 1.2 or 1.3)", value)
+	}
+	return version, nil
+}
+
+// tlsConfig builds the client TLS settings for an MX host from the active options
+// Skipping verification keeps the connection encrypted but accepts self-signed or mismatched certificates
+func tlsConfig(host string) *tls.Config {
+	opts := currentOptions()
+	return &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: opts.TLSSkipVerify,
+		MinVersion:         opts.TLSMinVersion,
+	}
+}
+
+// connect establishes an SMTP connection using secure or non-secure protocols
+// Dialing and the TLS handshake end early when ctx is done
+func connect(ctx context.Context, host, port string, connectTimeout time.Duration) (net.Conn, error) {
+	conn, err := dial(ctx, host, port, connectTimeout, currentOptions().IPPreference)
+	if err != nil {
+		return nil, err
+	}
+	if port != "465" {
+		return conn, nil // Non-secure connection
+	}
+
+	// Establish secure connection using TLS within the connection timeout
+	tlsConn := tls.Client(conn, tlsConfig(host))
+	conn.SetDeadline(time.Now().Add(connectTimeout))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s%w", tlsErrorPrefix, err)
+	}
+	return tlsConn, nil
+}
+
+// dial opens the TCP connection to an SMTP endpoint; tests point it at local mock servers
+var dial = dialTCP
+
+// dialTCP connects to host:port honouring the IP family preference, giving up when ctx is done
+// With a preference the host's A/AAAA records are resolved explicitly and tried in
+// preferred order, so IPv6-only or IPv4-only egress can still reach dual-stack MX hosts
+func dialTCP(ctx context.Context, host, port string, timeout time.Duration, pref IPPreference) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if pref == "" || pref == PreferAny {
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+		if err == nil {
+			recordFamily(conn)
+		}
+		return conn, err
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+	addrs, err := net.DefaultResolver.LookupIPAddr(lookupCtx, host)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	sortByPreference(addrs, pref)
+
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr.IP.String(), port))
+		if err == nil {
+			recordFamily(conn)
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses found for %s", host)
+	}
+	return nil, lastErr
+}
+
+// sortByPreference moves addresses of the preferred family to the front, keeping resolver order otherwise
+func sortByPreference(addrs []net.IPAddr, pref IPPreference) {
+	preferred := func(ip net.IP) bool {
+		isV4 := ip.To4() != nil
+		return (pref == PreferIPv4) == isV4
+	}
+	sort.SliceStable(addrs, func(i, j int) bool {
+		return preferred(addrs[i].IP) && !preferred(addrs[j].IP)
+	})
+}
+
+// recordFamily counts an established connection by the IP family of its remote address
+func recordFamily(conn net.Conn) {
+	family := "ipv6"
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && addr.IP.To4() != nil {
+		family = "ipv4"
+	}
+	metrics.SMTPConnections.WithLabelValues(family).Inc()
+}
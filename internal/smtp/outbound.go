@@ -0,0 +1,78 @@
+package smtp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/internal/logger"
+)
+
+const (
+	blockedDomainThreshold = 5 // Distinct domains failing on port 25 before outbound SMTP is considered blocked
+)
+
+// outbound tracks port 25 connectivity across unrelated domains
+var outbound struct {
+	sync.Mutex
+	failedDomains map[string]struct{} // Domains whose port 25 connections failed since the last success
+	blocked       bool                // Whether outbound port 25 appears to be blocked
+}
+
+// CheckOutboundSMTP probes port 25 on the given known-good hosts
+// Returns nil as soon as one host accepts a connection and marks outbound SMTP as blocked otherwise
+func CheckOutboundSMTP(hosts []string, timeout time.Duration) error {
+	var lastErr error
+	for _, host := range hosts {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, "25"), timeout)
+		if err == nil {
+			conn.Close()
+			setOutboundBlocked(false)
+			return nil
+		}
+		lastErr = err
+	}
+	setOutboundBlocked(true)
+	return fmt.Errorf("outbound port 25 appears blocked: %w", lastErr)
+}
+
+// OutboundBlocked reports whether outbound port 25 connectivity appears to be blocked
+func OutboundBlocked() bool {
+	outbound.Lock()
+	defer outbound.Unlock()
+	return outbound.blocked
+}
+
+// recordPort25Result updates the blocked-port heuristic after a port 25 connection attempt
+func recordPort25Result(domain string, err error) {
+	if err == nil {
+		setOutboundBlocked(false)
+		return
+	}
+
+	outbound.Lock()
+	defer outbound.Unlock()
+	if outbound.failedDomains == nil {
+		outbound.failedDomains = make(map[string]struct{})
+	}
+	outbound.failedDomains[domain] = struct{}{}
+	if !outbound.blocked && len(outbound.failedDomains) >= blockedDomainThreshold {
+		outbound.blocked = true
+		logger.Log(fmt.Sprintf("[WARN] Port 25 connections failed for %d unrelated domains; outbound SMTP is likely blocked by the network provider",
+			len(outbound.failedDomains)))
+	}
+}
+
+// setOutboundBlocked sets the blocked flag and resets the failure tracking on recovery
+func setOutboundBlocked(blocked bool) {
+	outbound.Lock()
+	defer outbound.Unlock()
+	if !blocked {
+		if outbound.blocked {
+			logger.Log("[SMTP] Outbound port 25 connectivity restored")
+		}
+		outbound.failedDomains = nil
+	}
+	outbound.blocked = blocked
+}
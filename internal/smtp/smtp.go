@@ -1,137 +1,440 @@
 package smtp
 
 import (
-	"crypto/tls"
+	"context"
 	"fmt"
+	"math/rand"
 	"net"
 	"net/smtp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute" // Trace span attributes
+
 	"github.com/shuliakovsky/email-checker/internal/domains"  // Domains rotation
 	"github.com/shuliakovsky/email-checker/internal/logger"   // Logging utility for activity tracking
 	"github.com/shuliakovsky/email-checker/internal/metrics"  // Metrics functionality
 	"github.com/shuliakovsky/email-checker/internal/throttle" // Throttling functionality
+	"github.com/shuliakovsky/email-checker/internal/tracing"  // OpenTelemetry spans
 )
 
 const (
-	connectTimeout = 3 * time.Second // Timeout for establishing SMTP connections
-	commandTimeout = 8 * time.Second // Timeout for executing SMTP commands
-	maxRetries     = 2               // Maximum number of retry attempts for failed connections
-	retryDelay     = 1 * time.Second // Delay between consecutive retries
+	heloCooldown    = 5 * time.Minute  // Time a rejected HELO domain is excluded from rotation
+	maxRetryBackoff = 30 * time.Second // Longest single pause between SMTP attempts
 )
 
+// NotChecked is the error category of checks cut short because their context was done before any verdict
+const NotChecked = "not_checked"
+
+// Options holds tunable SMTP network settings
+type Options struct {
+	ConnectTimeout time.Duration // Timeout for establishing SMTP connections
+	CommandTimeout time.Duration // Timeout for executing SMTP commands
+	MaxRetries     int           // Maximum number of retry attempts for failed connections
+	RetryDelay     time.Duration // Base delay before the first retry, doubled (with jitter) for each further one
+	MaxRetryTime   time.Duration // Time after the first attempt in which retries may still start (0 for no limit)
+	IPPreference   IPPreference  // IP family dialed first when connecting to MX hosts
+	TLSSkipVerify  bool          // Accept any certificate on STARTTLS/port 465 (opportunistic encryption)
+	TLSMinVersion  uint16        // Minimum TLS version offered to MX hosts (0 uses the Go default, TLS 1.2)
+	TLSFallback    bool          // Repeat the check in plaintext when STARTTLS fails
+	Ports          []string      // SMTP ports probed per MX host, in order (465 uses implicit TLS, 587 STARTTLS)
+	ParallelHosts  int           // MX hosts probed at once, first definitive answer wins (0 or 1 keeps strict priority order)
+}
+
+// DefaultOptions provides the default SMTP network settings
+var DefaultOptions = Options{
+	ConnectTimeout: 3 * time.Second,
+	CommandTimeout: 8 * time.Second,
+	MaxRetries:     2,
+	RetryDelay:     1 * time.Second,
+	MaxRetryTime:   10 * time.Second,
+	IPPreference:   PreferAny,
+	Ports:          []string{"25", "587", "465"},
+}
+
 var (
 	throttleManager *throttle.ThrottleManager
+
+	// Active SMTP network settings
+	options   = DefaultOptions
+	optionsMu sync.RWMutex
 )
 
 func SetThrottleManager(tm *throttle.ThrottleManager) {
 	throttleManager = tm
 }
 
+// SetOptions replaces the SMTP network settings; zero values keep the defaults
+func SetOptions(opts Options) {
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = DefaultOptions.ConnectTimeout
+	}
+	if opts.CommandTimeout <= 0 {
+		opts.CommandTimeout = DefaultOptions.CommandTimeout
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = DefaultOptions.MaxRetries
+	}
+	if opts.RetryDelay < 0 {
+		opts.RetryDelay = DefaultOptions.RetryDelay
+	}
+	if opts.MaxRetryTime < 0 {
+		opts.MaxRetryTime = DefaultOptions.MaxRetryTime
+	}
+	if opts.IPPreference == "" {
+		opts.IPPreference = DefaultOptions.IPPreference
+	}
+	if len(opts.Ports) == 0 {
+		opts.Ports = DefaultOptions.Ports
+	}
+
+	optionsMu.Lock()
+	options = opts
+	optionsMu.Unlock()
+}
+
+// GetOptions returns the active SMTP network settings
+func GetOptions() Options {
+	return currentOptions()
+}
+
+// currentOptions returns a snapshot of the active SMTP network settings
+func currentOptions() Options {
+	optionsMu.RLock()
+	defer optionsMu.RUnlock()
+	return options
+}
+
+// target is a single SMTP endpoint to probe
+type target struct {
+	host string
+	port string
+}
+
 // CheckEmailExists validates an email address by interacting with its domain's SMTP servers
 func CheckEmailExists(email string, mxRecords []*net.MX) (bool, string, string, bool, int) {
-	ports := []string{"25", "587", "465"} // Common SMTP ports (unsecured and secured)
-	var (
-		maxTTL        int    // Maximum TTL value from temporary SMTP errors
-		finalErr      string // Last error encountered during SMTP interactions
-		finalCategory string // Classification of the last error
-		hasPermanent  bool   // Flag indicating permanent SMTP error
-		permanentErr  string // Error message for permanent SMTP failure
-		permanentCat  string // Category of the permanent SMTP failure
-		tempErrors    int    // Category for temporary errors
-	)
-
-	domain := strings.Split(email, "@")[1]
+	return CheckEmailExistsContext(context.Background(), email, mxRecords)
+}
+
+// CheckEmailExistsContext is CheckEmailExists with log lines tagged by the request ID in ctx
+// Sessions end when ctx is done; a check cut short without a verdict is reported with the NotChecked category.
+// Domains with a registered Verifier are checked by it; SMTP is the default and the fallback
+func CheckEmailExistsContext(ctx context.Context, email string, mxRecords []*net.MX) (bool, string, string, bool, int) {
+	if res, ok := Verify(ctx, email, mxRecords); ok {
+		return res.Exists, res.Err, res.Category, res.Permanent, res.TTL
+	}
+	return checkMailHosts(ctx, email, mxRecords)
+}
+
+// checkMailHosts probes every port of the mail hosts over SMTP
+func checkMailHosts(ctx context.Context, email string, mxRecords []*net.MX) (bool, string, string, bool, int) {
+	var targets []target
+	for _, mx := range mxRecords {
+		mxHost := strings.TrimSuffix(mx.Host, ".")
+		for _, port := range currentOptions().Ports {
+			targets = append(targets, target{host: mxHost, port: port})
+		}
+	}
+	return checkTargets(ctx, email, targets)
+}
+
+// CheckEmailAtHost validates an email address against one specific SMTP host and port,
+// bypassing the domain's published MX records. Domain throttling still applies
+func CheckEmailAtHost(email, host, port string) (bool, string, string, bool, int) {
+	return CheckEmailAtHostContext(context.Background(), email, host, port)
+}
+
+// CheckEmailAtHostContext is CheckEmailAtHost with log lines tagged by the request ID in ctx
+func CheckEmailAtHostContext(ctx context.Context, email, host, port string) (bool, string, string, bool, int) {
+	return checkTargets(ctx, email, []target{{host: host, port: port}})
+}
+
+// checkResult is the verdict of an SMTP check
+type checkResult struct {
+	exists    bool
+	smtpErr   string
+	category  string
+	permanent bool
+	ttl       int
+}
+
+// checkTargets probes the SMTP endpoints in order until the address is verified or rejected
+// With ParallelHosts above 1 several MX hosts are probed at once and the first definitive answer wins
+func checkTargets(ctx context.Context, email string, targets []target) (exists bool, smtpErr string, category string, permanent bool, ttl int) {
+	domain := email[strings.LastIndex(email, "@")+1:] // Quoted local parts may contain '@'
+	startTime := time.Now()
+	defer func() {
+		result := latencyResult(exists, category, permanent)
+		metrics.SMTPLatency.WithLabelValues(result).Observe(time.Since(startTime).Seconds())
+		metrics.SMTPDomainResults.WithLabelValues(metrics.DomainLabel(domain), result).Inc()
+	}()
 
 	// Checks for domain throttling
 	if throttleManager != nil && throttleManager.IsThrottled(domain) {
-		logger.Log(fmt.Sprintf("[Throttle] Domain %s is throttled, skipping checks", domain))
+		logger.LogContext(ctx, fmt.Sprintf("[Throttle] Domain %s is throttled, skipping checks", domain))
 		return false, "domain throttled", "throttled", false, 0
 	}
 
-	// Iterate over all MX records and SMTP ports for validation
-	for _, mx := range mxRecords {
-		mxHost := strings.TrimSuffix(mx.Host, ".")
-		for _, port := range ports {
-			logger.Log(fmt.Sprintf("Trying %s:%s for %s", mxHost, port, email)) // Log attempt details
-
-			// Attempt validation with retry logic
-			exists, err, retry := attemptWithRetry(email, mxHost, port)
-			if retry {
-				logger.Log(fmt.Sprintf("Retrying %s:%s", mxHost, port)) // Log retry attempt
-				time.Sleep(retryDelay)                                  // Pause before retrying
-				exists, err, _ = attemptWithRetry(email, mxHost, port)
-			}
+	agg := &probeAggregate{email: email, domain: domain}
+	var res checkResult
+	if hosts := currentOptions().ParallelHosts; hosts > 1 {
+		res = checkTargetsParallel(ctx, email, targets, agg, hosts)
+	} else {
+		res = checkTargetsSequential(ctx, email, targets, agg)
+	}
+	return res.exists, res.smtpErr, res.category, res.permanent, res.ttl
+}
 
-			if exists { // Email address verified successfully
-				return true, "", "", false, 0
-			}
+// checkTargetsSequential probes the endpoints strictly in priority order
+func checkTargetsSequential(ctx context.Context, email string, targets []target, agg *probeAggregate) checkResult {
+	for _, t := range targets {
+		res := probe(ctx, email, t)
+		if ctx.Err() != nil && !res.exists {
+			return notChecked(ctx) // The failure may stem from the cancellation itself
+		}
+		if final, done := agg.add(ctx, res); done {
+			return final
+		}
+	}
+	return agg.result(ctx, len(targets))
+}
 
-			// Process errors returned during validation
-			if err != "" {
-				category, permanent, ttl := classifySMTPError(err)                      // Classify SMTP error
-				logger.Log(fmt.Sprintf("SMTP error: %s (category: %s)", err, category)) // Log error details
-
-				// Специальная обработка RBL ошибки
-				if category == "rbl_restriction" {
-					if throttleManager != nil {
-						// Блокируем домен на 1 минуту
-						throttleManager.ThrottleDomainWithTTL(domain, 1*time.Minute)
-						logger.Log(fmt.Sprintf("[RBL] Domain %s throttled for 1 minute", domain))
-						metrics.RBLRestrictions.Inc()
-					}
-					// Немедленно прерываем проверку
-					return false, "rbl restriction", category, false, 60
-				}
+// notChecked is the verdict of a check whose context was done before any endpoint answered definitively
+// Nothing is classified, so neither the domain is throttled nor the outcome cached as a failure
+func notChecked(ctx context.Context) checkResult {
+	return checkResult{smtpErr: ctx.Err().Error(), category: NotChecked}
+}
 
-				// Counting temp errors
-				if !permanent {
-					tempErrors++
-					metrics.TemporaryErrors.WithLabelValues(domain).Inc()
+// checkTargetsParallel probes up to hosts MX hosts at once, the ports of each host in order
+// The first definitive answer cancels the remaining probes; otherwise all outcomes are aggregated
+func checkTargetsParallel(ctx context.Context, email string, targets []target, agg *probeAggregate, hosts int) checkResult {
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan probeResult)
+	go func() {
+		defer close(results)
+		sem := make(chan struct{}, hosts)
+		var wg sync.WaitGroup
+	dispatch:
+		for _, group := range groupByHost(targets) {
+			select {
+			case sem <- struct{}{}:
+			case <-raceCtx.Done():
+				break dispatch
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				for _, t := range group {
+					if raceCtx.Err() != nil {
+						return
+					}
+					res := probe(raceCtx, email, t)
+					select {
+					case results <- res:
+					case <-raceCtx.Done():
+						return
+					}
 				}
+			}()
+		}
+		wg.Wait()
+	}()
 
-				// If permanent error, halt further processing
-				if permanent {
-					hasPermanent = true
-					permanentErr = err
-					permanentCat = category
-					break
-				}
+	for res := range results {
+		if ctx.Err() != nil && !res.exists {
+			return notChecked(ctx)
+		}
+		if final, done := agg.add(ctx, res); done {
+			return final // The deferred cancel closes the sessions still running
+		}
+	}
+	if ctx.Err() != nil {
+		return notChecked(ctx) // Dispatch stopped before every endpoint was probed
+	}
+	return agg.result(ctx, len(targets))
+}
 
-				// Track temporary errors with higher TTL
-				if ttl > maxTTL {
-					maxTTL = ttl
-					finalErr = err
-					finalCategory = category
-				}
-			}
+// groupByHost splits targets into per-host lists, keeping host priority and port order
+func groupByHost(targets []target) [][]target {
+	index := make(map[string]int)
+	var groups [][]target
+	for _, t := range targets {
+		i, ok := index[t.host]
+		if !ok {
+			i = len(groups)
+			index[t.host] = i
+			groups = append(groups, nil)
 		}
+		groups[i] = append(groups[i], t)
+	}
+	return groups
+}
+
+// probeResult is the outcome of one SMTP endpoint
+type probeResult struct {
+	exists bool
+	err    string
+}
+
+// probe checks the address at one SMTP endpoint; retries happen in attemptWithRetry only
+func probe(ctx context.Context, email string, t target) probeResult {
+	mxHost, port := t.host, t.port
+	logger.LogContext(ctx, fmt.Sprintf("Trying %s:%s for %s", mxHost, port, email)) // Log attempt details
+
+	// Attempt validation with retry logic
+	attemptCtx, span := tracing.Start(ctx, "smtp.attempt", attribute.String("smtp.host", mxHost), attribute.String("smtp.port", port))
+	defer span.End()
+	exists, err, retried := attemptWithRetry(attemptCtx, email, mxHost, port)
+	span.SetAttributes(attribute.Bool("smtp.exists", exists), attribute.Bool("smtp.retried", retried))
+	if err != "" {
+		tracing.Fail(span, err)
+	}
+	return probeResult{exists: exists, err: err}
+}
+
+// probeAggregate accumulates endpoint outcomes until one of them is definitive
+type probeAggregate struct {
+	email  string
+	domain string
+
+	maxTTL        int    // Maximum TTL value from temporary SMTP errors
+	finalErr      string // Error with the highest TTL among temporary errors
+	finalCategory string // Classification of finalErr
+	tempErrors    int    // Number of endpoints that failed temporarily
+	tlsErr        string // Last TLS handshake failure, reported only if nothing else answered
+}
+
+// add records one endpoint outcome and returns the verdict once it is definitive:
+// a verified address, a permanent rejection, an RBL block or a server refusing verification
+func (a *probeAggregate) add(ctx context.Context, res probeResult) (checkResult, bool) {
+	if res.exists { // Email address verified successfully
+		return checkResult{exists: true}, true
+	}
+	if res.err == "" {
+		return checkResult{}, false
+	}
+
+	category, permanent, ttl := classifySMTPError(res.err)                                  // Classify SMTP error
+	logger.LogContext(ctx, fmt.Sprintf("SMTP error: %s (category: %s)", res.err, category)) // Log error details
 
-		if hasPermanent { // Break loop if permanent error detected
-			break
+	// Специальная обработка RBL ошибки
+	if category == "rbl_restriction" {
+		if throttleManager != nil {
+			// Блокируем домен на 1 минуту
+			throttleManager.ThrottleDomainWithTTL(a.domain, 1*time.Minute)
+			logger.LogContext(ctx, fmt.Sprintf("[RBL] Domain %s throttled for 1 minute", a.domain))
+			metrics.RBLRestrictions.Inc()
 		}
+		// Немедленно прерываем проверку
+		return checkResult{smtpErr: "rbl restriction", category: category, ttl: 60}, true
+	}
+
+	// The server answered but will not verify mailboxes; other hosts behave the same
+	if category == "unverifiable" {
+		return checkResult{smtpErr: res.err, category: category}, true
+	}
+
+	// A failed handshake is not a server verdict: neither count it nor throttle the domain for it
+	if category == "tls_error" {
+		a.tlsErr = res.err
+		return checkResult{}, false
+	}
+
+	// If permanent error, halt further processing
+	if permanent {
+		return checkResult{smtpErr: res.err, category: category, permanent: true}, true
 	}
 
+	// Counting temp errors
+	a.tempErrors++
+	metrics.TemporaryErrors.WithLabelValues(metrics.DomainLabel(a.domain)).Inc()
+
+	// Track temporary errors with higher TTL
+	if ttl > a.maxTTL {
+		a.maxTTL = ttl
+		a.finalErr = res.err
+		a.finalCategory = category
+	}
+	return checkResult{}, false
+}
+
+// result returns the verdict after all endpoints were probed without a definitive answer
+// The domain is throttled when every one of the total endpoints failed temporarily
+func (a *probeAggregate) result(ctx context.Context, total int) checkResult {
 	// Handling temp errors over all MX
-	if tempErrors > 0 && tempErrors == len(mxRecords)*len(ports) {
+	if a.tempErrors > 0 && a.tempErrors == total {
 		if throttleManager != nil {
 			metrics.ThrottledDomains.Inc()
-			logger.Log(fmt.Sprintf("[Throttle] All MX failed for %s, throttling", domain))
-			throttleManager.ThrottleDomain(domain)
-			throttleManager.ScheduleRetry(email, 1)
+			logger.LogContext(ctx, fmt.Sprintf("[Throttle] All MX failed for %s, throttling", a.domain))
+			throttleManager.ThrottleDomain(a.domain)
+			throttleManager.ScheduleRetry(a.email, 1)
 		}
-		return false, "all MX temporary errors", "temporary", false, maxTTL
+		return checkResult{smtpErr: "all MX temporary errors", category: "temporary", ttl: a.maxTTL}
 	}
 
 	// Return results based on the encountered errors
-	if hasPermanent {
-		return false, permanentErr, permanentCat, true, 0
+	if a.finalErr != "" {
+		return checkResult{smtpErr: a.finalErr, category: a.finalCategory, ttl: a.maxTTL}
+	}
+	if a.tlsErr != "" {
+		return checkResult{smtpErr: a.tlsErr, category: "tls_error"}
+	}
+	return checkResult{} // Default case when no valid results are obtained
+}
+
+// PlannedProbes lists the host:port pairs CheckEmailExists would try, in order
+func PlannedProbes(mxRecords []*net.MX) []string {
+	var probes []string
+	for _, mx := range mxRecords {
+		mxHost := strings.TrimSuffix(mx.Host, ".")
+		for _, port := range currentOptions().Ports {
+			probes = append(probes, net.JoinHostPort(mxHost, port))
+		}
+	}
+	return probes
+}
+
+// RetryAfter returns the remaining throttle time for a domain in whole seconds (0 if not throttled)
+func RetryAfter(domain string) int {
+	if throttleManager == nil {
+		return 0
 	}
-	if finalErr != "" {
-		return false, finalErr, finalCategory, false, maxTTL
+	remaining := throttleManager.Remaining(domain)
+	return int((remaining + time.Second - 1) / time.Second) // Round up to full seconds
+}
+
+// ThrottledUntil returns when the domain throttle expires (zero time if not throttled)
+func ThrottledUntil(domain string) time.Time {
+	if throttleManager == nil {
+		return time.Time{}
+	}
+	return throttleManager.ThrottledUntil(domain)
+}
+
+// RetryScheduled reports whether a background re-check of the email is pending
+func RetryScheduled(email string) bool {
+	return throttleManager != nil && throttleManager.RetryScheduled(email)
+}
+
+// latencyResult maps a check outcome onto a low-cardinality metric label
+func latencyResult(exists bool, category string, permanent bool) string {
+	switch {
+	case exists:
+		return "exists"
+	case category == "throttled" || category == "rbl_restriction":
+		return "throttled"
+	case permanent:
+		return "permanent"
+	case category != "":
+		return "temporary"
+	default:
+		return "unknown"
 	}
-	return false, "", "", false, 0 // Default case when no valid results are obtained
 }
 
 // classifySMTPError categorizes SMTP errors as permanent or temporary
@@ -143,6 +446,21 @@ func classifySMTPError(errMsg string) (string, bool, int) {
 		return "rbl_restriction", false, 60 // Temporary error TTL 60 sec
 	}
 
+	// TLS handshake failures say nothing about the mailbox; other ports and hosts are still tried
+	if strings.HasPrefix(errMsg, tlsErrorPrefix) {
+		return "tls_error", false, 0
+	}
+
+	// Recipient accepted without verification (252 or an accept-and-bounce reply)
+	if strings.HasPrefix(errMsg, "2") {
+		return "unverifiable", false, 0
+	}
+
+	// Postfix reject_unverified_recipient answers 450 while its own probe is still running
+	if code == "450" && strings.Contains(strings.ToLower(errMsg), "unverified address") {
+		return "verification_pending", false, 300 // The probe usually finishes within minutes
+	}
+
 	switch {
 	case strings.HasPrefix(code, "5"): // Permanent errors start with '5'
 		return handlePermanentErrors(code)
@@ -212,71 +530,194 @@ func calculateTTL(code string) int {
 	}
 }
 
-// attemptWithRetry executes email validation attempts with a retry mechanism
-func attemptWithRetry(email, host, port string) (bool, string, bool) {
-	for i := 0; i < maxRetries; i++ {
-		exists, err, retry := attempt(email, host, port) // Perform validation attempt
-		if !retry {
-			return exists, err, false // Stop retries if retry flag is false
+// attemptWithRetry makes up to MaxRetries attempts against one endpoint, pausing with a jittered
+// exponential backoff after retryable failures. No retry starts once MaxRetryTime has passed since
+// the first attempt, or when ctx (e.g. the task deadline) is done or would be before the pause ends.
+// The last error is returned so it is classified like any other; retried reports whether more than
+// one attempt was made
+func attemptWithRetry(ctx context.Context, email, host, port string) (exists bool, errMsg string, retried bool) {
+	opts := currentOptions()
+	domain := email[strings.LastIndex(email, "@")+1:]
+	start := time.Now()
+	for n := 1; ; n++ {
+		exists, errMsg, retry := attempt(ctx, email, host, port)
+		if !retry || n >= opts.MaxRetries {
+			return exists, errMsg, n > 1
 		}
-		time.Sleep(retryDelay) // Pause before retrying
+		delay := retryBackoff(opts.RetryDelay, n)
+		if opts.MaxRetryTime > 0 && time.Since(start)+delay > opts.MaxRetryTime {
+			logger.LogContext(ctx, fmt.Sprintf("[Retry] Retry budget for %s:%s spent after %d attempts", host, port, n))
+			return exists, errMsg, n > 1
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return exists, errMsg, n > 1 // The retry could not start before the deadline
+		}
+		metrics.ConnectionRetries.WithLabelValues(metrics.DomainLabel(domain), strconv.Itoa(n)).Inc()
+		logger.LogContext(ctx, fmt.Sprintf("Retrying %s:%s in %v", host, port, delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done(): // Task deadline passed or a parallel probe already answered
+			timer.Stop()
+			return exists, errMsg, n > 1
+		case <-timer.C:
+		}
+	}
+}
+
+// retryBackoff returns the pause before retry n (1-based): base doubled for every earlier retry,
+// capped at maxRetryBackoff, with the upper half randomized so workers do not retry in lockstep
+func retryBackoff(base time.Duration, n int) time.Duration {
+	delay := base
+	for i := 1; i < n && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxRetryBackoff)
+	if delay <= 0 {
+		return 0
 	}
-	return false, "max retries exceeded", false // Default result after max retries
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
 }
 
+// tlsErrorPrefix marks failed TLS handshakes; they are classified as tls_error so the next
+// port or host is still tried instead of treating the handshake failure as a verdict
+const tlsErrorPrefix = "tls error: "
+
 // attempt performs a single email validation attempt against the SMTP server
-func attempt(email, host, port string) (bool, string, bool) {
-	heloDomain, err := domains.GetNext()
+// If STARTTLS fails and TLSFallback is enabled, the check is repeated on a new plaintext connection
+func attempt(ctx context.Context, email, host, port string) (bool, string, bool) {
+	exists, errMsg, retry := session(ctx, email, host, port, true)
+	if port != "465" && strings.HasPrefix(errMsg, tlsErrorPrefix) && currentOptions().TLSFallback {
+		logger.LogContext(ctx, fmt.Sprintf("[TLS] STARTTLS with %s:%s failed (%s), falling back to plaintext", host, port, errMsg))
+		return session(ctx, email, host, port, false)
+	}
+	return exists, errMsg, retry
+}
+
+// session runs one SMTP conversation up to RCPT TO, upgrading port 587 with STARTTLS when startTLS is set
+func session(ctx context.Context, email, host, port string, startTLS bool) (bool, string, bool) {
+	domain := email[strings.LastIndex(email, "@")+1:]
+	heloDomain, err := domains.GetNextFor(domain) // Strict providers may have a fixed HELO mapped
 	if err != nil {
+		logger.LogContext(ctx, fmt.Sprintf("[ERROR] HELO domain selection failed for %s: %v", email, err))
 		return false, fmt.Sprintf("failed to get HELO domain: %v", err), false
 	}
 
-	conn, err := connect(host, port)
+	opts := currentOptions()
+	conn, err := connect(ctx, host, port, opts.ConnectTimeout)
+	if port == "25" {
+		recordPort25Result(domain, err) // Feed the blocked-port heuristic
+	}
 	if err != nil {
 		return false, err.Error(), shouldRetry(err)
 	}
 	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() }) // Lost parallel races and passed deadlines end at once
+	defer stop()
+
+	// Refresh the deadline before every command so an unresponsive server cannot hang the worker;
+	// commands never wait past the deadline of ctx
+	refreshDeadline := func() error {
+		deadline := time.Now().Add(opts.CommandTimeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		return conn.SetDeadline(deadline)
+	}
 
+	if err := refreshDeadline(); err != nil { // Covers the server greeting
+		return false, err.Error(), false
+	}
 	client, err := smtp.NewClient(conn, host)
 	if err != nil {
 		return false, err.Error(), shouldRetry(err)
 	}
 	defer client.Close()
 
-	if port == "587" {
+	// HELO must come first: Extension would otherwise greet as "localhost" and make Hello fail
+	if err := refreshDeadline(); err != nil {
+		return false, err.Error(), false
+	}
+	if err := client.Hello(heloDomain); err != nil {
+		if !shouldRetry(err) && ctx.Err() == nil { // A session closed by ctx says nothing about the HELO domain
+			domains.CoolDown(heloDomain, heloCooldown) // Rest the rejected HELO domain
+			logger.LogContext(ctx, fmt.Sprintf("[HELO] Domain %s rejected by %s, cooling down for %v", heloDomain, host, heloCooldown))
+		}
+		return false, err.Error(), shouldRetry(err)
+	}
+
+	if port == "587" && startTLS {
+		if err := refreshDeadline(); err != nil {
+			return false, err.Error(), false
+		}
 		if ok, _ := client.Extension("STARTTLS"); ok {
-			if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
-				return false, err.Error(), shouldRetry(err)
+			if err := refreshDeadline(); err != nil {
+				return false, err.Error(), false
+			}
+			if err := client.StartTLS(tlsConfig(host)); err != nil {
+				return false, tlsErrorPrefix + err.Error(), false // The connection is unusable after a failed handshake
 			}
 		}
 	}
 
-	if err := client.Hello(heloDomain); err != nil {
-		return false, err.Error(), shouldRetry(err)
+	if err := refreshDeadline(); err != nil {
+		return false, err.Error(), false
 	}
-
-	if err := client.Mail("test@" + heloDomain); err != nil {
+	if err := client.Mail(domains.SenderFor(domain, heloDomain)); err != nil {
 		return false, err.Error(), shouldRetry(err)
 	}
 
-	if err := client.Rcpt(email); err != nil {
+	if err := refreshDeadline(); err != nil {
+		return false, err.Error(), false
+	}
+	code, msg, err := rcpt(client, email)
+	if err != nil {
 		return false, err.Error(), shouldRetry(err)
 	}
+	if code == 252 || deferredVerification(msg) {
+		// Accepted without verifying the mailbox: bounces may follow, so this is not a definite exists
+		return false, fmt.Sprintf("%d %s", code, msg), false
+	}
 
 	return true, "", false
 }
 
-// connect establishes an SMTP connection using secure or non-secure protocols
-func connect(host, port string) (net.Conn, error) {
-	if port == "465" { // Establish secure connection using TLS
-		return tls.DialWithDialer(
-			&net.Dialer{Timeout: connectTimeout}, // Apply connection timeout
-			"tcp",
-			net.JoinHostPort(host, port),  // Combine host and port for connection
-			&tls.Config{ServerName: host}, // Configure server name for TLS
-		)
+// rcpt sends RCPT TO like smtp.Client.Rcpt but also returns the accepting reply,
+// so 252 and accept-and-bounce replies can be told apart from a verified 250
+func rcpt(client *smtp.Client, email string) (int, string, error) {
+	if strings.ContainsAny(email, "\r\n") {
+		return 0, "", fmt.Errorf("smtp: A line must not contain CR or LF")
+	}
+	id, err := client.Text.Cmd("RCPT TO:<%s>", email)
+	if err != nil {
+		return 0, "", err
+	}
+	client.Text.StartResponse(id)
+	defer client.Text.EndResponse(id)
+	return client.Text.ReadResponse(25)
+}
+
+// deferredVerificationPhrases appear in positive RCPT replies of servers that accept any
+// recipient and verify (or bounce) later, e.g. "250 2.1.5 Ok, recipient not verified"
+var deferredVerificationPhrases = []string{
+	"cannot vrfy",
+	"cannot verify",
+	"not verified",
+	"unverified",
+	"verification deferred",
+	"will attempt delivery",
+}
+
+// deferredVerification reports whether a positive RCPT reply admits the mailbox was not verified
+func deferredVerification(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, phrase := range deferredVerificationPhrases {
+		if strings.Contains(msg, phrase) {
+			return true
+		}
 	}
-	return net.DialTimeout("tcp", net.JoinHostPort(host, port), connectTimeout) // Non-secure connection
+	return false
 }
 
 // shouldRetry determines if an error warrants retrying the operation
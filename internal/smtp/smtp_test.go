@@ -0,0 +1,304 @@
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/shuliakovsky/email-checker/internal/domains"
+	"github.com/shuliakovsky/email-checker/internal/metrics"
+)
+
+// mockSMTP is a scripted SMTP server answering every recipient with rcpt
+type mockSMTP struct {
+	startTLS bool          // Advertise STARTTLS and then break the handshake
+	rcpt     string        // Reply to RCPT TO, "250 OK" when empty
+	stall    string        // Command that is never answered, e.g. "EHLO"
+	delay    time.Duration // Pause before every reply
+}
+
+// start serves the script on a local port until the test ends and returns its address
+func (m mockSMTP) start(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// serve runs one SMTP session
+func (m mockSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		time.Sleep(m.delay)
+		fmt.Fprint(conn, strings.Join(lines, "\r\n")+"\r\n")
+	}
+
+	reply("220 mock.test ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.Fields(line + " x")[0])
+		if m.stall != "" && cmd == m.stall {
+			time.Sleep(time.Minute) // Ended by the client closing the connection or the test ending
+			return
+		}
+		switch cmd {
+		case "EHLO", "HELO":
+			if m.startTLS {
+				reply("250-mock.test", "250 STARTTLS")
+			} else {
+				reply("250 mock.test")
+			}
+		case "STARTTLS":
+			reply("220 Ready to start TLS")
+			reply("this is not a TLS handshake") // The client handshake fails on this
+			return
+		case "MAIL":
+			reply("250 OK")
+		case "RCPT":
+			if m.rcpt == "" {
+				reply("250 OK")
+			} else {
+				reply(m.rcpt)
+			}
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// useMockServers routes connections to each port to the given mock server address
+// and applies opts until the test ends
+func useMockServers(t *testing.T, servers map[string]string, opts Options) {
+	t.Helper()
+	if err := domains.Init(false, nil, []string{"helo.example.com"}, "", false); err != nil {
+		t.Fatal(err)
+	}
+	dial = func(ctx context.Context, host, port string, timeout time.Duration, pref IPPreference) (net.Conn, error) {
+		addr, ok := servers[port]
+		if !ok {
+			return nil, fmt.Errorf("dial %s:%s: connection refused", host, port)
+		}
+		return (&net.Dialer{Timeout: timeout}).DialContext(ctx, "tcp", addr)
+	}
+	SetOptions(opts)
+	t.Cleanup(func() {
+		dial = dialTCP
+		SetOptions(DefaultOptions)
+	})
+}
+
+func TestFailedSTARTTLSMovesToNextPort(t *testing.T) {
+	useMockServers(t, map[string]string{
+		"587": mockSMTP{startTLS: true}.start(t),
+		"25":  mockSMTP{}.start(t),
+	}, Options{Ports: []string{"587", "25"}, MaxRetries: 1})
+
+	exists, smtpErr, category, permanent, _ := CheckEmailExists("user@example.com", []*net.MX{{Host: "mx.example.com."}})
+	if !exists || smtpErr != "" || permanent {
+		t.Fatalf("result = %v, %q (%s), permanent %v; want verified on port 25", exists, smtpErr, category, permanent)
+	}
+}
+
+func TestFailedSTARTTLSIsNotAVerdict(t *testing.T) {
+	useMockServers(t, map[string]string{
+		"587": mockSMTP{startTLS: true}.start(t),
+	}, Options{Ports: []string{"587"}, MaxRetries: 1})
+
+	exists, smtpErr, category, permanent, _ := CheckEmailExists("user@example.com", []*net.MX{{Host: "mx.example.com."}})
+	if exists || permanent || category != "tls_error" || !strings.HasPrefix(smtpErr, tlsErrorPrefix) {
+		t.Fatalf("result = %v, %q (%s), permanent %v; want a non-permanent tls_error", exists, smtpErr, category, permanent)
+	}
+}
+
+func TestFailedSTARTTLSFallsBackToPlaintext(t *testing.T) {
+	useMockServers(t, map[string]string{
+		"587": mockSMTP{startTLS: true}.start(t),
+	}, Options{Ports: []string{"587"}, MaxRetries: 1, TLSFallback: true})
+
+	exists, smtpErr, category, _, _ := CheckEmailExists("user@example.com", []*net.MX{{Host: "mx.example.com."}})
+	if !exists {
+		t.Fatalf("result = %v, %q (%s); want verified over plaintext", exists, smtpErr, category)
+	}
+}
+
+func TestStalledServerTimesOutPerCommand(t *testing.T) {
+	for _, stall := range []string{"EHLO", "MAIL", "RCPT"} {
+		t.Run(stall, func(t *testing.T) {
+			useMockServers(t, map[string]string{
+				"25": mockSMTP{stall: stall}.start(t),
+			}, Options{Ports: []string{"25"}, MaxRetries: 1, CommandTimeout: 200 * time.Millisecond})
+
+			start := time.Now()
+			exists, smtpErr, _, _, _ := CheckEmailExists("user@example.com", []*net.MX{{Host: "mx.example.com."}})
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Fatalf("check took %v, want it to give up after the 200ms command timeout", elapsed)
+			}
+			if exists || !strings.Contains(smtpErr, "timeout") {
+				t.Fatalf("result = %v, %q; want a timeout", exists, smtpErr)
+			}
+		})
+	}
+}
+
+func TestDeadlineEndsSession(t *testing.T) {
+	for _, stall := range []string{"EHLO", "RCPT"} {
+		t.Run(stall, func(t *testing.T) {
+			useMockServers(t, map[string]string{
+				"25": mockSMTP{stall: stall}.start(t),
+			}, Options{Ports: []string{"25"}, MaxRetries: 3, CommandTimeout: 10 * time.Second})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			exists, _, category, permanent, _ := CheckEmailExistsContext(ctx, "user@example.com", []*net.MX{{Host: "mx.example.com."}})
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Fatalf("check took %v, want it to end at the 200ms deadline", elapsed)
+			}
+			if exists || category != NotChecked || permanent {
+				t.Fatalf("result = %v, %q, permanent %v; want not checked", exists, category, permanent)
+			}
+		})
+	}
+}
+
+func TestDeadlineSkipsRetryBackoff(t *testing.T) {
+	useMockServers(t, map[string]string{}, Options{Ports: []string{"25"}, MaxRetries: 3, RetryDelay: 10 * time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	CheckEmailExistsContext(ctx, "user@example.com", []*net.MX{{Host: "mx.example.com."}})
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Fatalf("check took %v, want it to return instead of pausing until the deadline", elapsed)
+	}
+}
+
+func TestCommandTimeoutRefreshesForEveryCommand(t *testing.T) {
+	// Every reply is slow but within the timeout; together they exceed it
+	useMockServers(t, map[string]string{
+		"25": mockSMTP{delay: 100 * time.Millisecond}.start(t),
+	}, Options{Ports: []string{"25"}, MaxRetries: 1, CommandTimeout: 300 * time.Millisecond})
+
+	exists, smtpErr, _, _, _ := CheckEmailExists("user@example.com", []*net.MX{{Host: "mx.example.com."}})
+	if !exists {
+		t.Fatalf("result = %v, %q; want verified", exists, smtpErr)
+	}
+}
+
+// rcptReplies are sample RCPT TO replies of real servers and the verdict they lead to
+// when the only MX host gives them (temporary errors of every host are reported as "temporary")
+var rcptReplies = []struct {
+	reply     string
+	exists    bool
+	category  string
+	permanent bool
+}{
+	{"250 2.1.5 Ok", true, "", false},
+	{"250 2.1.5 Recipient OK", true, "", false},
+	{"252 2.1.5 Cannot VRFY user, but will accept message and attempt delivery", false, "unverifiable", false},
+	{"252 2.0.0 Recipient address accepted", false, "unverifiable", false},
+	{"250 2.1.5 Ok, recipient not verified", false, "unverifiable", false},
+	{"250 2.1.5 <user@example.com>... Recipient ok (will attempt delivery)", false, "unverifiable", false},
+	{"250 2.1.5 Verification deferred", false, "unverifiable", false},
+	{"450 4.1.1 <user@example.com>: Recipient address rejected: unverified address: Address verification in progress", false, "temporary", false},
+	{"550 5.1.1 <user@example.com>: Recipient address rejected: User unknown", false, "mailbox_not_found", true},
+	{"451 4.3.0 Temporary lookup failure", false, "temporary", false},
+}
+
+func TestAcceptWithoutVerificationIsUnverifiable(t *testing.T) {
+	for _, tt := range rcptReplies {
+		t.Run(tt.reply, func(t *testing.T) {
+			useMockServers(t, map[string]string{
+				"25": mockSMTP{rcpt: tt.reply}.start(t),
+			}, Options{Ports: []string{"25"}, MaxRetries: 1})
+
+			exists, smtpErr, category, permanent, _ := CheckEmailExists("user@example.com", []*net.MX{{Host: "mx.example.com."}})
+			if exists != tt.exists || category != tt.category || permanent != tt.permanent {
+				t.Fatalf("result = %v, %q (%s), permanent %v; want %v (%s), permanent %v",
+					exists, smtpErr, category, permanent, tt.exists, tt.category, tt.permanent)
+			}
+		})
+	}
+}
+
+func TestUnverifiableStopsProbingOtherHosts(t *testing.T) {
+	useMockServers(t, map[string]string{
+		"25":  mockSMTP{rcpt: "252 2.1.5 Cannot VRFY user"}.start(t),
+		"587": mockSMTP{}.start(t), // Would verify the address if it were asked
+	}, Options{Ports: []string{"25", "587"}, MaxRetries: 1})
+
+	exists, _, category, _, _ := CheckEmailExists("user@example.com", []*net.MX{{Host: "mx.example.com."}})
+	if exists || category != "unverifiable" {
+		t.Fatalf("result = %v (%s), want unverifiable from the first server", exists, category)
+	}
+}
+
+func TestClassifyRcptReplies(t *testing.T) {
+	for reply, want := range map[string]string{
+		"252 2.1.5 Cannot VRFY user, but will accept message and attempt delivery":                                       "unverifiable",
+		"250 2.1.5 Ok, recipient not verified":                                                                           "unverifiable",
+		"450 4.1.1 <user@example.com>: Recipient address rejected: unverified address: Address verification in progress": "verification_pending",
+		"450 4.2.0 Mailbox busy":             "server_unavailable",
+		"451 4.3.0 Temporary lookup failure": "server_error",
+		"550 5.1.1 User unknown":             "mailbox_not_found",
+		"552 5.2.2 Mailbox full":             "mailbox_full",
+		"554 5.7.1 Service unavailable":      "transaction_failed",
+	} {
+		if got, _, _ := classifySMTPError(reply); got != want {
+			t.Errorf("classifySMTPError(%q) = %s, want %s", reply, got, want)
+		}
+	}
+}
+
+// counterValue reads one series of a counter vector
+func counterValue(t *testing.T, vec *prometheus.CounterVec, labels ...string) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := vec.WithLabelValues(labels...).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestConnectionRetriesAreCountedSeparately(t *testing.T) {
+	useMockServers(t, map[string]string{}, Options{Ports: []string{"25"}, MaxRetries: 3, RetryDelay: time.Millisecond})
+	domain := metrics.DomainLabel("retry.example.com")
+	before := counterValue(t, metrics.ConnectionRetries, domain, "1") + counterValue(t, metrics.ConnectionRetries, domain, "2")
+	scheduled := counterValue(t, metrics.RetryAttempts, domain, "1")
+
+	CheckEmailExists("user@retry.example.com", []*net.MX{{Host: "mx.retry.example.com."}})
+
+	after := counterValue(t, metrics.ConnectionRetries, domain, "1") + counterValue(t, metrics.ConnectionRetries, domain, "2")
+	if after-before != 2 {
+		t.Fatalf("connection retries grew by %v, want 2 for 3 attempts", after-before)
+	}
+	if got := counterValue(t, metrics.RetryAttempts, domain, "1"); got != scheduled {
+		t.Fatalf("scheduled re-checks grew by %v without a throttle manager", got-scheduled)
+	}
+}
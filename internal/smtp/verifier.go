@@ -0,0 +1,132 @@
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/shuliakovsky/email-checker/internal/logger"
+)
+
+// Verifier checks a mailbox without, or before, the SMTP check: a provider whose SMTP servers give no useful
+// RCPT feedback (an API or heuristic check for a major mail provider) or rules of your own (an internal
+// directory, a CRM lookup)
+// A returned Result is final and replaces the SMTP check; returning an error hands the address back to SMTP
+type Verifier interface {
+	Verify(ctx context.Context, email string, mxRecords []*net.MX) (Result, error)
+}
+
+// Result is the verdict of a Verifier, with the meaning of the CheckEmailExists return values
+type Result struct {
+	Exists    bool   // Mailbox confirmed to exist
+	Err       string // Error or rejection message, empty when the mailbox exists
+	Category  string // Error category, e.g. "mailbox_not_found"
+	Permanent bool   // Whether the failure is permanent
+	TTL       int    // Cache TTL in seconds for temporary failures
+}
+
+// VerifierFunc adapts a function to the Verifier interface
+type VerifierFunc func(ctx context.Context, email string, mxRecords []*net.MX) (Result, error)
+
+// Verify calls f
+func (f VerifierFunc) Verify(ctx context.Context, email string, mxRecords []*net.MX) (Result, error) {
+	return f(ctx, email, mxRecords)
+}
+
+// Registered verifiers: by lower-cased pattern, and by predicate in registration order
+var verifiers struct {
+	sync.RWMutex
+	byPattern map[string]Verifier
+	matchers  []matchVerifier
+}
+
+// matchVerifier is a verifier registered for the addresses accepted by match
+type matchVerifier struct {
+	match    func(email string) bool
+	verifier Verifier
+}
+
+// SMTPVerifier is the built-in Verifier probing the mail hosts over SMTP; it checks every address no registered
+// verifier decided
+var SMTPVerifier Verifier = VerifierFunc(func(ctx context.Context, email string, mxRecords []*net.MX) (Result, error) {
+	exists, err, category, permanent, ttl := checkMailHosts(ctx, email, mxRecords)
+	return Result{Exists: exists, Err: err, Category: category, Permanent: permanent, TTL: ttl}, nil
+})
+
+// RegisterVerifier routes addresses matching pattern to v instead of the SMTP check
+// pattern is a domain ("gmail.com") or a "*.suffix" wildcard; it is matched against the email domain
+// and then the MX hosts, so "*.google.com" also covers Google Workspace customers on their own domains
+// Registering a nil verifier removes the pattern
+func RegisterVerifier(pattern string, v Verifier) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	verifiers.Lock()
+	defer verifiers.Unlock()
+	if v == nil {
+		delete(verifiers.byPattern, pattern)
+		return
+	}
+	if verifiers.byPattern == nil {
+		verifiers.byPattern = make(map[string]Verifier)
+	}
+	verifiers.byPattern[pattern] = v
+}
+
+// RegisterVerifierFunc routes addresses accepted by match (lower-cased emails) to v instead of the SMTP check
+// Predicates are consulted in registration order after the patterns of RegisterVerifier
+func RegisterVerifierFunc(match func(email string) bool, v Verifier) {
+	verifiers.Lock()
+	defer verifiers.Unlock()
+	verifiers.matchers = append(verifiers.matchers, matchVerifier{match: match, verifier: v})
+}
+
+// verifierFor returns the verifier registered for the email domain or one of its MX hosts, then the first
+// predicate accepting the address, with a description for logs
+func verifierFor(email string, mxRecords []*net.MX) (Verifier, string) {
+	verifiers.RLock()
+	defer verifiers.RUnlock()
+	if len(verifiers.byPattern) == 0 && len(verifiers.matchers) == 0 {
+		return nil, ""
+	}
+	email = strings.ToLower(email)
+	names := []string{email[strings.LastIndex(email, "@")+1:]}
+	for _, mx := range mxRecords {
+		names = append(names, strings.ToLower(strings.TrimSuffix(mx.Host, ".")))
+	}
+	for _, name := range names {
+		if v, ok := verifiers.byPattern[name]; ok {
+			return v, name
+		}
+		for parent := name; ; {
+			_, rest, found := strings.Cut(parent, ".")
+			if !found {
+				break
+			}
+			if v, ok := verifiers.byPattern["*."+rest]; ok {
+				return v, "*." + rest
+			}
+			parent = rest
+		}
+	}
+	for _, m := range verifiers.matchers {
+		if m.match(email) {
+			return m.verifier, "predicate"
+		}
+	}
+	return nil, ""
+}
+
+// Verify runs the registered verifier for the address, reporting false when none decided and SMTP must be used
+func Verify(ctx context.Context, email string, mxRecords []*net.MX) (Result, bool) {
+	v, pattern := verifierFor(email, mxRecords)
+	if v == nil {
+		return Result{}, false
+	}
+	res, err := v.Verify(ctx, email, mxRecords)
+	if err != nil {
+		logger.LogContext(ctx, fmt.Sprintf("[Verifier] %s verifier failed for %s, falling back to SMTP: %v", pattern, email, err))
+		return Result{}, false
+	}
+	return res, true
+}
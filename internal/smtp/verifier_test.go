@@ -0,0 +1,73 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// resetVerifiers clears the registry when the test ends
+func resetVerifiers(t *testing.T) {
+	t.Cleanup(func() {
+		verifiers.Lock()
+		defer verifiers.Unlock()
+		verifiers.byPattern = nil
+		verifiers.matchers = nil
+	})
+}
+
+// fixedVerifier returns a verifier reporting the mailbox as existing, tagged with category
+func fixedVerifier(category string) Verifier {
+	return VerifierFunc(func(ctx context.Context, email string, mxRecords []*net.MX) (Result, error) {
+		return Result{Exists: true, Category: category}, nil
+	})
+}
+
+func TestVerifyDispatch(t *testing.T) {
+	resetVerifiers(t)
+	RegisterVerifier("gmail.com", fixedVerifier("gmail"))
+	RegisterVerifier("*.google.com", fixedVerifier("workspace"))
+	RegisterVerifierFunc(func(email string) bool { return strings.HasSuffix(email, "@corp.example") }, fixedVerifier("directory"))
+
+	workspaceMX := []*net.MX{{Host: "aspmx.l.google.com.", Pref: 1}}
+	tests := []struct {
+		email    string
+		mx       []*net.MX
+		category string // Empty when no verifier decides
+	}{
+		{"user@gmail.com", nil, "gmail"},
+		{"User@GMAIL.com", nil, "gmail"},
+		{"user@company.example", workspaceMX, "workspace"},
+		{"user@mail.google.com", nil, "workspace"},
+		{"user@corp.example", nil, "directory"},
+		{"USER@Corp.Example", nil, "directory"},
+		{"user@example.org", []*net.MX{{Host: "mx.example.org."}}, ""},
+	}
+	for _, tt := range tests {
+		res, ok := Verify(context.Background(), tt.email, tt.mx)
+		if got := map[bool]string{true: res.Category}[ok]; got != tt.category {
+			t.Errorf("Verify(%s) decided by %q, want %q", tt.email, got, tt.category)
+		}
+	}
+}
+
+func TestVerifierErrorFallsBackToSMTP(t *testing.T) {
+	resetVerifiers(t)
+	RegisterVerifier("flaky.example", VerifierFunc(func(ctx context.Context, email string, mxRecords []*net.MX) (Result, error) {
+		return Result{}, errors.New("api unavailable")
+	}))
+	if _, ok := Verify(context.Background(), "user@flaky.example", nil); ok {
+		t.Fatal("failed verifier decided the address")
+	}
+}
+
+func TestRegisterNilVerifierRemovesPattern(t *testing.T) {
+	resetVerifiers(t)
+	RegisterVerifier("gmail.com", fixedVerifier("gmail"))
+	RegisterVerifier("gmail.com", nil)
+	if _, ok := Verify(context.Background(), "user@gmail.com", nil); ok {
+		t.Fatal("removed verifier still decides")
+	}
+}
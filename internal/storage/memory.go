@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/shuliakovsky/email-checker/internal/cache" // Cache provider interface
 	"github.com/shuliakovsky/email-checker/pkg/types"      // Custom types for tasks and other entities
@@ -15,12 +16,27 @@ type MemoryStorage struct {
 	tasks map[string]*types.Task // Map for storing tasks by their unique IDs
 	queue []*types.Task          // Task queue for local processing mode
 	cache cache.Provider         // Cache provider instance for secondary caching
+	keys  map[string]idempotency // Idempotency key to task ID mappings
+	seen  map[string]time.Time   // Last update time per task, used for expiry
+	ttl   time.Duration          // Retention period after the last update
+}
+
+// MemoryStorage must keep satisfying Storage, including the queue used in local mode
+var _ Storage = (*MemoryStorage)(nil)
+
+// idempotency is a task ID reserved under an idempotency key until it expires
+type idempotency struct {
+	taskID  string
+	expires time.Time
 }
 
 // NewMemoryStorage creates a new instance of MemoryStorage
 func NewMemoryStorage(cache cache.Provider) *MemoryStorage {
 	return &MemoryStorage{
 		tasks: make(map[string]*types.Task), // Initialize the task map
+		keys:  make(map[string]idempotency), // Initialize the idempotency key map
+		seen:  make(map[string]time.Time),   // Initialize the task update times
+		ttl:   DefaultTaskTTL,               // Keep tasks for the default retention period
 		cache: cache,                        // Assign the provided cache provider
 	}
 }
@@ -32,10 +48,35 @@ func (m *MemoryStorage) GetCacheProvider() cache.Provider {
 
 // SaveTask stores a task in memory, overwriting any existing task with the same ID
 func (m *MemoryStorage) SaveTask(ctx context.Context, task *types.Task) error {
-	m.mu.Lock()             // Acquire write lock for thread-safe access
-	defer m.mu.Unlock()     // Release lock after operation
-	m.tasks[task.ID] = task // Save or update the task in the map
-	return nil              // Return nil to indicate successful storage
+	m.mu.Lock()                  // Acquire write lock for thread-safe access
+	defer m.mu.Unlock()          // Release lock after operation
+	m.tasks[task.ID] = task      // Save or update the task in the map
+	m.seen[task.ID] = time.Now() // Restart the retention period
+	return nil                   // Return nil to indicate successful storage
+}
+
+// SetTaskTTL sets how long tasks are kept after their last update
+func (m *MemoryStorage) SetTaskTTL(ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ttl = ttl
+}
+
+// PurgeExpiredTasks removes tasks whose last update is older than the task TTL
+func (m *MemoryStorage) PurgeExpiredTasks(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := time.Now().Add(-m.ttl)
+	purged := 0
+	for id, updated := range m.seen {
+		if updated.Before(cutoff) {
+			delete(m.tasks, id)
+			delete(m.seen, id)
+			purged++
+		}
+	}
+	return purged, nil
 }
 
 // GetTask retrieves a task by ID from memory
@@ -49,9 +90,80 @@ func (m *MemoryStorage) GetTask(ctx context.Context, id string) (*types.Task, er
 	return task, nil // Return the retrieved task
 }
 
-// UpdateTask updates an existing task in memory by overwriting it
+// UpdateTask updates an existing task in memory by overwriting it, keeping stored results the task lacks
 func (m *MemoryStorage) UpdateTask(ctx context.Context, task *types.Task) error {
-	return m.SaveTask(ctx, task) // Use SaveTask for updating logic
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.tasks[task.ID]; ok && len(existing.Results) > len(task.Results) {
+		updated := *task
+		updated.Results = existing.Results // Only SaveTask replaces stored results
+		task = &updated
+	}
+	m.tasks[task.ID] = task
+	m.seen[task.ID] = time.Now()
+	return nil
+}
+
+// DeleteTask removes a task from memory
+func (m *MemoryStorage) DeleteTask(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tasks, id)
+	delete(m.seen, id)
+	return nil
+}
+
+// AppendTaskResults stores a copy of task whose results are the stored ones followed by results
+// The caller's task is not stored, so it may keep only the reports it has not saved yet
+func (m *MemoryStorage) AppendTaskResults(ctx context.Context, task *types.Task, results []types.EmailReport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var stored []types.EmailReport
+	if existing, ok := m.tasks[task.ID]; ok {
+		stored = existing.Results
+	}
+	updated := *task
+	updated.Results = append(stored, results...) // Earlier snapshots only read up to their own length
+	m.tasks[task.ID] = &updated
+	m.seen[task.ID] = time.Now()
+	return nil
+}
+
+// WalkTasks calls fn for a snapshot of the stored tasks
+func (m *MemoryStorage) WalkTasks(ctx context.Context, fn func(task *types.Task) error) error {
+	m.mu.RLock()
+	tasks := make([]*types.Task, 0, len(m.tasks))
+	for _, task := range m.tasks {
+		tasks = append(tasks, task)
+	}
+	m.mu.RUnlock()
+
+	for _, task := range tasks {
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTaskResultsPage returns a slice of the task results starting at offset
+func (m *MemoryStorage) GetTaskResultsPage(ctx context.Context, id string, offset, limit int) ([]types.EmailReport, int, error) {
+	task, err := m.GetTask(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	total := len(task.Results)
+	if offset < 0 || offset >= total {
+		return []types.EmailReport{}, total, nil // Page is out of range
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return task.Results[offset:end], total, nil
 }
 
 // DequeueTask removes and returns the first task from the in-memory queue
@@ -68,10 +180,46 @@ func (m *MemoryStorage) DequeueTask() (*types.Task, error) {
 	return task, nil
 }
 
-// EnqueueTask adds a task to the end of the in-memory queue
+// EnqueueTask adds a copy of the task without its results to the end of the in-memory queue
 func (m *MemoryStorage) EnqueueTask(task *types.Task) error {
+	queued := *task
+	queued.Results = nil // Workers load the stored results
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue = append(m.queue, &queued)
+	return nil
+}
+
+// QueueLen returns the number of tasks waiting in the in-memory queue
+func (m *MemoryStorage) QueueLen() (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.queue), nil
+}
+
+// ReserveIdempotencyKey maps key to taskID unless a live mapping already exists
+func (m *MemoryStorage) ReserveIdempotencyKey(ctx context.Context, key, taskID string, ttl time.Duration) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := m.keys[key]; ok && now.Before(existing.expires) {
+		return existing.taskID, false, nil
+	}
+	// Drop expired mappings while holding the lock to keep the map bounded
+	for k, v := range m.keys {
+		if !now.Before(v.expires) {
+			delete(m.keys, k)
+		}
+	}
+	m.keys[key] = idempotency{taskID: taskID, expires: now.Add(ttl)}
+	return taskID, true, nil
+}
+
+// ReleaseIdempotencyKey removes an idempotency key mapping
+func (m *MemoryStorage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.queue = append(m.queue, task)
+	delete(m.keys, key)
 	return nil
 }
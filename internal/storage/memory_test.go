@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+func TestMemoryStorageTasks(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStorage(nil)
+
+	if _, err := m.GetTask(ctx, "missing"); err == nil {
+		t.Fatal("GetTask of an unknown ID returned no error")
+	}
+
+	if err := m.SaveTask(ctx, &types.Task{ID: "t1", Status: "pending"}); err != nil {
+		t.Fatalf("SaveTask: %v", err)
+	}
+	task, err := m.GetTask(ctx, "t1")
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if task.Status != "pending" {
+		t.Fatalf("status = %q, want pending", task.Status)
+	}
+
+	if err := m.UpdateTask(ctx, &types.Task{ID: "t1", Status: "completed"}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	task, err = m.GetTask(ctx, "t1")
+	if err != nil {
+		t.Fatalf("GetTask after update: %v", err)
+	}
+	if task.Status != "completed" {
+		t.Fatalf("status after update = %q, want completed", task.Status)
+	}
+
+	if err := m.DeleteTask(ctx, "t1"); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if _, err := m.GetTask(ctx, "t1"); err == nil {
+		t.Fatal("GetTask of a deleted task returned no error")
+	}
+}
+
+func TestMemoryStorageAppendTaskResults(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStorage(nil)
+	m.SaveTask(ctx, &types.Task{ID: "t1", Results: []types.EmailReport{{Email: "a@example.com"}}})
+
+	snapshot, _ := m.GetTask(ctx, "t1")
+	if err := m.AppendTaskResults(ctx, &types.Task{ID: "t1", Status: "running"}, []types.EmailReport{{Email: "b@example.com"}}); err != nil {
+		t.Fatalf("AppendTaskResults: %v", err)
+	}
+
+	task, _ := m.GetTask(ctx, "t1")
+	if len(task.Results) != 2 || task.Results[1].Email != "b@example.com" || task.Status != "running" {
+		t.Fatalf("task after append = %+v", task)
+	}
+	if len(snapshot.Results) != 1 {
+		t.Fatalf("earlier snapshot changed to %d results", len(snapshot.Results))
+	}
+
+	page, total, err := m.GetTaskResultsPage(ctx, "t1", 1, 10)
+	if err != nil || total != 2 || len(page) != 1 || page[0].Email != "b@example.com" {
+		t.Fatalf("GetTaskResultsPage = %v, %d, %v", page, total, err)
+	}
+}
+
+func TestMemoryStorageQueueOrder(t *testing.T) {
+	m := NewMemoryStorage(nil)
+	if _, err := m.DequeueTask(); err == nil {
+		t.Fatal("DequeueTask on an empty queue returned no error")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := m.EnqueueTask(&types.Task{ID: fmt.Sprintf("t%d", i)}); err != nil {
+			t.Fatalf("EnqueueTask: %v", err)
+		}
+	}
+	if n, _ := m.QueueLen(); n != 3 {
+		t.Fatalf("QueueLen = %d, want 3", n)
+	}
+
+	for i := 0; i < 3; i++ {
+		task, err := m.DequeueTask()
+		if err != nil {
+			t.Fatalf("DequeueTask: %v", err)
+		}
+		if want := fmt.Sprintf("t%d", i); task.ID != want {
+			t.Fatalf("dequeued %s, want %s", task.ID, want)
+		}
+	}
+	if n, _ := m.QueueLen(); n != 0 {
+		t.Fatalf("QueueLen after draining = %d, want 0", n)
+	}
+}
+
+func TestMemoryStorageUpdateTaskKeepsStoredResults(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStorage(nil)
+
+	task := &types.Task{ID: "t1", Status: "processing"}
+	if err := m.SaveTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AppendTaskResults(ctx, task, []types.EmailReport{{Email: "a@example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.EnqueueTask(&types.Task{ID: "t1", Results: []types.EmailReport{{Email: "a@example.com"}}}); err != nil {
+		t.Fatal(err)
+	}
+	queued, err := m.DequeueTask()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued.Results) != 0 {
+		t.Fatalf("queued task carries %d results, want none", len(queued.Results))
+	}
+
+	queued.Status = "pending"
+	if err := m.UpdateTask(ctx, queued); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := m.GetTask(ctx, "t1")
+	if stored.Status != "pending" || len(stored.Results) != 1 {
+		t.Fatalf("stored task has status %q and %d results, want pending with 1", stored.Status, len(stored.Results))
+	}
+}
@@ -4,35 +4,64 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/shuliakovsky/email-checker/internal/cache"
+	"github.com/shuliakovsky/email-checker/internal/metrics"
 	"github.com/shuliakovsky/email-checker/pkg/types"
 )
 
 // Redis key identifier for the task queue
 const (
 	TaskQueueKey = "email_checker:tasks"
+
+	// MaxTaskMetadataBytes bounds the serialized task without its results; larger tasks are rejected
+	// with ErrTaskTooLarge instead of stalling Redis with a huge value
+	MaxTaskMetadataBytes = 8 << 20
+
+	resultsBatchSize = 500 // Results appended per RPUSH, so no single command carries a whole large task
 )
 
 // RedisStorage implements storage operations using Redis
 type RedisStorage struct {
-	client redis.UniversalClient
-	cache  cache.Provider
+	client  redis.UniversalClient
+	cache   cache.Provider
+	taskTTL time.Duration // Expiry applied to task keys on every save
 }
 
+// RedisStorage must keep satisfying Storage
+var _ Storage = (*RedisStorage)(nil)
+
 // Creates new RedisStorage instance with specified Redis client
 func NewRedisStorage(client redis.UniversalClient) *RedisStorage {
 	return &RedisStorage{
-		client: client,
-		cache:  cache.NewRedisCache(client),
+		client:  client,
+		cache:   cache.NewRedisCache(client),
+		taskTTL: DefaultTaskTTL,
 	}
 }
 
+// SetTaskTTL sets the expiry applied to tasks and their results on every save
+func (r *RedisStorage) SetTaskTTL(ttl time.Duration) {
+	r.taskTTL = ttl
+}
+
+// PurgeExpiredTasks is a no-op: Redis expires task keys natively
+func (r *RedisStorage) PurgeExpiredTasks(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
 // Adds task to the processing queue (LPUSH operation)
+// Results are left out: they live in the task results list, and the payload stays as small as the saved metadata
 func (r *RedisStorage) EnqueueTask(task *types.Task) error {
-	data, _ := json.Marshal(task)
+	queued := *task
+	queued.Results = nil
+	data, err := json.Marshal(queued)
+	if err != nil {
+		return err
+	}
 	return r.client.LPush(context.Background(), "email_checker:tasks", data).Err()
 }
 
@@ -50,18 +79,72 @@ func (r *RedisStorage) DequeueTask() (*types.Task, error) {
 	return &task, nil
 }
 
+// QueueLen returns the length of the task queue (LLEN operation)
+func (r *RedisStorage) QueueLen() (int, error) {
+	n, err := r.client.LLen(context.Background(), TaskQueueKey).Result()
+	return int(n), err
+}
+
 // GetCacheProvider returns the cache provider instance
 func (r *RedisStorage) GetCacheProvider() cache.Provider {
 	return r.cache
 }
 
-// SaveTask saves a task to Redis storage with 24-hour expiration
+// SaveTask saves a task to Redis storage, expiring after the task TTL
+// Results are kept in a separate list so pages can be read with LRANGE; any previously stored results are replaced
 func (r *RedisStorage) SaveTask(ctx context.Context, task *types.Task) error {
-	data, err := json.Marshal(task) // Serialize task into JSON format
+	return r.writeTask(ctx, task, task.Results, true)
+}
+
+// AppendTaskResults overwrites the task metadata and pushes results onto the stored results list
+func (r *RedisStorage) AppendTaskResults(ctx context.Context, task *types.Task, results []types.EmailReport) error {
+	return r.writeTask(ctx, task, results, false)
+}
+
+// writeTask stores the task metadata and appends results to its results list
+// With replace the previously stored results are removed first
+func (r *RedisStorage) writeTask(ctx context.Context, task *types.Task, reports []types.EmailReport, replace bool) error {
+	meta := *task
+	meta.Results = nil              // Results live in the task results list
+	data, err := json.Marshal(meta) // Serialize task into JSON format
 	if err != nil {
 		return err // Return error if serialization fails
 	}
-	return r.client.Set(ctx, "task:"+task.ID, data, 24*time.Hour).Err() // Store the task with a 24-hour TTL
+	metrics.TaskPayloadBytes.Observe(float64(len(data)))
+	if len(data) > MaxTaskMetadataBytes {
+		return fmt.Errorf("%w: %d bytes (max %d)", ErrTaskTooLarge, len(data), MaxTaskMetadataBytes)
+	}
+
+	results := make([]interface{}, 0, len(reports))
+	for _, report := range reports {
+		item, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		results = append(results, item)
+	}
+
+	resultsKey := taskResultsKey(task.ID)
+	pipe := r.client.Pipeline()                     // Plain pipeline: keys may live on different cluster slots
+	pipe.Set(ctx, "task:"+task.ID, data, r.taskTTL) // Store the task with the configured TTL
+	if replace {
+		pipe.Del(ctx, resultsKey)
+	}
+	for start := 0; start < len(results); start += resultsBatchSize {
+		pipe.RPush(ctx, resultsKey, results[start:min(start+resultsBatchSize, len(results))]...)
+	}
+	pipe.Expire(ctx, resultsKey, r.taskTTL) // Also refreshes the expiry of results stored earlier
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// DeleteTask removes the task metadata and its results list
+func (r *RedisStorage) DeleteTask(ctx context.Context, id string) error {
+	pipe := r.client.Pipeline() // Plain pipeline: keys may live on different cluster slots
+	pipe.Del(ctx, "task:"+id)
+	pipe.Del(ctx, taskResultsKey(id))
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
 // GetTask retrieves a task from Redis storage by its ID
@@ -78,11 +161,131 @@ func (r *RedisStorage) GetTask(ctx context.Context, id string) (*types.Task, err
 	if err := json.Unmarshal(data, &task); err != nil { // Deserialize JSON data into a Task struct
 		return nil, err
 	}
+
+	items, err := r.client.LRange(ctx, taskResultsKey(id), 0, -1).Result() // Load all stored results
+	if err != nil {
+		return nil, err
+	}
+	if task.Results, err = decodeReports(items); err != nil {
+		return nil, err
+	}
 	return &task, nil // Return the deserialized task
 }
 
-// UpdateTask updates an existing task in Redis storage by overwriting it
-// Uses same storage logic as SaveTask with updated data
+// GetTaskResultsPage reads a range of task results with LRANGE instead of loading the whole task
+func (r *RedisStorage) GetTaskResultsPage(ctx context.Context, id string, offset, limit int) ([]types.EmailReport, int, error) {
+	exists, err := r.client.Exists(ctx, "task:"+id).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	if exists == 0 {
+		return nil, 0, fmt.Errorf("task not found")
+	}
+
+	resultsKey := taskResultsKey(id)
+	total, err := r.client.LLen(ctx, resultsKey).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset < 0 || int64(offset) >= total {
+		return []types.EmailReport{}, int(total), nil // Page is out of range
+	}
+
+	items, err := r.client.LRange(ctx, resultsKey, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	reports, err := decodeReports(items)
+	return reports, int(total), err
+}
+
+// ReserveIdempotencyKey maps key to taskID with SETNX so concurrent retries agree on one task
+func (r *RedisStorage) ReserveIdempotencyKey(ctx context.Context, key, taskID string, ttl time.Duration) (string, bool, error) {
+	redisKey := idempotencyKey(key)
+	created, err := r.client.SetNX(ctx, redisKey, taskID, ttl).Result()
+	if err != nil {
+		return "", false, err
+	}
+	if created {
+		return taskID, true, nil
+	}
+
+	existing, err := r.client.Get(ctx, redisKey).Result()
+	if err == redis.Nil {
+		// Mapping expired between SETNX and GET; retry the reservation
+		return r.ReserveIdempotencyKey(ctx, key, taskID, ttl)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return existing, false, nil
+}
+
+// ReleaseIdempotencyKey removes an idempotency key mapping
+func (r *RedisStorage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	return r.client.Del(ctx, idempotencyKey(key)).Err()
+}
+
+// idempotencyKey returns the Redis key holding an idempotency key mapping
+func idempotencyKey(key string) string {
+	return "idempotency:" + key
+}
+
+// taskResultsKey returns the Redis list key holding task results
+// WalkTasks enumerates task keys with cursor-based SCAN (on every master in Redis Cluster)
+// and loads each task with its results
+func (r *RedisStorage) WalkTasks(ctx context.Context, fn func(task *types.Task) error) error {
+	walk := func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.Scan(ctx, 0, "task:*", 100).Iterator()
+		for iter.Next(ctx) {
+			key := iter.Val()
+			if strings.HasSuffix(key, ":results") {
+				continue
+			}
+			task, err := r.GetTask(ctx, strings.TrimPrefix(key, "task:"))
+			if err != nil {
+				continue // Expired between SCAN and GET
+			}
+			if err := fn(task); err != nil {
+				return err
+			}
+		}
+		return iter.Err()
+	}
+
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return walk(ctx, node)
+		})
+	}
+	return walk(ctx, r.client)
+}
+
+func taskResultsKey(id string) string {
+	return "task:" + id + ":results"
+}
+
+// decodeReports deserializes JSON-encoded results read from Redis
+func decodeReports(items []string) ([]types.EmailReport, error) {
+	reports := make([]types.EmailReport, 0, len(items))
+	for _, item := range items {
+		var report types.EmailReport
+		if err := json.Unmarshal([]byte(item), &report); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// UpdateTask overwrites the task metadata and appends only the results added since the last write
+// Stored results are kept when fewer are given (e.g. a task loaded from the queue without them);
+// restarting a task from scratch takes an explicit SaveTask
 func (r *RedisStorage) UpdateTask(ctx context.Context, task *types.Task) error {
-	return r.SaveTask(ctx, task) // Reuses SaveTask method to update the task
+	stored, err := r.client.LLen(ctx, taskResultsKey(task.ID)).Result()
+	if err != nil {
+		return err
+	}
+	added := task.Results[min(int(stored), len(task.Results)):]
+	return r.writeTask(ctx, task, added, false)
 }
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+// newTestRedisStorage returns a RedisStorage backed by an in-process Redis
+func newTestRedisStorage(t *testing.T) (*RedisStorage, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisStorage(client), mr
+}
+
+func TestRedisUpdateTaskKeepsStoredResults(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedisStorage(t)
+
+	task := &types.Task{ID: "t1", Status: "processing", Emails: []string{"a@example.com", "b@example.com"}}
+	if err := r.SaveTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AppendTaskResults(ctx, task, []types.EmailReport{{Email: "a@example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A task without its results (e.g. taken from the queue) only updates the metadata
+	if err := r.UpdateTask(ctx, &types.Task{ID: "t1", Status: "pending", Emails: task.Emails}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := r.GetTask(ctx, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != "pending" || len(stored.Results) != 1 {
+		t.Fatalf("stored task has status %q and %d results, want pending with 1", stored.Status, len(stored.Results))
+	}
+
+	// Results beyond the stored ones are appended
+	stored.Results = append(stored.Results, types.EmailReport{Email: "b@example.com"})
+	if err := r.UpdateTask(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := r.GetTaskResultsPage(ctx, "t1", 0, 10); total != 2 {
+		t.Fatalf("%d results stored, want 2", total)
+	}
+
+	// SaveTask is the explicit reset
+	if err := r.SaveTask(ctx, &types.Task{ID: "t1", Status: "pending", Emails: task.Emails}); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := r.GetTaskResultsPage(ctx, "t1", 0, 10); total != 0 {
+		t.Fatalf("%d results left after SaveTask, want 0", total)
+	}
+}
+
+func TestRedisQueuePayloadHasNoResults(t *testing.T) {
+	r, mr := newTestRedisStorage(t)
+
+	task := &types.Task{ID: "t1", Emails: []string{"a@example.com"}, Results: []types.EmailReport{{Email: "a@example.com"}}}
+	if err := r.EnqueueTask(task); err != nil {
+		t.Fatal(err)
+	}
+	items, err := mr.List(TaskQueueKey)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("queue = %v, %v; want one entry", items, err)
+	}
+	var queued types.Task
+	if err := json.Unmarshal([]byte(items[0]), &queued); err != nil {
+		t.Fatal(err)
+	}
+	if queued.ID != "t1" || len(queued.Emails) != 1 || len(queued.Results) != 0 {
+		t.Fatalf("queued %+v, want the task without results", queued)
+	}
+	if len(task.Results) != 1 {
+		t.Fatal("EnqueueTask modified the caller's task")
+	}
+}
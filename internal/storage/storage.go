@@ -2,28 +2,65 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/shuliakovsky/email-checker/internal/cache" // Cache provider interface
 	"github.com/shuliakovsky/email-checker/pkg/types"      // Custom types for tasks and other entities
 )
 
+// DefaultTaskTTL is how long tasks and their results are kept after the last update
+const DefaultTaskTTL = 24 * time.Hour
+
+// ErrTaskTooLarge is returned when a task exceeds the size the backend can store safely
+var ErrTaskTooLarge = errors.New("task too large to store")
+
 // Storage defines the interface for persistence operations related to tasks
 type Storage interface {
-	// Saves a task to persistent storage
+	// Saves a task to persistent storage, replacing any stored results with task.Results
 	SaveTask(ctx context.Context, task *types.Task) error
 
 	// Retrieves a task by its unique identifier
 	GetTask(ctx context.Context, id string) (*types.Task, error)
 
-	// Updates an existing task in storage
+	// Updates an existing task in storage, adding the results of task.Results beyond those already stored
+	// Stored results are never removed, even when task holds fewer of them; SaveTask replaces them
 	UpdateTask(ctx context.Context, task *types.Task) error
 
+	// Removes a task and its results (e.g. when task creation failed half way)
+	DeleteTask(ctx context.Context, id string) error
+
+	// Stores the task metadata and appends results to the results already stored; task.Results is ignored
+	// Lets long tasks persist reports as they arrive without keeping all of them in memory
+	AppendTaskResults(ctx context.Context, task *types.Task, results []types.EmailReport) error
+
+	// Retrieves a page of task results with the total result count, without loading the whole task where possible
+	GetTaskResultsPage(ctx context.Context, id string, offset, limit int) ([]types.EmailReport, int, error)
+
+	// Atomically maps an idempotency key to a task ID unless already mapped
+	// Returns the task ID stored under the key and whether this call created the mapping
+	ReserveIdempotencyKey(ctx context.Context, key, taskID string, ttl time.Duration) (string, bool, error)
+
+	// Removes an idempotency key mapping (e.g. when task creation failed)
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+
+	// Deletes tasks not updated within the task TTL; returns the number removed
+	// Backends with native expiry (Redis) have nothing to purge
+	PurgeExpiredTasks(ctx context.Context) (int, error)
+
+	// Calls fn with every stored task including its results; stops at the first error
+	WalkTasks(ctx context.Context, fn func(task *types.Task) error) error
+
 	// Provides access to the cache layer instance
 	GetCacheProvider() cache.Provider
 
 	// Adds task to processing queue (local mode without context)
+	// The queue carries the task without its results; workers resume from the stored task
 	EnqueueTask(task *types.Task) error
 
 	// Retrieves and removes task from queue (local mode blocking pop)
 	DequeueTask() (*types.Task, error)
+
+	// Returns the number of tasks waiting in the queue
+	QueueLen() (int, error)
 }
@@ -2,7 +2,12 @@
 package throttle
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/shuliakovsky/email-checker/internal/cache"
@@ -17,12 +22,48 @@ const (
 
 // Central throttling controller with cache backend
 type ThrottleManager struct {
-	cache cache.Provider // Storage for throttle states and retry schedules
+	cache cache.Provider // Storage for throttle states
+	ttl   atomic.Int64   // Domain block duration in nanoseconds, tunable at runtime
+
+	mu        sync.Mutex                // Guards throttled and retries
+	throttled map[string]time.Time      // Expiry per throttled domain, kept for snapshots
+	retries   map[string]scheduledRetry // Pending re-checks by email
+}
+
+// scheduledRetry is a pending re-check of an email after a temporary failure
+type scheduledRetry struct {
+	attempt int       // 1-based retry number
+	due     time.Time // Earliest time the email may be re-checked
+}
+
+// Retry is a re-check that became due
+type Retry struct {
+	Email   string // Normalized email address
+	Attempt int    // 1-based retry number
 }
 
 // Creates new manager with specified cache provider
 func NewThrottleManager(cache cache.Provider) *ThrottleManager {
-	return &ThrottleManager{cache: cache}
+	tm := &ThrottleManager{
+		cache:     cache,
+		throttled: make(map[string]time.Time),
+		retries:   make(map[string]scheduledRetry),
+	}
+	tm.ttl.Store(int64(ThrottleTTL))
+	return tm
+}
+
+// TTL returns the current domain block duration
+func (tm *ThrottleManager) TTL() time.Duration {
+	return time.Duration(tm.ttl.Load())
+}
+
+// SetTTL changes the block duration for subsequently throttled domains
+func (tm *ThrottleManager) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = ThrottleTTL
+	}
+	tm.ttl.Store(int64(ttl))
 }
 
 // Check if domain is currently blocked
@@ -31,26 +72,164 @@ func (tm *ThrottleManager) IsThrottled(domain string) bool {
 	return ok
 }
 
-// Block domain with default TTL (60s)
+// Remaining returns how long the domain stays blocked (0 if not throttled)
+// Falls back to the block duration when the cache backend doesn't preserve the expiry time
+func (tm *ThrottleManager) Remaining(domain string) time.Duration {
+	value, ok := tm.cache.Get("throttle:" + domain)
+	if !ok {
+		return 0
+	}
+	until, ok := value.(time.Time)
+	if !ok {
+		return tm.TTL()
+	}
+	if remaining := time.Until(until); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// ThrottledUntil returns when the domain throttle expires (zero time if not throttled)
+func (tm *ThrottleManager) ThrottledUntil(domain string) time.Time {
+	remaining := tm.Remaining(domain)
+	if remaining <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(remaining).UTC()
+}
+
+// Block domain with the configured TTL (60s by default)
 func (tm *ThrottleManager) ThrottleDomain(domain string) {
-	tm.cache.Set("throttle:"+domain, struct{}{}, ThrottleTTL)
-	logger.Log(fmt.Sprintf("[Throttle] Domain %s throttled for %v", domain, ThrottleTTL))
+	tm.ThrottleDomainWithTTL(domain, tm.TTL())
 }
 
 // Schedule email retry with attempt-specific delay
+// The retry is never due before the email's domain throttle expires (its Retry-After).
+// Attempts beyond MaxRetries cancel any pending retry instead
 func (tm *ThrottleManager) ScheduleRetry(email string, attempt int) {
-	metrics.RetryAttempts.WithLabelValues(email, fmt.Sprintf("%d", attempt)).Inc()
-	delay := getRetryDelay(attempt) // Get attempt-based delay
-	key := fmt.Sprintf("retry:%s:%d", email, attempt)
-	tm.cache.Set(key, email, delay) // Store retry schedule
+	if attempt > MaxRetries {
+		tm.CancelRetry(email)
+		logger.Log(fmt.Sprintf("[Retry] Giving up on %s after %d retries", email, MaxRetries))
+		return
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	metrics.RetryAttempts.WithLabelValues(metrics.DomainLabel(domain), fmt.Sprintf("%d", attempt)).Inc()
+	delay := max(getRetryDelay(attempt), tm.Remaining(domain)) // Attempt-based delay, not before the throttle expires
+
+	tm.mu.Lock()
+	tm.retries[email] = scheduledRetry{attempt: attempt, due: time.Now().Add(delay)}
+	tm.mu.Unlock()
+}
+
+// CancelRetry drops a pending retry of the email
+func (tm *ThrottleManager) CancelRetry(email string) {
+	tm.mu.Lock()
+	delete(tm.retries, email)
+	tm.mu.Unlock()
+}
+
+// DueRetries removes and returns the retries whose delay has elapsed
+func (tm *ThrottleManager) DueRetries() []Retry {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	now := time.Now()
+	var due []Retry
+	for email, retry := range tm.retries {
+		if now.Before(retry.due) {
+			continue
+		}
+		due = append(due, Retry{Email: email, Attempt: retry.attempt})
+		delete(tm.retries, email)
+	}
+	return due
+}
+
+// RetryScheduled reports whether a re-check of the email is pending
+func (tm *ThrottleManager) RetryScheduled(email string) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	_, ok := tm.retries[email]
+	return ok
+}
+
+// PendingRetries returns the number of scheduled retries
+func (tm *ThrottleManager) PendingRetries() int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return len(tm.retries)
+}
+
+// ThrottledDomains returns the number of domains currently blocked by this node
+func (tm *ThrottleManager) ThrottledDomains() int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	now := time.Now()
+	count := 0
+	for _, until := range tm.throttled {
+		if now.Before(until) {
+			count++
+		}
+	}
+	return count
 }
 
 // Block domain with custom TTL duration
 func (tm *ThrottleManager) ThrottleDomainWithTTL(domain string, ttl time.Duration) {
-	tm.cache.Set("throttle:"+domain, struct{}{}, ttl)
+	until := time.Now().Add(ttl)
+	tm.cache.Set("throttle:"+domain, until, ttl) // Keep expiry time to report remaining TTL
+	tm.track(domain, until)
 	logger.Log(fmt.Sprintf("[Throttle] Domain %s throttled for %v", domain, ttl))
 }
 
+// track records a domain expiry for snapshots and drops expired entries
+func (tm *ThrottleManager) track(domain string, until time.Time) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	now := time.Now()
+	for d, expiry := range tm.throttled {
+		if !now.Before(expiry) {
+			delete(tm.throttled, d)
+		}
+	}
+	tm.throttled[domain] = until
+}
+
+// Snapshot writes the active domain throttles as JSON (domain -> expiry time)
+// Used to carry throttle state across restarts when the cache is in-memory
+func (tm *ThrottleManager) Snapshot(w io.Writer) error {
+	tm.mu.Lock()
+	now := time.Now()
+	active := make(map[string]time.Time, len(tm.throttled))
+	for domain, until := range tm.throttled {
+		if now.Before(until) {
+			active[domain] = until
+		}
+	}
+	tm.mu.Unlock()
+
+	return json.NewEncoder(w).Encode(active)
+}
+
+// Restore re-applies throttles from a Snapshot; entries that expired meanwhile are skipped
+func (tm *ThrottleManager) Restore(r io.Reader) (int, error) {
+	var snapshot map[string]time.Time
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return 0, fmt.Errorf("decode throttle snapshot: %w", err)
+	}
+
+	restored := 0
+	for domain, until := range snapshot {
+		remaining := time.Until(until)
+		if remaining <= 0 {
+			continue
+		}
+		tm.cache.Set("throttle:"+domain, until, remaining)
+		tm.track(domain, until)
+		restored++
+	}
+	return restored, nil
+}
+
 // Get delay duration based on attempt number
 func getRetryDelay(attempt int) time.Duration {
 	switch attempt {
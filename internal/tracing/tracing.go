@@ -0,0 +1,90 @@
+// Package tracing wraps OpenTelemetry tracing for the check pipeline
+// Spans are no-ops until Init configures an OTLP exporter
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName identifies this service in exported spans unless OTEL_SERVICE_NAME is set
+const serviceName = "email-checker"
+
+// tracer delegates to the global provider, so spans started before Init become real once it runs
+var tracer = otel.Tracer("github.com/shuliakovsky/email-checker")
+
+// propagator carries W3C trace context between HTTP requests and queued tasks
+var propagator = propagation.TraceContext{}
+
+// Init exports spans over OTLP/HTTP to endpoint (e.g. http://collector:4318)
+// Sampling follows the standard OTEL_TRACES_SAMPLER variables (parent-based always-on by default)
+// The returned function flushes pending spans and must be called before exit
+func Init(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	var opts []otlptracehttp.Option
+	if strings.Contains(endpoint, "://") {
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	} else {
+		opts = append(opts, otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.NewSchemaless(attribute.String("service.name", serviceName)), resource.Default())
+	if err != nil {
+		return nil, fmt.Errorf("build trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	return provider.Shutdown, nil
+}
+
+// Start begins a span as a child of any span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartServer begins a server span continuing the trace context of an incoming HTTP request
+func StartServer(ctx context.Context, header propagation.TextMapCarrier, name string) (context.Context, trace.Span) {
+	ctx = propagator.Extract(ctx, header)
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// Inject returns the trace context of ctx as key/value pairs for storing alongside a task
+// Returns nil when ctx carries no sampled span
+func Inject(ctx context.Context) map[string]string {
+	if !trace.SpanContextFromContext(ctx).IsSampled() {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier
+}
+
+// Extract returns ctx continuing the trace context stored by Inject
+func Extract(ctx context.Context, stored map[string]string) context.Context {
+	if len(stored) == 0 {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier(stored))
+}
+
+// Fail marks a span as failed with the given description
+func Fail(span trace.Span, description string) {
+	span.SetStatus(codes.Error, description)
+}
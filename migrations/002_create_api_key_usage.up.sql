@@ -0,0 +1,10 @@
+
+CREATE TABLE api_key_usage (
+                          id BIGSERIAL PRIMARY KEY,
+                          api_key TEXT NOT NULL REFERENCES api_keys (api_key) ON DELETE CASCADE,
+                          task_id TEXT NOT NULL,
+                          checks INT NOT NULL CHECK (checks > 0),
+                          created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX api_key_usage_api_key_created_at_idx ON api_key_usage (api_key, created_at);
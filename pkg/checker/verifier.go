@@ -0,0 +1,66 @@
+// Package checker exposes the extension points of the email checker to programs embedding it
+package checker
+
+import (
+	"context"
+	"net"
+
+	"github.com/shuliakovsky/email-checker/internal/smtp"
+)
+
+// Verifier checks a mailbox instead of the built-in SMTP check: a provider whose SMTP servers give no useful
+// RCPT feedback (an API or heuristic check for a major mail provider) or rules of your own (an internal
+// directory, a CRM lookup)
+// A returned VerifyResult is final and replaces the SMTP check; returning an error hands the address back to SMTP
+type Verifier interface {
+	Verify(ctx context.Context, email string) (VerifyResult, error)
+}
+
+// VerifyResult is the verdict of a Verifier
+type VerifyResult struct {
+	Exists    bool   // Mailbox confirmed to exist
+	Err       string // Error or rejection message, empty when the mailbox exists
+	Category  string // Error category, e.g. "mailbox_not_found"
+	Permanent bool   // Whether the failure is permanent
+	TTL       int    // Cache TTL in seconds for temporary failures
+}
+
+// VerifierFunc adapts a function to the Verifier interface
+type VerifierFunc func(ctx context.Context, email string) (VerifyResult, error)
+
+// Verify calls f
+func (f VerifierFunc) Verify(ctx context.Context, email string) (VerifyResult, error) {
+	return f(ctx, email)
+}
+
+// RegisterVerifier routes addresses matching pattern to v instead of the SMTP check
+// pattern is a domain ("gmail.com") or a "*.suffix" wildcard; it is matched against the email domain
+// and then the MX hosts, so "*.google.com" also covers Google Workspace customers on their own domains
+// Registering a nil verifier removes the pattern
+func RegisterVerifier(pattern string, v Verifier) {
+	if v == nil {
+		smtp.RegisterVerifier(pattern, nil)
+		return
+	}
+	smtp.RegisterVerifier(pattern, adapt(v))
+}
+
+// RegisterVerifierFunc routes addresses accepted by match (lower-cased emails) to v instead of the SMTP check
+// Predicates are consulted in registration order after the patterns of RegisterVerifier
+func RegisterVerifierFunc(match func(email string) bool, v Verifier) {
+	smtp.RegisterVerifierFunc(match, adapt(v))
+}
+
+// adapt wraps v as a verifier of the SMTP registry, which also hands over the MX records
+func adapt(v Verifier) smtp.Verifier {
+	return smtp.VerifierFunc(func(ctx context.Context, email string, _ []*net.MX) (smtp.Result, error) {
+		res, err := v.Verify(ctx, email)
+		return smtp.Result{
+			Exists:    res.Exists,
+			Err:       res.Err,
+			Category:  res.Category,
+			Permanent: res.Permanent,
+			TTL:       res.TTL,
+		}, err
+	})
+}
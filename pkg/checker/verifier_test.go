@@ -0,0 +1,48 @@
+package checker_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/shuliakovsky/email-checker/internal/smtp"
+	"github.com/shuliakovsky/email-checker/pkg/checker"
+)
+
+func TestRegisteredVerifiersDecide(t *testing.T) {
+	checker.RegisterVerifier("*.provider.example", checker.VerifierFunc(func(ctx context.Context, email string) (checker.VerifyResult, error) {
+		return checker.VerifyResult{Err: "no such user", Category: "mailbox_not_found", Permanent: true}, nil
+	}))
+	t.Cleanup(func() { checker.RegisterVerifier("*.provider.example", nil) })
+	checker.RegisterVerifierFunc(func(email string) bool { return strings.HasSuffix(email, "@directory.example") },
+		checker.VerifierFunc(func(ctx context.Context, email string) (checker.VerifyResult, error) {
+			return checker.VerifyResult{Exists: true}, nil
+		}))
+
+	// Matched through the MX host, like a Workspace domain
+	res, ok := smtp.Verify(context.Background(), "user@customer.example", []*net.MX{{Host: "mx.provider.example."}})
+	if !ok || res.Exists || res.Category != "mailbox_not_found" || !res.Permanent {
+		t.Fatalf("pattern verifier result = %+v, %v; want a permanent mailbox_not_found", res, ok)
+	}
+	if res, ok := smtp.Verify(context.Background(), "User@Directory.example", nil); !ok || !res.Exists {
+		t.Fatalf("predicate verifier result = %+v, %v; want exists", res, ok)
+	}
+
+	checker.RegisterVerifier("*.provider.example", nil)
+	if _, ok := smtp.Verify(context.Background(), "user@customer.example", []*net.MX{{Host: "mx.provider.example."}}); ok {
+		t.Fatal("removed verifier still decided")
+	}
+}
+
+func TestVerifierErrorFallsBackToSMTP(t *testing.T) {
+	checker.RegisterVerifier("flaky.example", checker.VerifierFunc(func(ctx context.Context, email string) (checker.VerifyResult, error) {
+		return checker.VerifyResult{}, errors.New("api unavailable")
+	}))
+	t.Cleanup(func() { checker.RegisterVerifier("flaky.example", nil) })
+
+	if _, ok := smtp.Verify(context.Background(), "user@flaky.example", nil); ok {
+		t.Fatal("failed verifier decided instead of leaving the address to SMTP")
+	}
+}
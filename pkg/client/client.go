@@ -0,0 +1,222 @@
+// Package client provides a typed Go client for the email-checker server API
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shuliakovsky/email-checker/pkg/types"
+)
+
+const (
+	// MaxPerPage is the largest results page served by the API
+	MaxPerPage = 100
+
+	defaultPollInterval = 2 * time.Second // Interval between status polls in WaitForCompletion
+)
+
+// Client calls the email-checker API on behalf of one API key
+type Client struct {
+	baseURL    string       // Server URL, e.g. https://checker.example.com
+	apiKey     string       // Value sent in the X-API-Key header
+	httpClient *http.Client // Transport used for all requests
+
+	// PollInterval is the delay between status checks in WaitForCompletion
+	PollInterval time.Duration
+}
+
+// TaskStatus is the response of GET /tasks/{task_id}
+type TaskStatus struct {
+	Status       string             `json:"status"`
+	TotalEmails  int                `json:"total_emails"`
+	TotalResults int                `json:"total_results"`
+	Processed    int                `json:"processed"`
+	Skipped      int                `json:"skipped"`
+	CreatedAt    time.Time          `json:"created_at"`
+	TotalPages   int                `json:"total_pages,omitempty"`
+	Summary      *types.TaskSummary `json:"summary,omitempty"`
+	ExportURL    string             `json:"export_url,omitempty"`   // Object the results were uploaded to
+	ExportError  string             `json:"export_error,omitempty"` // Why the export upload failed
+}
+
+// Done reports whether the task has finished processing
+func (s TaskStatus) Done() bool {
+	return s.Status == "completed" || s.Status == "completed_partial"
+}
+
+// ResultsPage is one page of task results
+type ResultsPage struct {
+	Data  []types.EmailReport `json:"data"`
+	Page  int                 `json:"page"`
+	Total int                 `json:"total"`
+}
+
+// APIError is returned for non-2xx responses
+type APIError struct {
+	StatusCode int    // HTTP status code
+	Code       string // Machine-readable error code (e.g. "not_found"); empty for non-JSON bodies
+	Message    string // Error message from the response body
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("email-checker API: %d %s", e.StatusCode, e.Message)
+}
+
+// New creates a client for the server at baseURL authenticating with apiKey
+// A nil httpClient uses http.DefaultClient
+func New(baseURL, apiKey string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		apiKey:       apiKey,
+		httpClient:   httpClient,
+		PollInterval: defaultPollInterval,
+	}
+}
+
+// CreateTask submits emails for asynchronous verification and returns the task ID
+func (c *Client) CreateTask(ctx context.Context, emails []string) (string, error) {
+	return c.createTask(ctx, "/tasks", map[string]interface{}{"emails": emails})
+}
+
+// CreateTaskWithWebhook submits emails and registers a webhook notified on completion
+// The webhook TTL is sent from TTLStr (e.g. "1h")
+func (c *Client) CreateTaskWithWebhook(ctx context.Context, emails []string, webhook types.WebhookConfig) (string, error) {
+	return c.createTask(ctx, "/tasks-with-webhook", map[string]interface{}{"emails": emails, "webhook": webhook})
+}
+
+// CreateTaskWithWebhooks submits emails and registers several webhooks, each notified and retried independently
+func (c *Client) CreateTaskWithWebhooks(ctx context.Context, emails []string, webhooks []types.WebhookConfig) (string, error) {
+	return c.createTask(ctx, "/tasks-with-webhook", map[string]interface{}{"emails": emails, "webhooks": webhooks})
+}
+
+// createTask posts a task creation request and decodes the task ID
+func (c *Client) createTask(ctx context.Context, path string, body interface{}) (string, error) {
+	var created struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, path, body, &created); err != nil {
+		return "", err
+	}
+	return created.TaskID, nil
+}
+
+// GetTaskStatus returns the processing status of a task
+func (c *Client) GetTaskStatus(ctx context.Context, taskID string) (*TaskStatus, error) {
+	var status TaskStatus
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+url.PathEscape(taskID), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetResults returns one page of task results (pages start at 1, perPage is capped at MaxPerPage)
+func (c *Client) GetResults(ctx context.Context, taskID string, page, perPage int) (*ResultsPage, error) {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("per_page", strconv.Itoa(perPage))
+
+	var results ResultsPage
+	if err := c.do(ctx, http.MethodGet, "/tasks-results/"+url.PathEscape(taskID)+"?"+query.Encode(), nil, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// GetAllResults pages through every result of a task
+func (c *Client) GetAllResults(ctx context.Context, taskID string) ([]types.EmailReport, error) {
+	var all []types.EmailReport
+	for page := 1; ; page++ {
+		results, err := c.GetResults(ctx, taskID, page, MaxPerPage)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, results.Data...)
+		if len(results.Data) == 0 || len(all) >= results.Total {
+			return all, nil
+		}
+	}
+}
+
+// WaitForCompletion polls the task status until it finishes or ctx is done
+func (c *Client) WaitForCompletion(ctx context.Context, taskID string) (*TaskStatus, error) {
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status, err := c.GetTaskStatus(ctx, taskID)
+		if err != nil {
+			return nil, err
+		}
+		if status.Done() {
+			return status, nil
+		}
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// do sends an authenticated JSON request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// newAPIError extracts the error message from a JSON or plain text error response
+func newAPIError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var body struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		apiErr.Message = body.Error
+		apiErr.Code = body.Code
+	}
+	return apiErr
+}
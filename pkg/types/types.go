@@ -11,33 +11,89 @@ type MXRecord struct {
 
 // MXStats contains information about a domain's MX records
 type MXStats struct {
-	Valid   bool       `json:"valid"`             // Indicates whether valid MX records are available for the domain
-	Records []MXRecord `json:"records,omitempty"` // List of retrieved MX records; omitted if none are found
-	Error   string     `json:"error,omitempty"`   // Description of any error encountered during MX lookup
+	Valid         bool       `json:"valid"`                    // Indicates whether valid MX records are available for the domain
+	Records       []MXRecord `json:"records,omitempty"`        // List of retrieved MX records; omitted if none are found
+	Error         string     `json:"error,omitempty"`          // Description of any error encountered during MX lookup
+	ErrorCategory string     `json:"error_category,omitempty"` // Classified lookup failure (e.g., "nxdomain", "dns_timeout")
+	Implicit      bool       `json:"implicit,omitempty"`       // Records come from the A/AAAA fallback because no MX records exist
 }
 
 // EmailReport represents the result of validating and processing an email address
 type EmailReport struct {
-	Email          string  `json:"email"`                     // The email address being validated
-	Valid          bool    `json:"valid"`                     // Indicates whether the email address has a valid format
-	Disposable     bool    `json:"disposable"`                // Indicates whether the domain is a disposable (temporary) email provider
-	Exists         *bool   `json:"exists,omitempty"`          // Indicates whether the email address exists (nil if not checked)
-	MX             MXStats `json:"mx"`                        // Contains MX record-related statistics and errors
-	PermanentError bool    `json:"permanent_error,omitempty"` // Indicates if a permanent error occurred during validation
-	ErrorCategory  string  `json:"error_category,omitempty"`  // Describes the error type, if any (e.g., "mailbox_not_found")
-	TTL            int     `json:"ttl,omitempty"`             // Time-to-live value for retrying validation (if temporary error)
-	SMTPError      string  `json:"smtp_error,omitempty"`      // Description of any SMTP error encountered during validation
+	Email          string    `json:"email"`                     // The email address being validated
+	Valid          bool      `json:"valid"`                     // Indicates whether the email address has a valid format
+	Disposable     bool      `json:"disposable"`                // Indicates whether the domain is a disposable (temporary) email provider
+	Role           bool      `json:"role"`                      // Indicates whether the address is a role-based mailbox (e.g. info@, support@)
+	DomainAgeDays  int       `json:"domain_age_days,omitempty"` // Days since the domain was registered (omitted when unknown or not requested)
+	Exists         *bool     `json:"exists,omitempty"`          // Indicates whether the email address exists (nil if not checked)
+	MX             MXStats   `json:"mx"`                        // Contains MX record-related statistics and errors
+	PermanentError bool      `json:"permanent_error,omitempty"` // Indicates if a permanent error occurred during validation
+	ErrorCategory  string    `json:"error_category,omitempty"`  // Describes the error type, if any (e.g., "mailbox_not_found")
+	TTL            int       `json:"ttl,omitempty"`             // Time-to-live value for retrying validation (if temporary error)
+	RetryAfter     int       `json:"retry_after,omitempty"`     // Seconds until the throttled domain can be checked again
+	ThrottledUntil time.Time `json:"throttled_until,omitzero"`  // When the domain throttle expires (omitted if not throttled)
+	RetryScheduled bool      `json:"retry_scheduled,omitempty"` // A background re-check was scheduled after a temporary failure
+	SMTPError      string    `json:"smtp_error,omitempty"`      // Description of any SMTP error encountered during validation
+	Score          int       `json:"score"`                     // Confidence score from 0 (undeliverable) to 100 (deliverable)
+	Risk           string    `json:"risk"`                      // Risk level derived from the score: low, medium or high
+	PlannedProbes  []string  `json:"planned_probes,omitempty"`  // SMTP host:port pairs that would be probed (dry-run only)
+	CheckedAt      time.Time `json:"checked_at,omitzero"`       // When the check ran; cached reports keep the time of the original check
 }
 
 // Task represents a batch email validation task
 type Task struct {
-	ID        string         `json:"id"`                // Unique identifier for the task
-	Status    string         `json:"status"`            // Current status of the task (e.g., "pending", "processing", "completed")
-	Emails    []string       `json:"emails"`            // List of email addresses to be validated in the task
-	Results   []EmailReport  `json:"results"`           // List of validation results for the processed emails
-	CreatedAt time.Time      `json:"created_at"`        // Timestamp indicating when the task was created
-	Webhook   *WebhookConfig `json:"webhook,omitempty"` // Webhook configuration
-	APIKey    string         `json:"api_key,omitempty"` // APIKey
+	ID           string            `json:"id"`                      // Unique identifier for the task
+	Status       string            `json:"status"`                  // Current status of the task (e.g., "pending", "processing", "completed", "completed_partial")
+	Emails       []string          `json:"emails"`                  // List of email addresses to be validated in the task
+	Results      []EmailReport     `json:"results"`                 // List of validation results for the processed emails
+	CreatedAt    time.Time         `json:"created_at"`              // Timestamp indicating when the task was created
+	CompletedAt  time.Time         `json:"completed_at,omitzero"`   // Timestamp indicating when processing finished
+	Webhook      *WebhookConfig    `json:"webhook,omitempty"`       // Single webhook of tasks stored before Webhooks existed
+	Webhooks     []WebhookConfig   `json:"webhooks,omitempty"`      // Destinations notified when the task completes
+	APIKey       string            `json:"api_key,omitempty"`       // APIKey
+	RequestID    string            `json:"request_id,omitempty"`    // Correlation ID of the request that created the task
+	Checks       *CheckToggles     `json:"checks,omitempty"`        // Optional checks switched on or off for this task
+	Fresh        bool              `json:"fresh,omitempty"`         // Ignore cached verdicts and check every email again
+	Summary      *TaskSummary      `json:"summary,omitempty"`       // Deliverability breakdown, stored when the task completes
+	TraceContext map[string]string `json:"trace_context,omitempty"` // W3C trace context of the creating request, continued by task processing
+	Export       *ExportConfig     `json:"export,omitempty"`        // Upload the results to S3-compatible storage on completion
+	ExportURL    string            `json:"export_url,omitempty"`    // Object the results were uploaded to
+	ExportError  string            `json:"export_error,omitempty"`  // Why the upload failed
+}
+
+// ExportConfig uploads the results of a completed task to an S3-compatible bucket
+// Without bucket and endpoint the server's export destination is used and only format and prefix apply
+type ExportConfig struct {
+	Format    string `json:"format,omitempty"`     // "json" (default) or "csv"
+	Prefix    string `json:"prefix,omitempty"`     // Object key prefix; the key is <prefix><task_id>.<format>
+	Endpoint  string `json:"endpoint,omitempty"`   // Service URL, e.g. https://s3.eu-west-1.amazonaws.com
+	Region    string `json:"region,omitempty"`     // Signing region (default us-east-1)
+	Bucket    string `json:"bucket,omitempty"`     // Destination bucket
+	AccessKey string `json:"access_key,omitempty"` // Credentials with s3:PutObject on the bucket
+	SecretKey string `json:"-"`                    // Never serialized, so it is not stored with the task
+}
+
+// TaskSummary counts task results per deliverability class; skipped (not_checked) emails are not counted
+type TaskSummary struct {
+	Deliverable   int `json:"deliverable"`   // Mailbox accepted by the SMTP server
+	Undeliverable int `json:"undeliverable"` // Permanently rejected (hard bounce)
+	Risky         int `json:"risky"`         // Temporary errors, no definitive answer or disposable (soft bounce)
+	Invalid       int `json:"invalid"`       // Failed syntax validation
+}
+
+// QuotaEstimate is the quota cost of an email list computed before it is submitted
+type QuotaEstimate struct {
+	Emails        int      `json:"emails"`                   // Addresses in the request
+	Duplicates    int      `json:"duplicates"`               // Repeats of an earlier address (case and whitespace ignored), not charged
+	Invalid       int      `json:"invalid"`                  // Distinct addresses failing syntax validation
+	InvalidEmails []string `json:"invalid_emails,omitempty"` // The invalid addresses, listed when excluded from the cost
+	Chargeable    int      `json:"chargeable"`               // Checks the list consumes
+}
+
+// CheckToggles enables or disables optional checks per request; unset toggles keep the check enabled
+type CheckToggles struct {
+	Disposable *bool `json:"disposable,omitempty"` // Disposable email provider lookup
+	Role       *bool `json:"role,omitempty"`       // Role-based mailbox detection
 }
 
 // WebhookConfig contains the parameters for task status notifications
@@ -47,4 +103,16 @@ type WebhookConfig struct {
 	TTLStr  string        `json:"ttl"`     // Accepts a string from JSON (e.g., "1h")
 	Retries int           `json:"retries"` // Maximum number of retry attempts
 	Secret  string        `json:"secret"`  // Secret for signing requests (optional)
+
+	Headers map[string]string `json:"headers,omitempty"` // Extra request headers, e.g. Authorization for the receiver
+
+	SignatureHeader    string `json:"signature_header,omitempty"`    // Header carrying the signature (default "X-Signature")
+	SignaturePrefix    string `json:"signature_prefix,omitempty"`    // Scheme prefix prepended to the signature (e.g. "sha256=")
+	SignatureEncoding  string `json:"signature_encoding,omitempty"`  // Signature encoding: "hex" (default) or "base64"
+	SignatureAlgorithm string `json:"signature_algorithm,omitempty"` // HMAC hash: "sha256" (default) or "sha512"
+	SignTimestamp      bool   `json:"sign_timestamp,omitempty"`      // Sign "timestamp.payload" instead of the payload alone
+
+	ClientCert string `json:"client_cert,omitempty"` // PEM client certificate for mutual TLS (optional, held in memory only by the server)
+	ClientKey  string `json:"client_key,omitempty"`  // PEM private key of the client certificate (never stored with the task)
+	CACert     string `json:"ca_cert,omitempty"`     // PEM CA bundle trusted for the receiver instead of the system roots
 }